@@ -0,0 +1,153 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"nanto.io/application-auto-scaling-service/pkg/backtest"
+	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/controller"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/loadstore"
+	"nanto.io/application-auto-scaling-service/pkg/utils/logutil"
+)
+
+// AdminUsage 运维命令说明
+const AdminUsage = `Admin commands:
+  history <targetHPA>              show apply history of the target customed HPA
+  rollback <targetHPA> [revision]  restore the previous spec (or the spec of the revision) and pause the schedule
+  resume <targetHPA>               remove the pause annotations, the schedule takes over again
+  restore <targetHPA>              restore the original spec recorded before the service took over and pause the schedule
+  generate <loadFile> [maxWindows] propose strategies yaml from a recorded load file (.json/.csv),
+                                   target and shunting are taken from the configured local strategies
+  backtest <loadFile> <strategiesFile> [otherStrategiesFile]
+                                   replay a recorded load file through the strategies and report replicas,
+                                   SLA violations and replica-hours, side by side when two files are given`
+
+// RunAdminCommand 执行运维命令，结果以 json 格式输出到标准输出
+func RunAdminCommand(configFile string, args []string) error {
+	if len(args) < 2 {
+		return errors.New(AdminUsage)
+	}
+	conf, err := config.LoadConfig(configFile)
+	if err != nil {
+		return err
+	}
+	logutil.Init(&conf.LogConf)
+	// 离线命令，不需要访问集群
+	switch args[0] {
+	case "generate":
+		return generateStrategies(conf, args[1:])
+	case "backtest":
+		return backtestStrategies(conf, args[1:])
+	}
+	if err = k8sclient.InitK8sClientSet(conf.K8sConf.Kubeconfig); err != nil {
+		return err
+	}
+
+	history := controller.NewHistoryStore(conf.StrategyConf.HistoryMaxRecords)
+	cmd, target := args[0], args[1]
+	switch cmd {
+	case "history":
+		records, err := history.List(target)
+		if err != nil {
+			return err
+		}
+		return printJSON(records)
+	case "rollback":
+		revision := 0
+		if len(args) > 2 {
+			if revision, err = strconv.Atoi(args[2]); err != nil || revision <= 0 {
+				return errors.Errorf("invalid revision[%s]", args[2])
+			}
+		}
+		record, err := controller.RollbackStrategy(history, target, revision)
+		if err != nil {
+			return err
+		}
+		return printJSON(record)
+	case "resume":
+		if err = controller.SetTargetPaused(target, false); err != nil {
+			return err
+		}
+		fmt.Printf("customed HPA[%s] resumed\n", target)
+		return nil
+	case "restore":
+		if err = controller.RestoreOriginalSpec(history, target, true); err != nil {
+			return err
+		}
+		fmt.Printf("customed HPA[%s] restored to original spec, schedule paused\n", target)
+		return nil
+	default:
+		return errors.Errorf("unknown command[%s]\n%s", cmd, AdminUsage)
+	}
+}
+
+// generateStrategies 根据负载数据文件生成策略，输出到标准输出
+func generateStrategies(conf *config.Config, args []string) error {
+	samples, err := loadstore.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	info, err := controller.ReadStrategiesFile(conf.StrategyConf.LocalPath)
+	if err != nil {
+		return err
+	}
+	opts := controller.GenerateOptions{
+		TargetHPA:         info.TargetHPA,
+		Shunting:          info.Shunting,
+		ReplicaThroughput: conf.PredictConf.ReplicaThroughput,
+	}
+	if len(args) > 1 {
+		if opts.MaxWindows, err = strconv.Atoi(args[1]); err != nil || opts.MaxWindows <= 0 {
+			return errors.Errorf("invalid maxWindows[%s]", args[1])
+		}
+	}
+	proposal, err := controller.GenerateStrategies(samples, opts)
+	if err != nil {
+		return err
+	}
+	out, err := proposal.YAML()
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return errors.Wrap(err, "write strategies err")
+}
+
+// backtestStrategies 用负载数据文件回放一个或两个策略文件，结果以表格形式输出到标准输出
+func backtestStrategies(conf *config.Config, args []string) error {
+	if len(args) < 2 || len(args) > 3 {
+		return errors.New(AdminUsage)
+	}
+	samples, err := loadstore.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	opts := backtest.Options{ReplicaThroughput: conf.PredictConf.ReplicaThroughput}
+	results := []*backtest.Result{}
+	for _, path := range args[1:] {
+		info, err := controller.ReadStrategiesFile(path)
+		if err != nil {
+			return err
+		}
+		result, err := backtest.Run(filepath.Base(path), info, samples, opts)
+		if err != nil {
+			return errors.Wrapf(err, "backtest strategies[%s] err", path)
+		}
+		results = append(results, result)
+	}
+	return backtest.WriteReport(os.Stdout, results, time.Hour)
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return errors.Wrap(encoder.Encode(v), "encode json err")
+}
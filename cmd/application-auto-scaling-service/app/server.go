@@ -8,9 +8,15 @@ import (
 	"syscall"
 	"time"
 
+	"nanto.io/application-auto-scaling-service/pkg/busystate"
 	"nanto.io/application-auto-scaling-service/pkg/config"
 	"nanto.io/application-auto-scaling-service/pkg/controller"
+	"nanto.io/application-auto-scaling-service/pkg/extmetrics"
+	"nanto.io/application-auto-scaling-service/pkg/grm"
 	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/loadstore"
+	"nanto.io/application-auto-scaling-service/pkg/promsource"
+	"nanto.io/application-auto-scaling-service/pkg/server"
 	"nanto.io/application-auto-scaling-service/pkg/syncer"
 	"nanto.io/application-auto-scaling-service/pkg/utils/logutil"
 	"nanto.io/application-auto-scaling-service/pkg/utils/obsutil"
@@ -75,15 +81,70 @@ func startService(ctx context.Context, conf *config.Config, cancel context.Cance
 		if obsCli, err = obsutil.NewObsClient(conf.ObsConf.Endpoint); err != nil {
 			return err
 		}
-		go syncer.NewInstanceSyncer(obsCli, &conf.ObsConf, conf.ClusterId).SyncInstanceToOBS(ctx)
+		instanceSyncer, err := syncer.NewInstanceSyncer(obsCli, &conf.ObsConf, &conf.NodeFilterConf, conf.ClusterId)
+		if err != nil {
+			return err
+		}
+		go instanceSyncer.SyncInstanceToOBS(ctx)
+	}
+
+	// conductor/worker 上报的负载数据，保存在内存中
+	loadStore := loadstore.NewStore(time.Duration(conf.LoadConf.RetentionHour)*time.Hour,
+		conf.LoadConf.MaxSamplesPerTaskType, conf.LoadConf.MaxTaskTypes)
+
+	// Prometheus 指标源：用于门控条件，并导入各任务类型的请求量
+	if err = promsource.Init(&conf.PrometheusConf); err != nil {
+		return err
+	}
+	if promCli := promsource.GetClient(); promCli != nil {
+		go promsource.NewLoadImporter(promCli, loadStore, conf.PrometheusConf.LoadQueries,
+			time.Duration(conf.PrometheusConf.LoadIntervalSecond)*time.Second,
+			time.Duration(conf.PrometheusConf.LoadBackfillHour)*time.Hour).Run(ctx)
+	}
+
+	// GRM 客户端：提高 minReplicas 超出集群容量时申请节点，降低后释放
+	if err = grm.Init(&conf.GRMConf, conf.ClusterId); err != nil {
+		return err
+	}
+
+	// pod 任务状态：维护 pod-deletion-cost 注解，缩容时优先删除空闲的 pod
+	if err = busystate.Init(&conf.BusyStateConf); err != nil {
+		return err
 	}
 
 	// 启动strategy controller，修改 cce 的 hpa策略
-	go controller.NewStrategyController(&conf.StrategyConf).Start(ctx, cancel)
+	strategyController, err := controller.NewStrategyController(&conf.StrategyConf, loadStore,
+		time.Duration(conf.LoadConf.RateWindowMinute)*time.Minute, &conf.PredictConf, &conf.GRMConf)
+	if err != nil {
+		return err
+	}
+	go strategyController.Start(ctx, cancel)
 
-	// todo 初始化 http client（请求 GTM、GRM）
+	// 启动 http server（管理接口、给 conductor 提供分流策略、接收负载数据、metrics）
+	if conf.ServerConf.ListenAddr != "" {
+		srv := server.NewServer(&conf.ServerConf)
+		srv.HandleAdmin(strategyController)
+		srv.HandleShunting(strategyController)
+		srv.HandleLoad(loadStore)
+		srv.HandlePrediction(strategyController)
+		srv.HandleProposal(strategyController)
+		if tracker := busystate.GetTracker(); tracker != nil && tracker.Source() == busystate.SourcePush {
+			srv.HandleBusyState(tracker)
+		}
+		go srv.Start(ctx, cancel)
+	}
 
-	// todo 启动 http server（给 conductor 提供分流策略）
+	// 启动 external.metrics.k8s.io 聚合 API，供 HPA 按业务指标扩缩容
+	if conf.ServerConf.ExternalMetricsListenAddr != "" {
+		metricsSrv, err := server.NewExternalMetricsServer(&conf.ServerConf)
+		if err != nil {
+			return err
+		}
+		metricsSrv.HandleExternalMetrics(extmetrics.NewProvider(loadStore,
+			time.Duration(conf.LoadConf.RateWindowMinute)*time.Minute, promsource.GetClient(),
+			conf.PrometheusConf.ExternalMetrics))
+		go metricsSrv.Start(ctx, cancel)
+	}
 
 	return nil
 }
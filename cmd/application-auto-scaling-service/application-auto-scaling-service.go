@@ -12,8 +12,21 @@ const defaultConfPath = "/opt/cloud/application-auto-scaling-service/conf/applic
 
 func main() {
 	configFile := flag.String("config-file", defaultConfPath, "Service conf file")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [command args...]\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprintln(flag.CommandLine.Output(), app.AdminUsage)
+	}
 	flag.Parse()
 
+	// 无子命令时启动服务，否则执行运维命令
+	if flag.NArg() > 0 {
+		if err := app.RunAdminCommand(*configFile, flag.Args()); err != nil {
+			fmt.Fprintf(os.Stderr, "app.RunAdminCommand err: %+v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 	if err := app.Run(*configFile); err != nil {
 		fmt.Fprintf(os.Stderr, "app.Run err: %+v\n", err)
 		os.Exit(1)
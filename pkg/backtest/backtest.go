@@ -0,0 +1,427 @@
+package backtest
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"nanto.io/application-auto-scaling-service/pkg/controller"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	"nanto.io/application-auto-scaling-service/pkg/loadstore"
+	"nanto.io/application-auto-scaling-service/pkg/predictor"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+const (
+	minStep = time.Minute
+	// 默认的 SLA 上限：单副本负载超过处理能力（利用率 > 1）即视为违反
+	defaultMaxUtilization = 1.0
+
+	infinity = "+Infinity"
+)
+
+// Options 回放参数
+type Options struct {
+	// 回放的时间步长，为 0 时取负载数据的上报间隔（中位数），且不小于 1 分钟
+	Step time.Duration
+	// 策略文件分流配置中没有的任务类型使用的单副本处理能力（每分钟请求数），为 0 时忽略这些任务类型
+	ReplicaThroughput float64
+	// 单副本负载上限（相对处理能力的利用率），超过即记为一次 SLA 违反
+	MaxUtilization float64
+	Location       *time.Location
+}
+
+// Point 回放过程中某一时刻的状态
+type Point struct {
+	Time   time.Time `json:"time"`
+	Window string    `json:"window"`
+	// 承载当前负载需要的副本数（按单副本处理能力折算，未取整）
+	Demand   float64 `json:"demand"`
+	Replicas int32   `json:"replicas"`
+	// 单副本负载相对处理能力的利用率，即 CPURatioToRequest 指标的模拟值
+	Utilization float64 `json:"utilization"`
+	Violation   bool    `json:"violation,omitempty"`
+	// 本时间步副本数的变化（包括时间段切换引起的调整）
+	Change int32 `json:"change,omitempty"`
+}
+
+// Summary 回放结果汇总
+type Summary struct {
+	ReplicaHours    float64 `json:"replicaHours"`
+	Violations      int     `json:"violations"`
+	ViolationRatio  float64 `json:"violationRatio"`
+	MinReplicas     int32   `json:"minReplicas"`
+	MaxReplicas     int32   `json:"maxReplicas"`
+	AvgReplicas     float64 `json:"avgReplicas"`
+	PeakUtilization float64 `json:"peakUtilization"`
+	// 副本数增加、减少的次数（包括时间段切换引起的调整）
+	ScaleUps   int `json:"scaleUps"`
+	ScaleDowns int `json:"scaleDowns"`
+}
+
+// Result 某个策略文件的回放结果
+type Result struct {
+	Name    string  `json:"name"`
+	Summary Summary `json:"summary"`
+	// 各策略时间段的汇总
+	Windows map[string]*Summary `json:"windows"`
+	Points  []Point             `json:"points"`
+}
+
+// window 策略时间段及其在一天中的起始分钟
+type window struct {
+	startMinute int
+	strategy    controller.Strategy
+	coolDown    time.Duration
+}
+
+// ruleState 规则的连续命中次数及上次评估时间
+type ruleState struct {
+	hits     int32
+	lastEval time.Time
+}
+
+func (o *Options) complete(samples map[string][]loadstore.Sample) {
+	if o.Step <= 0 {
+		o.Step = sampleInterval(samples)
+	}
+	if o.MaxUtilization <= 0 {
+		o.MaxUtilization = defaultMaxUtilization
+	}
+	if o.Location == nil {
+		o.Location = time.Local
+	}
+}
+
+// Run 将负载数据（按任务类型分组、时间升序）按 CustomedHPA 规则语义回放：
+// 每个时间步按当前时间段的规则评估利用率，命中 hitThreshold 次后按 metricRange 匹配的 action 扩缩容，
+// 扩缩容后 coolDownTime 内不再触发，副本数限制在 [minReplicas, maxReplicas]
+func Run(name string, info *controller.StrategiesInfo, samples map[string][]loadstore.Sample,
+	opts Options) (*Result, error) {
+	opts.complete(samples)
+	windows, err := parseWindows(info.Strategies)
+	if err != nil {
+		return nil, err
+	}
+	demand, start, err := demandSeries(info, samples, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Name: name, Windows: map[string]*Summary{}, Points: make([]Point, 0, len(demand))}
+	states := map[string]*ruleState{}
+	var (
+		replicas     int32
+		lastScale    time.Time
+		activeWindow string
+	)
+	for i, d := range demand {
+		now := start.Add(time.Duration(i) * opts.Step)
+		w := activeAt(windows, now.In(opts.Location))
+		spec := &w.strategy.Spec
+		min, max := utils.Int32Value(spec.MinReplicas), utils.Int32Value(spec.MaxReplicas)
+		prev := replicas
+		if w.strategy.ValidTime != activeWindow {
+			// 进入新时间段：规则重新计数，副本数按新的 min/max 调整
+			activeWindow = w.strategy.ValidTime
+			states = map[string]*ruleState{}
+			if i == 0 {
+				replicas, prev = min, min
+			}
+		}
+		replicas = clamp(replicas, min, max)
+
+		utilization := d / float64(replicas)
+		if !lastScale.IsZero() && now.Sub(lastScale) < w.coolDown {
+			// 冷却时间内仍然评估规则，但不执行动作
+			evaluateRules(spec.Rules, states, utilization, now)
+		} else if delta := evaluateRules(spec.Rules, states, utilization, now); delta != 0 {
+			if next := clamp(replicas+delta, min, max); next != replicas {
+				replicas = next
+				lastScale = now
+			}
+		}
+
+		result.Points = append(result.Points, Point{
+			Time:        now,
+			Window:      activeWindow,
+			Demand:      d,
+			Replicas:    replicas,
+			Utilization: d / float64(replicas),
+			Violation:   d/float64(replicas) > opts.MaxUtilization,
+			Change:      replicas - prev,
+		})
+	}
+
+	summarize(&result.Summary, result.Points, opts.Step)
+	byWindow := map[string][]Point{}
+	for _, p := range result.Points {
+		byWindow[p.Window] = append(byWindow[p.Window], p)
+	}
+	for validTime, points := range byWindow {
+		summary := &Summary{}
+		summarize(summary, points, opts.Step)
+		result.Windows[validTime] = summary
+	}
+	return result, nil
+}
+
+// evaluateRules 评估各规则是否命中，返回需要变化的副本数；扩容规则优先
+func evaluateRules(rules []v1alpha1.Rule, states map[string]*ruleState, utilization float64, now time.Time) int32 {
+	var delta int32
+	for _, rule := range sortedRules(rules) {
+		if rule.Disable != nil && *rule.Disable {
+			continue
+		}
+		state, ok := states[rule.RuleName]
+		if !ok {
+			state = &ruleState{}
+			states[rule.RuleName] = state
+		}
+		period := time.Duration(utils.Int32Value(rule.MetricTrigger.PeriodSeconds)) * time.Second
+		if !state.lastEval.IsZero() && now.Sub(state.lastEval) < period {
+			continue
+		}
+		state.lastEval = now
+
+		if !triggered(&rule.MetricTrigger, utilization) {
+			state.hits = 0
+			continue
+		}
+		state.hits++
+		hitThreshold := utils.Int32Value(rule.MetricTrigger.HitThreshold)
+		if state.hits < hitThreshold || delta != 0 {
+			continue
+		}
+		state.hits = 0
+		for _, action := range rule.Actions {
+			if !inRange(action.MetricRange, utilization) {
+				continue
+			}
+			value := utils.Int32Value(action.OperationValue)
+			if action.OperationType == controller.OperationTypeScaleUp {
+				delta = value
+			} else {
+				delta = -value
+			}
+			break
+		}
+	}
+	return delta
+}
+
+// sortedRules 扩容规则排在缩容规则之前
+func sortedRules(rules []v1alpha1.Rule) []v1alpha1.Rule {
+	sorted := append([]v1alpha1.Rule{}, rules...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].MetricTrigger.MetricOperation == controller.MetricOptScaleUp &&
+			sorted[j].MetricTrigger.MetricOperation != controller.MetricOptScaleUp
+	})
+	return sorted
+}
+
+func triggered(trigger *v1alpha1.MetricTrigger, value float64) bool {
+	if trigger.MetricValue == nil {
+		return false
+	}
+	threshold := float64(*trigger.MetricValue)
+	switch trigger.MetricOperation {
+	case controller.MetricOptScaleUp:
+		return value > threshold
+	case controller.MetricOptScaleDown:
+		return value < threshold
+	}
+	return false
+}
+
+// inRange 判断指标值是否在 metricRange（"下限,上限"，上限可为 +Infinity）内，包含下限不包含上限
+func inRange(metricRange string, value float64) bool {
+	bounds := strings.Split(metricRange, ",")
+	if len(bounds) != 2 {
+		return false
+	}
+	low, err := strconv.ParseFloat(strings.TrimSpace(bounds[0]), 64)
+	if err != nil {
+		return false
+	}
+	high := math.Inf(1)
+	if val := strings.TrimSpace(bounds[1]); val != infinity {
+		if high, err = strconv.ParseFloat(val, 64); err != nil {
+			return false
+		}
+	}
+	return value >= low && value < high
+}
+
+func clamp(replicas, min, max int32) int32 {
+	if replicas < min {
+		replicas = min
+	}
+	if max > 0 && replicas > max {
+		replicas = max
+	}
+	if replicas < 1 {
+		replicas = 1
+	}
+	return replicas
+}
+
+// parseWindows 解析各时间段的起始时间和冷却时间，按起始时间升序
+func parseWindows(strategies []controller.Strategy) ([]window, error) {
+	if len(strategies) == 0 {
+		return nil, errors.New("no strategies")
+	}
+	windows := []window{}
+	for _, strategy := range strategies {
+		startMinute, err := parseStartMinute(strategy.ValidTime)
+		if err != nil {
+			return nil, err
+		}
+		w := window{startMinute: startMinute, strategy: strategy}
+		if strategy.Spec.CoolDownTime != "" {
+			if w.coolDown, err = time.ParseDuration(strategy.Spec.CoolDownTime); err != nil {
+				return nil, errors.Wrapf(err, "invalid coolDownTime[%s] of window[%s]", strategy.Spec.CoolDownTime,
+					strategy.ValidTime)
+			}
+		}
+		windows = append(windows, w)
+	}
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].startMinute < windows[j].startMinute
+	})
+	return windows, nil
+}
+
+// parseStartMinute 解析时间段（eg："0:00-09:30"）的起始时刻，返回一天中的分钟数
+func parseStartMinute(validTime string) (int, error) {
+	times := strings.Split(validTime, "-")
+	if len(times) != 2 {
+		return 0, errors.Errorf("illegal validTime[%s]", validTime)
+	}
+	hourAndMinute := strings.Split(times[0], ":")
+	if len(hourAndMinute) != 2 {
+		return 0, errors.Errorf("illegal validTime[%s]", validTime)
+	}
+	hour, err := strconv.Atoi(hourAndMinute[0])
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, errors.Errorf("illegal validTime[%s]", validTime)
+	}
+	minute, err := strconv.Atoi(hourAndMinute[1])
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, errors.Errorf("illegal validTime[%s]", validTime)
+	}
+	return hour*60 + minute, nil
+}
+
+// activeAt 与定时任务一致：起始时刻最近（不晚于 t）的时间段生效，当天还没有时间段开始时沿用前一天最后一个
+func activeAt(windows []window, t time.Time) *window {
+	minute := t.Hour()*60 + t.Minute()
+	active := &windows[len(windows)-1]
+	for i := range windows {
+		if windows[i].startMinute <= minute {
+			active = &windows[i]
+		}
+	}
+	return active
+}
+
+// demandSeries 将各任务类型的负载按单副本处理能力折算为每个时间步需要的副本数
+func demandSeries(info *controller.StrategiesInfo, samples map[string][]loadstore.Sample,
+	opts *Options) ([]float64, time.Time, error) {
+	var start, end time.Time
+	for _, taskSamples := range samples {
+		if len(taskSamples) == 0 {
+			continue
+		}
+		if first := taskSamples[0].Time; start.IsZero() || first.Before(start) {
+			start = first
+		}
+		if last := taskSamples[len(taskSamples)-1].Time; last.After(end) {
+			end = last
+		}
+	}
+	if start.IsZero() {
+		return nil, start, errors.New("no load samples")
+	}
+	start = start.Truncate(opts.Step)
+	n := int(end.Sub(start)/opts.Step) + 1
+
+	demand := make([]float64, n)
+	used := 0
+	for taskType, taskSamples := range samples {
+		throughput := opts.ReplicaThroughput
+		for _, conf := range info.Shunting {
+			if conf.TaskType == taskType {
+				throughput = conf.ReplicaCapacity
+			}
+		}
+		if throughput <= 0 {
+			continue
+		}
+		used++
+		for i, rate := range predictor.Resample(taskSamples, start, opts.Step, n) {
+			demand[i] += rate / throughput
+		}
+	}
+	if used == 0 {
+		return nil, start, errors.New("no load samples of task types with replica throughput")
+	}
+	return demand, start, nil
+}
+
+// sampleInterval 负载数据上报间隔的中位数，上报间隔大于时间步长时，按步长重采样会重复计算同一个上报周期的请求数
+func sampleInterval(samples map[string][]loadstore.Sample) time.Duration {
+	intervals := []time.Duration{}
+	for _, taskSamples := range samples {
+		for i := 1; i < len(taskSamples); i++ {
+			if d := taskSamples[i].Time.Sub(taskSamples[i-1].Time); d > 0 {
+				intervals = append(intervals, d)
+			}
+		}
+	}
+	if len(intervals) == 0 {
+		return minStep
+	}
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i] < intervals[j]
+	})
+	if interval := intervals[len(intervals)/2].Round(time.Second); interval > minStep {
+		return interval
+	}
+	return minStep
+}
+
+func summarize(summary *Summary, points []Point, step time.Duration) {
+	if len(points) == 0 {
+		return
+	}
+	summary.MinReplicas = math.MaxInt32
+	total := 0.0
+	for _, p := range points {
+		if p.Change > 0 {
+			summary.ScaleUps++
+		} else if p.Change < 0 {
+			summary.ScaleDowns++
+		}
+		total += float64(p.Replicas)
+		if p.Violation {
+			summary.Violations++
+		}
+		if p.Replicas < summary.MinReplicas {
+			summary.MinReplicas = p.Replicas
+		}
+		if p.Replicas > summary.MaxReplicas {
+			summary.MaxReplicas = p.Replicas
+		}
+		if p.Utilization > summary.PeakUtilization {
+			summary.PeakUtilization = p.Utilization
+		}
+	}
+	summary.ReplicaHours = total * step.Hours()
+	summary.AvgReplicas = total / float64(len(points))
+	summary.ViolationRatio = float64(summary.Violations) / float64(len(points))
+}
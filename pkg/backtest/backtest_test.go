@@ -0,0 +1,150 @@
+package backtest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"nanto.io/application-auto-scaling-service/pkg/controller"
+	"nanto.io/application-auto-scaling-service/pkg/loadstore"
+)
+
+const testStrategies = `targetHPA: "chpa"
+shunting:
+  - taskType: "transcode"
+    replicaCapacity: 10
+strategies:
+  - validTime: "0:00-24:00"
+    spec:
+      coolDownTime: 1m
+      maxReplicas: 10
+      minReplicas: 1
+      rules:
+        - actions:
+            - metricRange: "0.60,+Infinity"
+              operationValue: %d
+          metricTrigger:
+            metricOperation: ">"
+            metricValue: 0.6
+          ruleName: up
+        - actions:
+            - metricRange: "0.00,0.20"
+              operationValue: 1
+          metricTrigger:
+            metricOperation: "<"
+            metricValue: 0.2
+          ruleName: down
+`
+
+func readTestStrategies(t *testing.T, dir string, upStep string) *controller.StrategiesInfo {
+	path := filepath.Join(dir, "strategies-"+upStep+".yaml")
+	content := strings.Replace(testStrategies, "%d", upStep, 1)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := controller.ReadStrategiesFile(path)
+	if err != nil {
+		t.Fatalf("read strategies err: %v", err)
+	}
+	return info
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 前 10 分钟每分钟 50 个请求（需要 5 个副本），之后 20 分钟没有请求
+	start := time.Date(2021, 10, 1, 8, 0, 0, 0, time.UTC)
+	samples := []loadstore.Sample{}
+	for m := 0; m < 30; m++ {
+		requests := 0.0
+		if m < 10 {
+			requests = 50
+		}
+		samples = append(samples, loadstore.Sample{Time: start.Add(time.Duration(m) * time.Minute), Requests: requests})
+	}
+	trace := map[string][]loadstore.Sample{"transcode": samples}
+	opts := Options{Location: time.UTC}
+
+	got, err := Run("step2", readTestStrategies(t, dir, "2"), trace, opts)
+	if err != nil {
+		t.Fatalf("Run() err: %v", err)
+	}
+	// 1 -> 3 -> 5 -> 7 -> 9，利用率低于 0.6 后停止扩容
+	wantReplicas := []int32{3, 5, 7, 9, 9}
+	for i, want := range wantReplicas {
+		if got.Points[i].Replicas != want {
+			t.Errorf("Run() replicas at minute %d = %d, want %d", i, got.Points[i].Replicas, want)
+		}
+	}
+	// 负载消失后每分钟缩容 1 个，直到 minReplicas
+	if last := got.Points[len(got.Points)-1]; last.Replicas != 1 {
+		t.Errorf("Run() last replicas = %d, want 1", last.Replicas)
+	}
+	if got.Summary.Violations != 1 || got.Summary.MaxReplicas != 9 || got.Summary.ScaleUps != 4 {
+		t.Errorf("Run() summary = %+v", got.Summary)
+	}
+	if got.Windows["0:00-24:00"] == nil {
+		t.Errorf("Run() windows = %+v", got.Windows)
+	}
+
+	// 扩容步长为 1 时违反 SLA 的时间更长，但成本更低
+	other, err := Run("step1", readTestStrategies(t, dir, "1"), trace, opts)
+	if err != nil {
+		t.Fatalf("Run() err: %v", err)
+	}
+	if other.Summary.Violations <= got.Summary.Violations || other.Summary.ReplicaHours >= got.Summary.ReplicaHours {
+		t.Errorf("Run() step1 summary = %+v, step2 summary = %+v", other.Summary, got.Summary)
+	}
+
+	buf := &bytes.Buffer{}
+	if err = WriteReport(buf, []*Result{got, other}, 10*time.Minute); err != nil {
+		t.Fatalf("WriteReport() err: %v", err)
+	}
+	if report := buf.String(); !strings.Contains(report, "delta") || !strings.Contains(report, "TIMELINE") {
+		t.Errorf("WriteReport() got:\n%s", report)
+	}
+}
+
+func Test_inRange(t *testing.T) {
+	tests := []struct {
+		metricRange string
+		value       float64
+		want        bool
+	}{
+		{"0.60,+Infinity", 0.6, true},
+		{"0.60,+Infinity", 100, true},
+		{"0.40,0.50", 0.5, false},
+		{"0.00,0.20", 0.1, true},
+		{"invalid", 0.1, false},
+	}
+	for _, tt := range tests {
+		if got := inRange(tt.metricRange, tt.value); got != tt.want {
+			t.Errorf("inRange(%s, %v) = %v, want %v", tt.metricRange, tt.value, got, tt.want)
+		}
+	}
+}
+
+func Test_activeAt(t *testing.T) {
+	windows, err := parseWindows([]controller.Strategy{{ValidTime: "8:00-20:00"}, {ValidTime: "20:00-8:00"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		clock string
+		want  string
+	}{{"07:59", "20:00-8:00"}, {"08:00", "8:00-20:00"}, {"21:00", "20:00-8:00"}}
+	for _, tt := range tests {
+		now, _ := time.Parse("15:04", tt.clock)
+		if got := activeAt(windows, now).strategy.ValidTime; got != tt.want {
+			t.Errorf("activeAt(%s) = %s, want %s", tt.clock, got, tt.want)
+		}
+	}
+}
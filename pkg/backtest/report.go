@@ -0,0 +1,77 @@
+package backtest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// WriteReport 以表格形式并排输出多个策略文件的回放结果：汇总、各时间段汇总，以及每隔 interval 的副本数
+func WriteReport(w io.Writer, results []*Result, interval time.Duration) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "SUMMARY")
+	fmt.Fprintln(tw, "strategies\treplica-hours\tviolations\tviolation-ratio\tmin\tmax\tavg\tpeak-util\tscale-ups\tscale-downs")
+	for _, r := range results {
+		writeSummary(tw, r.Name, &r.Summary)
+	}
+	if len(results) == 2 {
+		a, b := &results[0].Summary, &results[1].Summary
+		fmt.Fprintf(tw, "delta\t%+.2f\t%+d\t%+.4f\t%+d\t%+d\t%+.2f\t%+.2f\t%+d\t%+d\n",
+			b.ReplicaHours-a.ReplicaHours, b.Violations-a.Violations, b.ViolationRatio-a.ViolationRatio,
+			b.MinReplicas-a.MinReplicas, b.MaxReplicas-a.MaxReplicas, b.AvgReplicas-a.AvgReplicas,
+			b.PeakUtilization-a.PeakUtilization, b.ScaleUps-a.ScaleUps, b.ScaleDowns-a.ScaleDowns)
+	}
+
+	fmt.Fprintln(tw, "\nWINDOWS")
+	fmt.Fprintln(tw, "strategies\twindow\treplica-hours\tviolations\tviolation-ratio\tmin\tmax\tavg\tpeak-util\tscale-ups\tscale-downs")
+	for _, r := range results {
+		windows := make([]string, 0, len(r.Windows))
+		for validTime := range r.Windows {
+			windows = append(windows, validTime)
+		}
+		sort.Strings(windows)
+		for _, validTime := range windows {
+			writeSummary(tw, r.Name+"\t"+validTime, r.Windows[validTime])
+		}
+	}
+
+	if len(results) > 0 && interval > 0 {
+		fmt.Fprintln(tw, "\nTIMELINE")
+		header := []string{"time", "demand"}
+		for _, r := range results {
+			header = append(header, r.Name+" replicas", r.Name+" util")
+		}
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		var next time.Time
+		for i, p := range results[0].Points {
+			if p.Time.Before(next) {
+				continue
+			}
+			next = p.Time.Add(interval)
+			row := []string{p.Time.Format(time.RFC3339), fmt.Sprintf("%.2f", p.Demand)}
+			for _, r := range results {
+				if i >= len(r.Points) {
+					row = append(row, "-", "-")
+					continue
+				}
+				mark := ""
+				if r.Points[i].Violation {
+					mark = "!"
+				}
+				row = append(row, fmt.Sprintf("%d", r.Points[i].Replicas),
+					fmt.Sprintf("%.2f%s", r.Points[i].Utilization, mark))
+			}
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+	}
+	return tw.Flush()
+}
+
+func writeSummary(w io.Writer, name string, s *Summary) {
+	fmt.Fprintf(w, "%s\t%.2f\t%d\t%.4f\t%d\t%d\t%.2f\t%.2f\t%d\t%d\n", name, s.ReplicaHours, s.Violations,
+		s.ViolationRatio, s.MinReplicas, s.MaxReplicas, s.AvgReplicas, s.PeakUtilization, s.ScaleUps, s.ScaleDowns)
+}
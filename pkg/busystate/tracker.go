@@ -0,0 +1,211 @@
+package busystate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/utils/logutil"
+)
+
+// 任务状态来源
+const (
+	SourceProbe = "probe"
+	SourcePush  = "push"
+)
+
+var (
+	logger = logutil.GetLogger()
+
+	tracker *Tracker
+)
+
+// State pod 的任务状态
+type State struct {
+	Busy bool `json:"busy"`
+	// 正在执行的任务数
+	ActiveTasks int `json:"activeTasks"`
+	// 正在执行的任务中最早开始的时间
+	TaskStartedAt *time.Time `json:"taskStartedAt,omitempty"`
+	// 上报或探测到该状态的时间
+	ReportedAt time.Time `json:"reportedAt"`
+}
+
+// Tracker 获取目标负载各 pod 的任务状态：probe 模式下并发请求各 pod 的 HTTP 接口，push 模式下读取 worker 上报的状态
+type Tracker struct {
+	source      string
+	probePort   int
+	probePath   string
+	concurrency int
+	httpClient  *http.Client
+	ttl         time.Duration
+	interval    time.Duration
+
+	mu     sync.Mutex
+	pushed map[string]State
+}
+
+// Init 初始化全局 Tracker，未配置任务状态来源时不初始化
+func Init(conf *config.BusyStateConf) error {
+	if conf.Source == "" {
+		return nil
+	}
+	t, err := NewTracker(conf)
+	if err != nil {
+		return err
+	}
+	tracker = t
+	return nil
+}
+
+// GetTracker 获取全局 Tracker，未配置时返回 nil
+func GetTracker() *Tracker {
+	return tracker
+}
+
+// SetTracker 直接指定全局 Tracker，用于单测
+func SetTracker(t *Tracker) {
+	tracker = t
+}
+
+// NewTracker 创建 Tracker
+func NewTracker(conf *config.BusyStateConf) (*Tracker, error) {
+	switch conf.Source {
+	case SourceProbe:
+		if conf.ProbePort <= 0 || conf.ProbePort > 65535 {
+			return nil, errors.Errorf("invalid busy state probe port[%d]", conf.ProbePort)
+		}
+	case SourcePush:
+		if conf.PushTTLSecond <= 0 {
+			return nil, errors.Errorf("invalid busy state push ttl[%d]", conf.PushTTLSecond)
+		}
+	default:
+		return nil, errors.Errorf("invalid busy state source[%s], must be %s or %s", conf.Source, SourceProbe,
+			SourcePush)
+	}
+	interval := time.Duration(conf.IntervalSecond) * time.Second
+	if interval <= 0 {
+		return nil, errors.Errorf("invalid busy state interval[%d]", conf.IntervalSecond)
+	}
+	concurrency := conf.ProbeConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &Tracker{
+		source:      conf.Source,
+		probePort:   conf.ProbePort,
+		probePath:   conf.ProbePath,
+		concurrency: concurrency,
+		httpClient:  &http.Client{Timeout: time.Duration(conf.ProbeTimeoutMillisecond) * time.Millisecond},
+		ttl:         time.Duration(conf.PushTTLSecond) * time.Second,
+		interval:    interval,
+		pushed:      map[string]State{},
+	}, nil
+}
+
+// Source 任务状态来源
+func (t *Tracker) Source() string {
+	return t.source
+}
+
+// Interval 更新 pod-deletion-cost 注解的间隔
+func (t *Tracker) Interval() time.Duration {
+	return t.interval
+}
+
+// Report 记录 worker 上报的任务状态
+func (t *Tracker) Report(pod string, state State) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pushed[pod] = state
+}
+
+// States 获取 pod 的任务状态，状态未知（探测失败、未上报或上报已过期）的 pod 不在结果中
+func (t *Tracker) States(ctx context.Context, pods []*corev1.Pod, now time.Time) map[string]State {
+	if t.source == SourcePush {
+		return t.pushedStates(pods, now)
+	}
+	return t.probeStates(ctx, pods, now)
+}
+
+func (t *Tracker) pushedStates(pods []*corev1.Pod, now time.Time) map[string]State {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// 清理已过期的上报
+	for name, state := range t.pushed {
+		if now.Sub(state.ReportedAt) > t.ttl {
+			delete(t.pushed, name)
+		}
+	}
+	states := make(map[string]State, len(pods))
+	for _, pod := range pods {
+		if state, ok := t.pushed[pod.Name]; ok {
+			states[pod.Name] = state
+		}
+	}
+	return states
+}
+
+func (t *Tracker) probeStates(ctx context.Context, pods []*corev1.Pod, now time.Time) map[string]State {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		states = make(map[string]State, len(pods))
+		sem    = make(chan struct{}, t.concurrency)
+	)
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(pod *corev1.Pod) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			state, err := t.probe(ctx, pod.Status.PodIP)
+			if err != nil {
+				logger.Warnf("Probe busy state of pod[%s] err: %v", pod.Name, err)
+				return
+			}
+			state.ReportedAt = now
+			mu.Lock()
+			states[pod.Name] = *state
+			mu.Unlock()
+		}(pod)
+	}
+	wg.Wait()
+	return states
+}
+
+// probe 请求 pod 的任务状态接口
+func (t *Tracker) probe(ctx context.Context, podIP string) (*State, error) {
+	url := fmt.Sprintf("http://%s%s", net.JoinHostPort(podIP, strconv.Itoa(t.probePort)), t.probePath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "new request err")
+	}
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "request %s err", url)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("request %s status %d", url, resp.StatusCode)
+	}
+	state := &State{}
+	if err = json.NewDecoder(resp.Body).Decode(state); err != nil {
+		return nil, errors.Wrapf(err, "decode response of %s err", url)
+	}
+	return state, nil
+}
@@ -0,0 +1,84 @@
+package busystate
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+)
+
+func newTestPod(name, ip string, phase corev1.PodPhase) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Status:     corev1.PodStatus{Phase: phase, PodIP: ip},
+	}
+}
+
+func TestTracker_probe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/busy" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"busy": true, "activeTasks": 2, "taskStartedAt": "2021-10-01T08:00:00Z"}`))
+	}))
+	defer srv.Close()
+	_, portStr, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+
+	tracker, err := NewTracker(&config.BusyStateConf{Source: SourceProbe, ProbePort: port, ProbePath: "/busy",
+		ProbeTimeoutMillisecond: 500, ProbeConcurrency: 2, IntervalSecond: 15})
+	if err != nil {
+		t.Fatalf("NewTracker() err: %+v", err)
+	}
+	now := time.Now()
+	states := tracker.States(context.Background(), []*corev1.Pod{
+		newTestPod("busy", "127.0.0.1", corev1.PodRunning),
+		// 未运行、或接口不可达的 pod 状态未知
+		newTestPod("pending", "", corev1.PodPending),
+		newTestPod("unreachable", "127.0.0.2", corev1.PodRunning),
+	}, now)
+	state, ok := states["busy"]
+	if len(states) != 1 || !ok || !state.Busy || state.ActiveTasks != 2 || state.TaskStartedAt == nil ||
+		!state.ReportedAt.Equal(now) {
+		t.Errorf("States() = %+v", states)
+	}
+}
+
+func TestTracker_push(t *testing.T) {
+	tracker, err := NewTracker(&config.BusyStateConf{Source: SourcePush, PushTTLSecond: 60, IntervalSecond: 15})
+	if err != nil {
+		t.Fatalf("NewTracker() err: %+v", err)
+	}
+	now := time.Now()
+	tracker.Report("worker-0", State{Busy: true, ActiveTasks: 1, ReportedAt: now})
+	tracker.Report("worker-1", State{ReportedAt: now.Add(-2 * time.Minute)})
+	pods := []*corev1.Pod{newTestPod("worker-0", "", corev1.PodRunning), newTestPod("worker-1", "", corev1.PodRunning)}
+
+	// 过期的上报视为状态未知
+	states := tracker.States(context.Background(), pods, now)
+	if len(states) != 1 || !states["worker-0"].Busy {
+		t.Errorf("States() = %+v", states)
+	}
+}
+
+func TestNewTracker_invalid(t *testing.T) {
+	for _, conf := range []config.BusyStateConf{
+		{Source: "other", IntervalSecond: 15},
+		{Source: SourceProbe, ProbePort: 0, IntervalSecond: 15},
+		{Source: SourcePush, PushTTLSecond: 60},
+		{Source: SourcePush, PushTTLSecond: 0, IntervalSecond: 15},
+	} {
+		if _, err := NewTracker(&conf); err == nil {
+			t.Errorf("NewTracker(%+v) should fail", conf)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/pkg/errors"
@@ -11,11 +12,18 @@ type Config struct {
 	// CCE 集群元信息
 	ClusterId string `ini:"cluster_id"`
 	//ClusterName        string       `ini:"cluster_name"`
-	SyncInstanceToVega bool         `ini:"sync_instance_to_vega"`
-	LogConf            LogConf      `ini:"log"`
-	ObsConf            ObsConf      `ini:"obs"`
-	StrategyConf       StrategyConf `ini:"strategy"`
-	K8sConf            K8sConf      `ini:"k8s"`
+	SyncInstanceToVega bool           `ini:"sync_instance_to_vega"`
+	LogConf            LogConf        `ini:"log"`
+	ObsConf            ObsConf        `ini:"obs"`
+	NodeFilterConf     NodeFilterConf `ini:"node_filter"`
+	StrategyConf       StrategyConf   `ini:"strategy"`
+	K8sConf            K8sConf        `ini:"k8s"`
+	ServerConf         ServerConf     `ini:"server"`
+	LoadConf           LoadConf       `ini:"load"`
+	PredictConf        PredictConf    `ini:"predict"`
+	PrometheusConf     PrometheusConf `ini:"prometheus"`
+	GRMConf            GRMConf        `ini:"grm"`
+	BusyStateConf      BusyStateConf  `ini:"busy_state"`
 }
 
 // LogConf log相关配置
@@ -38,6 +46,24 @@ type ObsConf struct {
 	// 伸缩策略文件路径
 	ObjectKeyStrategiesTemplate    string `ini:"object_key_strategies_template"`
 	SyncNodeIdsToOBSIntervalMinute int    `ini:"sync_node_ids_to_obs_interval_minute"`
+	// 节点变化后等待合并的时间（秒），期间的多次变化只上传一次；SyncNodeIdsToOBSIntervalMinute 为定期兜底同步的间隔
+	SyncDebounceSecond int `ini:"sync_debounce_second"`
+	// 同步给 Vega 的实例信息格式，enum："v1"（旧格式，仅 ClusterId 与 NodeIds）/"v2"（节点详情，Vega 支持后再切换）
+	InventoryFormat string `ini:"inventory_format"`
+}
+
+// NodeFilterConf 同步给 Vega 的节点过滤条件，均为空时同步集群所有节点
+type NodeFilterConf struct {
+	// 节点标签选择器，如 "nodepool in (pool-a,pool-b),!node-role.kubernetes.io/master"
+	LabelSelector string `ini:"label_selector"`
+	// 节点字段选择器，支持 metadata.name 与 spec.unschedulable
+	FieldSelector string `ini:"field_selector"`
+	// 排除带有这些污点的节点，逗号分隔，格式为 key[=value][:effect]，省略 value/effect 时匹配任意值
+	ExcludeTaints string `ini:"exclude_taints"`
+	// 只保留满足这些状况的节点，逗号分隔，格式为 type=status，如 "Ready=True"；节点缺少该状况时排除
+	RequireConditions string `ini:"require_conditions"`
+	// 排除被 cordon（如正在排水）的节点
+	ExcludeUnschedulable bool `ini:"exclude_unschedulable"`
 }
 
 // StrategyConf 扩缩策略相关配置
@@ -46,6 +72,12 @@ type StrategyConf struct {
 	Source string `ini:"source"`
 	// 本地策略文件路径，只有在 Source 为 "local" 时需要
 	LocalPath string `ini:"local_path"`
+	// 策略更新后，校验目标负载副本数收敛的超时时间（秒），为 0 时不校验
+	VerifyTimeoutSecond int `ini:"verify_timeout_second"`
+	// 策略更新历史中每个目标HPA最多保留的记录数
+	HistoryMaxRecords int `ini:"history_max_records"`
+	// 目标HPA从策略中移除时，是否恢复为首次接管前的原始 spec
+	RestoreOnRemoval bool `ini:"restore_on_removal"`
 }
 
 // K8sConf k8s相关配置
@@ -54,6 +86,155 @@ type K8sConf struct {
 	Kubeconfig string `ini:"kubeconfig"`
 }
 
+// ServerConf http server相关配置
+type ServerConf struct {
+	// 监听地址，为空时不启动 http server
+	ListenAddr string `ini:"listen_addr"`
+	// external.metrics.k8s.io 聚合 API 的 https 监听地址，为空时不提供
+	ExternalMetricsListenAddr string `ini:"external_metrics_listen_addr"`
+	// https 证书，为空时使用自签名证书
+	TLSCertFile string `ini:"tls_cert_file"`
+	TLSKeyFile  string `ini:"tls_key_file"`
+	// 校验 kube-aggregator 客户端证书的 CA（requestheader-client-ca），提供聚合 API 时必须配置
+	ClientCAFile string `ini:"client_ca_file"`
+	// 为 true 时允许不配置 client CA，不校验客户端证书，任何能访问该端口的客户端都可读取指标，仅用于测试
+	ExternalMetricsInsecure bool `ini:"external_metrics_insecure"`
+	// 管理接口写操作（POST）的认证 token，请求头为 "Authorization: Bearer <token>"；为空时读取环境变量 admin_token，
+	// 仍为空时只接受本机请求
+	AdminToken string `ini:"admin_token"`
+}
+
+// String 打印配置时隐藏管理 token
+func (c ServerConf) String() string {
+	type plain ServerConf
+	c.AdminToken = maskSecret(c.AdminToken)
+	return fmt.Sprintf("%+v", plain(c))
+}
+
+// LoadConf 业务负载（请求量）数据相关配置
+type LoadConf struct {
+	// 负载数据保留时长（小时），按周预测需要超过两周的数据
+	RetentionHour int `ini:"retention_hour"`
+	// 每个任务类型最多保留的数据个数
+	MaxSamplesPerTaskType int `ini:"max_samples_per_task_type"`
+	// 最多保存的任务类型数，达到上限后拒绝新任务类型的数据
+	MaxTaskTypes int `ini:"max_task_types"`
+	// 计算当前请求量时统计的时间窗口（分钟）
+	RateWindowMinute int `ini:"rate_window_minute"`
+}
+
+// PredictConf 根据历史请求量预测并调整 minReplicas 的相关配置
+type PredictConf struct {
+	// 预测模式，enum："off"/"recommend"（仅给出建议）/"apply"（按建议提高策略时间段的 minReplicas）
+	Mode string `ini:"mode"`
+	// 历史数据重采样、预测的时间间隔（分钟，不超过一天），同时也是预测结果的刷新间隔
+	StepMinute int `ini:"step_minute"`
+	// 预测的时长（小时）
+	HorizonHour int `ini:"horizon_hour"`
+	// 单个副本的处理能力（每分钟请求数），用于分流配置中未设置 replicaCapacity 的任务类型，为 0 时忽略这些任务类型
+	ReplicaThroughput float64 `ini:"replica_throughput"`
+	// 计算 minReplicas 时在预测请求量基础上预留的余量倍数
+	Headroom float64 `ini:"headroom"`
+	// Holt-Winters 水平、趋势、季节分量的平滑系数，取值 [0, 1]
+	Alpha float64 `ini:"alpha"`
+	Beta  float64 `ini:"beta"`
+	Gamma float64 `ini:"gamma"`
+}
+
+// PrometheusConf Prometheus 指标源相关配置
+type PrometheusConf struct {
+	// Prometheus HTTP API 地址，eg："http://prometheus:9090"，为空时不使用 Prometheus
+	Endpoint string `ini:"endpoint"`
+	// 单次查询超时时间（秒）
+	TimeoutSecond int `ini:"timeout_second"`
+	// 即时查询结果的缓存时间（秒），为 0 时不缓存
+	CacheTTLSecond int `ini:"cache_ttl_second"`
+	// 认证信息：bearer token（或 token 文件路径），未设置 token 时使用 basic auth
+	BearerToken        string `ini:"bearer_token"`
+	BearerTokenFile    string `ini:"bearer_token_file"`
+	Username           string `ini:"username"`
+	Password           string `ini:"password"`
+	InsecureSkipVerify bool   `ini:"insecure_skip_verify"`
+	// 导入各任务类型请求量的间隔（秒）
+	LoadIntervalSecond int `ini:"load_interval_second"`
+	// 启动时从 Prometheus 补齐的历史请求量时长（小时），为 0 时不补齐
+	LoadBackfillHour int `ini:"load_backfill_hour"`
+	// 各任务类型请求量（每分钟请求数）的 PromQL，来自 [prometheus.load_queries] 分区，key 为任务类型
+	LoadQueries map[string]string `ini:"-"`
+	// 通过 external.metrics.k8s.io 提供给 HPA 的指标，来自 [prometheus.external_metrics] 分区，key 为指标名
+	ExternalMetrics map[string]string `ini:"-"`
+}
+
+// String 打印配置时隐藏认证信息
+func (c PrometheusConf) String() string {
+	type plain PrometheusConf
+	c.BearerToken = maskSecret(c.BearerToken)
+	c.Password = maskSecret(c.Password)
+	return fmt.Sprintf("%+v", plain(c))
+}
+
+// GRMConf 第三方资源管理系统（GRM）相关配置
+type GRMConf struct {
+	// GRM 地址，为空时不申请/释放节点
+	Endpoint string `ini:"endpoint"`
+	// 认证 token，为空时读取环境变量 grm_token
+	Token string `ini:"token"`
+	// 单次请求超时时间（秒）
+	TimeoutSecond int `ini:"timeout_second"`
+	// 网络错误、429 和 5xx 响应的最大重试次数，及首次重试间隔（毫秒，之后按指数退避）
+	MaxRetries               int `ini:"max_retries"`
+	RetryIntervalMillisecond int `ini:"retry_interval_millisecond"`
+	// 提高 minReplicas 时申请的节点的交付超时时间（秒），策略按要求的 minReplicas 立即更新，不等待交付；
+	// 申请中的节点按轮询间隔（秒）跟踪，两者须大于 0
+	AcquireTimeoutSecond int `ini:"acquire_timeout_second"`
+	PollIntervalSecond   int `ini:"poll_interval_second"`
+	// 降低 minReplicas 后延迟释放节点的时间（秒），等待目标负载缩容完成
+	ReleaseDelaySecond int `ini:"release_delay_second"`
+	// 释放节点各步骤的超时时间（秒）：封锁节点、驱逐节点上的 pod 并等待退出、调用 GRM 释放接口，
+	// 超时后解除封锁并放弃本次释放；各步骤按 PollIntervalSecond 轮询。目标负载中正在执行任务的 pod 等任务结束后再驱逐
+	CordonTimeoutSecond  int `ini:"cordon_timeout_second"`
+	DrainTimeoutSecond   int `ini:"drain_timeout_second"`
+	ReleaseTimeoutSecond int `ini:"release_timeout_second"`
+}
+
+// String 打印配置时隐藏认证 token
+func (c GRMConf) String() string {
+	type plain GRMConf
+	c.Token = maskSecret(c.Token)
+	return fmt.Sprintf("%+v", plain(c))
+}
+
+// BusyStateConf 目标负载 pod 任务状态相关配置，用于维护 pod-deletion-cost 注解，缩容时优先删除空闲的 pod
+type BusyStateConf struct {
+	// 任务状态来源，enum：""（不维护）/"probe"（请求各 pod 的 HTTP 接口）/"push"（worker 调用 /api/v1/pods/busy 上报，需携带
+	// 管理接口 token）
+	Source string `ini:"source"`
+	// pod 任务状态接口的端口、路径及超时时间（毫秒），最多同时请求的 pod 数
+	ProbePort               int    `ini:"probe_port"`
+	ProbePath               string `ini:"probe_path"`
+	ProbeTimeoutMillisecond int    `ini:"probe_timeout_millisecond"`
+	ProbeConcurrency        int    `ini:"probe_concurrency"`
+	// 上报的任务状态的有效期（秒），过期后视为状态未知
+	PushTTLSecond int `ini:"push_ttl_second"`
+	// 更新 pod-deletion-cost 注解的间隔（秒），切换到降低副本数的时间段前也会立即更新
+	IntervalSecond int `ini:"interval_second"`
+}
+
+const (
+	prometheusLoadQueriesSection     = "prometheus.load_queries"
+	prometheusExternalMetricsSection = "prometheus.external_metrics"
+)
+
+// maskedSecret 打印配置时替代敏感信息
+const maskedSecret = "******"
+
+func maskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return maskedSecret
+}
+
 // LoadConfig 加载配置文件
 func LoadConfig(configFile string) (*Config, error) {
 	config := GetDefaultConfig()
@@ -74,6 +255,12 @@ func readConfig(configFile string, config *Config) error {
 	if err = conf.MapTo(config); err != nil {
 		return errors.Wrapf(err, "invalid config from file[%s]", configFile)
 	}
+	if section, err := conf.GetSection(prometheusLoadQueriesSection); err == nil {
+		config.PrometheusConf.LoadQueries = section.KeysHash()
+	}
+	if section, err := conf.GetSection(prometheusExternalMetricsSection); err == nil {
+		config.PrometheusConf.ExternalMetrics = section.KeysHash()
+	}
 	return nil
 }
 
@@ -90,5 +277,56 @@ func GetDefaultConfig() *Config {
 			MaxDays:    90,
 			Compress:   true,
 		},
+		StrategyConf: StrategyConf{
+			VerifyTimeoutSecond: 300,
+			HistoryMaxRecords:   100,
+		},
+		ObsConf: ObsConf{
+			SyncDebounceSecond: 10,
+			InventoryFormat:    "v1",
+		},
+		ServerConf: ServerConf{
+			ListenAddr: ":8080",
+		},
+		LoadConf: LoadConf{
+			RetentionHour:         15 * 24,
+			MaxSamplesPerTaskType: 15 * 24 * 60,
+			MaxTaskTypes:          100,
+			RateWindowMinute:      5,
+		},
+		PredictConf: PredictConf{
+			Mode:        "off",
+			StepMinute:  10,
+			HorizonHour: 6,
+			Headroom:    1.2,
+			Alpha:       0.5,
+			Beta:        0.01,
+			Gamma:       0.3,
+		},
+		GRMConf: GRMConf{
+			TimeoutSecond:            10,
+			MaxRetries:               3,
+			RetryIntervalMillisecond: 500,
+			AcquireTimeoutSecond:     600,
+			PollIntervalSecond:       10,
+			ReleaseDelaySecond:       600,
+			CordonTimeoutSecond:      60,
+			DrainTimeoutSecond:       900,
+			ReleaseTimeoutSecond:     300,
+		},
+		BusyStateConf: BusyStateConf{
+			ProbePort:               8080,
+			ProbePath:               "/busy",
+			ProbeTimeoutMillisecond: 1000,
+			ProbeConcurrency:        10,
+			PushTTLSecond:           60,
+			IntervalSecond:          15,
+		},
+		PrometheusConf: PrometheusConf{
+			TimeoutSecond:      10,
+			CacheTTLSecond:     30,
+			LoadIntervalSecond: 60,
+			LoadBackfillHour:   15 * 24,
+		},
 	}
 }
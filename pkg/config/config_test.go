@@ -0,0 +1,23 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestConfig_String(t *testing.T) {
+	conf := GetDefaultConfig()
+	conf.ServerConf.AdminToken = "admin-secret"
+	conf.PrometheusConf.BearerToken, conf.PrometheusConf.Password = "prom-token", "prom-password"
+	conf.GRMConf.Token = "grm-token"
+	got := fmt.Sprintf("%+v", conf)
+	if strings.Contains(got, "admin-secret") || strings.Contains(got, "prom-token") ||
+		strings.Contains(got, "prom-password") || strings.Contains(got, "grm-token") {
+		t.Errorf("config dump contains secrets: %s", got)
+	}
+	if !strings.Contains(got, "AdminToken:"+maskedSecret) || !strings.Contains(got, "ListenAddr::8080") ||
+		!strings.Contains(got, "Password:"+maskedSecret) {
+		t.Errorf("config dump = %s", got)
+	}
+}
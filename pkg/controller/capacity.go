@@ -0,0 +1,294 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	"nanto.io/application-auto-scaling-service/pkg/metrics"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+// 提高 minReplicas 超出集群容量时的处理策略
+const (
+	// CapacityPolicyWarn 仅记录 warning event，仍按策略更新
+	CapacityPolicyWarn = "warn"
+	// CapacityPolicyClamp 将 minReplicas 限制为集群可容纳的副本数
+	CapacityPolicyClamp = "clamp"
+	// CapacityPolicyRequest 向 GRM 申请节点，需配置 resources
+	CapacityPolicyRequest = "request"
+)
+
+// 容量检查后采取的动作
+const (
+	capacityActionNone      = "none"
+	capacityActionWarned    = "warned"
+	capacityActionClamped   = "clamped"
+	capacityActionRequested = "requested"
+)
+
+// CapacityReport 最近一次提高 minReplicas 前的集群容量检查结果
+type CapacityReport struct {
+	Window string `json:"window"`
+	Policy string `json:"policy"`
+	// 策略要求的 minReplicas
+	RequiredReplicas int32 `json:"requiredReplicas"`
+	// 集群可运行的目标负载副本数：已调度的副本数 + 剩余资源可容纳的副本数
+	SchedulableReplicas int32 `json:"schedulableReplicas"`
+	// 容量缺口，为 0 时容量充足
+	Gap int32 `json:"gap"`
+	// 按策略处理后实际更新的 minReplicas
+	AppliedMinReplicas int32     `json:"appliedMinReplicas"`
+	Action             string    `json:"action"`
+	CheckedAt          time.Time `json:"checkedAt"`
+}
+
+// workloadCapacity 目标负载在集群中的容量
+type workloadCapacity struct {
+	// 已调度的副本数
+	Scheduled int32
+	// 各节点剩余资源可再容纳的副本数之和
+	Fits int32
+	// 目标负载可调度的节点
+	Nodes map[string]bool
+}
+
+// Schedulable 集群可运行的目标负载副本数
+func (c *workloadCapacity) Schedulable() int32 {
+	return c.Scheduled + c.Fits
+}
+
+func (s *StrategyController) setCapacityReport(report *CapacityReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.capacityReport = report
+}
+
+// capacityPolicy 当前策略的容量处理策略：未配置时，配置了 resources 则申请节点，否则仅告警
+func (s *StrategyController) capacityPolicy() string {
+	info := s.Strategies()
+	if info == nil {
+		return CapacityPolicyWarn
+	}
+	if info.Capacity != nil && info.Capacity.Policy != "" {
+		return info.Capacity.Policy
+	}
+	if info.Resources != nil {
+		return CapacityPolicyRequest
+	}
+	return CapacityPolicyWarn
+}
+
+// checkCapacity 即将提高 minReplicas 时，计算集群可运行的目标负载副本数，超出时按策略告警、限制或申请节点；
+// 即将降低 minReplicas 时，延迟释放多余的 GRM 节点。计算容量出错时不做处理
+func (s *StrategyController) checkCapacity(chpa *v1alpha1.CustomedHorizontalPodAutoscaler, window string,
+	spec *v1alpha1.CustomedHorizontalPodAutoscalerSpec) {
+	curMin, newMin := utils.Int32Value(chpa.Spec.MinReplicas), utils.Int32Value(spec.MinReplicas)
+	if newMin < curMin {
+		if client, _ := s.resourcesEnabled(); client != nil {
+			s.scheduleRelease(time.Now().Add(time.Duration(s.grmConf.ReleaseDelaySecond) * time.Second))
+		}
+		return
+	}
+	if newMin == curMin {
+		return
+	}
+	s.scheduleRelease(time.Time{})
+
+	ctx := context.Background()
+	capacity, err := computeCapacity(ctx, chpa.Spec.ScaleTargetRef)
+	if err != nil {
+		logger.Errorf("Compute cluster capacity of customHPA[%s] err, capacity check skipped: %+v", chpa.Name, err)
+		return
+	}
+	policy := s.capacityPolicy()
+	report := &CapacityReport{
+		Window:              window,
+		Policy:              policy,
+		RequiredReplicas:    newMin,
+		SchedulableReplicas: capacity.Schedulable(),
+		AppliedMinReplicas:  newMin,
+		Action:              capacityActionNone,
+		CheckedAt:           time.Now(),
+	}
+	if newMin > report.SchedulableReplicas {
+		report.Gap = newMin - report.SchedulableReplicas
+		switch policy {
+		case CapacityPolicyClamp:
+			keep := report.SchedulableReplicas
+			if keep < curMin {
+				keep = curMin
+			}
+			spec.MinReplicas = utils.Int32Ptr(keep)
+			report.AppliedMinReplicas = keep
+			report.Action = capacityActionClamped
+		case CapacityPolicyRequest:
+			if client, conf := s.resourcesEnabled(); client != nil {
+				s.requestNodes(ctx, client, conf, chpa, window, newMin, capacity)
+				report.Action = capacityActionRequested
+				break
+			}
+			logger.Warnf("Grm or resources is not configured, capacity policy[%s] of customHPA[%s] degrades to warn",
+				policy, chpa.Name)
+			report.Action = capacityActionWarned
+		default:
+			report.Action = capacityActionWarned
+		}
+		logger.Warnf("MinReplicas %d of customHPA[%s] window[%s] exceeds schedulable replicas %d, gap %d, %s",
+			newMin, chpa.Name, window, report.SchedulableReplicas, report.Gap, report.Action)
+		recordEvent(chpa, corev1.EventTypeWarning, EventReasonCapacityShortage,
+			"Window[%s] requires minReplicas %d, cluster can schedule %d, gap %d, policy %s: %s, apply minReplicas %d",
+			window, newMin, report.SchedulableReplicas, report.Gap, policy, report.Action, report.AppliedMinReplicas)
+	}
+	metrics.CapacityGapReplicas.WithLabelValues(chpa.Name).Set(float64(report.Gap))
+	s.setCapacityReport(report)
+}
+
+// computeCapacity 根据目标负载 pod 的资源 request、nodeSelector 和容忍，以及各节点 allocatable、
+// 已有 pod 的 request 和污点，计算目标负载在集群中的容量。未考虑节点亲和性和 pod 反亲和性
+func computeCapacity(ctx context.Context, ref v1alpha1.ScaleTargetRef) (*workloadCapacity, error) {
+	w, err := getWorkloadReplicas(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	selector, err := metav1.LabelSelectorAsSelector(w.Selector)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid workload selector")
+	}
+	kubeCli := k8sclient.GetKubeClientSet()
+	nodes, err := kubeCli.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "list nodes err")
+	}
+	pods, err := kubeCli.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "list pods err")
+	}
+
+	capacity := &workloadCapacity{Nodes: map[string]bool{}}
+	podsByNode := map[string][]*corev1.Pod{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		podsByNode[pod.Spec.NodeName] = append(podsByNode[pod.Spec.NodeName], pod)
+		if pod.Namespace == NamespaceDefault && selector.Matches(labels.Set(pod.Labels)) {
+			capacity.Scheduled++
+		}
+	}
+
+	request := podRequests(&w.Template.Spec)
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if !isNodeSchedulable(node) || !matchesNodeSelector(node, w.Template.Spec.NodeSelector) ||
+			!toleratesTaints(w.Template.Spec.Tolerations, node.Spec.Taints) {
+			continue
+		}
+		capacity.Nodes[node.Name] = true
+		capacity.Fits += nodeFits(node, podsByNode[node.Name], request)
+	}
+	return capacity, nil
+}
+
+// nodeFits 节点剩余资源可容纳的 pod 数，受 cpu、内存和 pod 数限制
+func nodeFits(node *corev1.Node, pods []*corev1.Pod, request corev1.ResourceList) int32 {
+	allocatable := node.Status.Allocatable
+	fits := int64(-1)
+	if maxPods, ok := allocatable[corev1.ResourcePods]; ok {
+		fits = maxPods.Value() - int64(len(pods))
+	}
+	used := corev1.ResourceList{}
+	for _, pod := range pods {
+		addResources(used, podRequests(&pod.Spec))
+	}
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		req, ok := request[name]
+		if !ok || req.IsZero() {
+			continue
+		}
+		total := allocatable[name]
+		usedQuantity := used[name]
+		n := (total.MilliValue() - usedQuantity.MilliValue()) / req.MilliValue()
+		if fits < 0 || n < fits {
+			fits = n
+		}
+	}
+	if fits < 0 {
+		return 0
+	}
+	return int32(fits)
+}
+
+// podRequests pod 的资源 request：各容器 request 之和，与各 init 容器 request 的较大值
+func podRequests(spec *corev1.PodSpec) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, c := range spec.Containers {
+		addResources(requests, c.Resources.Requests)
+	}
+	for _, c := range spec.InitContainers {
+		for name, quantity := range c.Resources.Requests {
+			if cur, ok := requests[name]; !ok || quantity.Cmp(cur) > 0 {
+				requests[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	return requests
+}
+
+func addResources(total, add corev1.ResourceList) {
+	for name, quantity := range add {
+		cur, ok := total[name]
+		if !ok {
+			cur = resource.Quantity{}
+		}
+		cur.Add(quantity)
+		total[name] = cur
+	}
+}
+
+// matchesNodeSelector 节点标签满足 pod 模板的 nodeSelector
+func matchesNodeSelector(node *corev1.Node, nodeSelector map[string]string) bool {
+	return labels.SelectorFromSet(nodeSelector).Matches(labels.Set(node.Labels))
+}
+
+// toleratesTaints pod 模板容忍节点上所有 NoSchedule、NoExecute 污点
+func toleratesTaints(tolerations []corev1.Toleration, taints []corev1.Taint) bool {
+	for i := range taints {
+		taint := &taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for j := range tolerations {
+			if tolerations[j].ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
+
+// isNodeSchedulable 节点 Ready 且未被标记为不可调度
+func isNodeSchedulable(node *corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/loadstore"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+	"nanto.io/application-auto-scaling-service/pkg/utils/cronutil"
+)
+
+func newTestScheduledPod(name, namespace, nodeName, cpu string, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Spec: corev1.PodSpec{NodeName: nodeName, Containers: []corev1.Container{{Name: "c",
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+				corev1.ResourceCPU: resource.MustParse(cpu),
+			}}}}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func Test_computeCapacity(t *testing.T) {
+	d := newTestCapacityDeployment(1)
+	d.Spec.Template.Spec.NodeSelector = map[string]string{"pool": "worker"}
+	d.Spec.Template.Spec.Tolerations = []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual,
+		Value: "worker", Effect: corev1.TaintEffectNoSchedule}}
+	// init 容器 request 较大时按 init 容器计算：每个副本 2 核
+	bigInit := d.DeepCopy()
+	bigInit.Spec.Template.Spec.InitContainers = []corev1.Container{{Name: "init", Resources: corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+	}}}
+
+	tolerated := newTestNode("node-tolerated", true)
+	tolerated.Spec.Taints = []corev1.Taint{{Key: "dedicated", Value: "worker", Effect: corev1.TaintEffectNoSchedule}}
+	tainted := newTestNode("node-tainted", true)
+	tainted.Spec.Taints = []corev1.Taint{{Key: "gpu", Effect: corev1.TaintEffectNoSchedule}}
+	preferred := newTestNode("node-preferred", true)
+	preferred.Spec.Taints = []corev1.Taint{{Key: "gpu", Effect: corev1.TaintEffectPreferNoSchedule}}
+	otherPool := newTestNode("node-other", true)
+	otherPool.Labels = map[string]string{"pool": "other"}
+	cordoned := newTestNode("node-cordoned", true)
+	cordoned.Spec.Unschedulable = true
+	objs := []runtime.Object{
+		newTestNode("node-1", true), tolerated, tainted, preferred, otherPool, cordoned,
+		// node-1 上已运行 1 个目标负载副本（1 核），剩余 1 核
+		newTestScheduledPod("worker-1", NamespaceDefault, "node-1", "1", map[string]string{"app": "worker"}),
+		// node-preferred 上其他 pod 占用 1.5 核，剩余 0.5 核
+		newTestScheduledPod("other-1", "kube-system", "node-preferred", "1500m", nil),
+	}
+
+	setupFakeClientSet(append(objs, d))
+	capacity, err := computeCapacity(context.Background(), newTestCustomedHPA("customedhpa01", nil).Spec.ScaleTargetRef)
+	if err != nil {
+		t.Fatalf("computeCapacity() err: %+v", err)
+	}
+	// node-1 剩余 1 个，node-tolerated 2 个，node-preferred 0 个
+	if capacity.Scheduled != 1 || capacity.Fits != 3 || len(capacity.Nodes) != 3 {
+		t.Errorf("computeCapacity() = %+v, want scheduled 1, fits 3, 3 nodes", capacity)
+	}
+
+	setupFakeClientSet(append(objs, bigInit))
+	capacity, err = computeCapacity(context.Background(), newTestCustomedHPA("customedhpa01", nil).Spec.ScaleTargetRef)
+	if err != nil || capacity.Fits != 1 {
+		t.Errorf("computeCapacity() with init container = %+v, err: %v, want fits 1", capacity, err)
+	}
+}
+
+func TestStrategyController_checkCapacity(t *testing.T) {
+	chpa := newTestCustomedHPA("customedhpa01", nil)
+	chpa.Spec.MinReplicas = utils.Int32Ptr(2)
+	cronutil.InitCron()
+	defer cronutil.GetCron().Stop()
+	tests := []struct {
+		name       string
+		policy     string
+		newMin     int32
+		wantMin    int32
+		wantGap    int32
+		wantAction string
+	}{
+		{"enough capacity", CapacityPolicyClamp, 4, 4, 0, capacityActionNone},
+		{"warn", CapacityPolicyWarn, 7, 7, 3, capacityActionWarned},
+		{"clamp", CapacityPolicyClamp, 7, 4, 3, capacityActionClamped},
+		// 未配置 GRM 时退化为告警
+		{"request without grm", CapacityPolicyRequest, 7, 7, 3, capacityActionWarned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := setupFakeClientSet([]runtime.Object{
+				newTestNode("node-1", true), newTestNode("node-2", true), newTestCapacityDeployment(0),
+			}, chpa)
+			s := &StrategyController{targetHPA: "customedhpa01", strategiesInfo: &StrategiesInfo{
+				TargetHPA: "customedhpa01", Capacity: &CapacityConf{Policy: tt.policy},
+			}}
+			spec := newTestSpec(tt.newMin, 10)
+			s.checkCapacity(chpa, "8:00-9:00", &spec)
+
+			report := s.capacityReport
+			if got := utils.Int32Value(spec.MinReplicas); got != tt.wantMin || report == nil ||
+				report.Gap != tt.wantGap || report.Action != tt.wantAction || report.SchedulableReplicas != 4 {
+				t.Errorf("checkCapacity() minReplicas = %d, report = %+v", got, report)
+			}
+			if got := hasEvent(drainEvents(recorder), EventReasonCapacityShortage); got != (tt.wantGap > 0) {
+				t.Errorf("checkCapacity() event %s = %v", EventReasonCapacityShortage, got)
+			}
+
+			target, err := s.Target("customedhpa01")
+			if err != nil || target.Capacity != report {
+				t.Errorf("Target() capacity = %+v, err: %v", target, err)
+			}
+		})
+	}
+}
+
+// apply 模式下容量不足被限制的时间段，预测刷新时不重复执行
+func TestStrategyController_checkCapacity_clampWithPredictApply(t *testing.T) {
+	chpa := newTestCustomedHPA("customedhpa01", nil)
+	chpa.Spec.MinReplicas = utils.Int32Ptr(2)
+	recorder := setupFakeClientSet([]runtime.Object{
+		newTestNode("node-1", true), newTestNode("node-2", true), newTestCapacityDeployment(0),
+	}, chpa)
+	cronutil.InitCron()
+	defer cronutil.GetCron().Stop()
+
+	// 两个节点可容纳 4 个副本，各时间段要求 minReplicas 7
+	path := filepath.Join(t.TempDir(), "local-strategies.yaml")
+	strategies := `targetHPA: customedhpa01
+capacity:
+  policy: clamp
+strategies:
+  - validTime: "0:00-12:00"
+    spec: {minReplicas: 7, maxReplicas: 10}
+  - validTime: "12:00-24:00"
+    spec: {minReplicas: 7, maxReplicas: 10}
+`
+	if err := ioutil.WriteFile(path, []byte(strategies), 0644); err != nil {
+		t.Fatalf("write strategies err: %v", err)
+	}
+	// 没有负载数据时预测建议值即为时间段配置的 minReplicas
+	s := &StrategyController{LocalPath: path, history: NewHistoryStore(0),
+		loadStore: loadstore.NewStore(time.Hour, 10, 0), predictConf: &config.PredictConf{
+			Mode: PredictModeApply, StepMinute: 10, HorizonHour: 6, Headroom: 1, Alpha: 0.5, Beta: 0.01, Gamma: 0.3}}
+	if err := s.execLocalStrategies(); err != nil {
+		t.Fatalf("execLocalStrategies() err: %+v", err)
+	}
+	if got := utils.Int32Value(getTestCustomedHPA(t, "customedhpa01").Spec.MinReplicas); got != 4 ||
+		s.capacityReport == nil || s.capacityReport.Action != capacityActionClamped {
+		t.Fatalf("minReplicas = %d, report = %+v, want clamped to 4", got, s.capacityReport)
+	}
+	drainEvents(recorder)
+
+	for i := 0; i < 2; i++ {
+		s.refreshPrediction(time.Now())
+		if hasEvent(drainEvents(recorder), EventReasonStrategyApplied) {
+			t.Errorf("refreshPrediction() re-applied the clamped window")
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"nanto.io/application-auto-scaling-service/pkg/busystate"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	"nanto.io/application-auto-scaling-service/pkg/metrics"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+// AnnotationPodDeletionCost ReplicaSet 缩容时优先删除该值较小的 pod
+const AnnotationPodDeletionCost = "controller.kubernetes.io/pod-deletion-cost"
+
+// pod-deletion-cost 取值：空闲的 pod 最先删除，其次是状态未知的 pod；执行任务的 pod 按任务已执行的小时数递增，
+// 执行长任务的 pod 最后删除。按小时取整，避免注解频繁变化导致反复 patch pod
+const (
+	deletionCostIdle    = 0
+	deletionCostUnknown = 100
+	deletionCostBusy    = 1000
+	// deletionCostMax 任务执行时长折算的上限，约 1 年
+	deletionCostMax = deletionCostBusy + 24*365
+)
+
+// deletionCost 根据 pod 的任务状态计算 pod-deletion-cost
+func deletionCost(state busystate.State, known bool, now time.Time) int {
+	if !known {
+		return deletionCostUnknown
+	}
+	if !state.Busy && state.ActiveTasks == 0 {
+		return deletionCostIdle
+	}
+	cost := deletionCostBusy
+	if state.TaskStartedAt != nil && now.After(*state.TaskStartedAt) {
+		cost += int(now.Sub(*state.TaskStartedAt) / time.Hour)
+	}
+	if cost > deletionCostMax {
+		cost = deletionCostMax
+	}
+	return cost
+}
+
+// refreshDeletionCosts 按目标负载各 pod 的任务状态更新 pod-deletion-cost 注解，未配置任务状态来源时不处理
+func (s *StrategyController) refreshDeletionCosts(chpa *v1alpha1.CustomedHorizontalPodAutoscaler, now time.Time) {
+	tracker := busystate.GetTracker()
+	if tracker == nil {
+		return
+	}
+	ctx := context.Background()
+	pods, err := s.listWorkloadPods(ctx, chpa.Spec.ScaleTargetRef)
+	if err != nil {
+		logger.Errorf("List pods of customHPA[%s] err, skip updating deletion cost: %+v", chpa.Name, err)
+		return
+	}
+	states := tracker.States(ctx, pods, now)
+	var busy, updated int
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		state, known := states[pod.Name]
+		cost := deletionCost(state, known, now)
+		if cost >= deletionCostBusy {
+			busy++
+		}
+		if pod.Annotations[AnnotationPodDeletionCost] == strconv.Itoa(cost) {
+			continue
+		}
+		if err = patchDeletionCost(ctx, pod, cost); err != nil {
+			logger.Warnf("Update deletion cost of pod[%s] err: %+v", pod.Name, err)
+			continue
+		}
+		updated++
+	}
+	metrics.BusyPods.WithLabelValues(chpa.Name).Set(float64(busy))
+	if updated > 0 {
+		logger.Infof("Updated deletion cost of %d pods of customHPA[%s], busy pods: %d, known states: %d/%d",
+			updated, chpa.Name, busy, len(states), len(pods))
+	}
+}
+
+// refreshTargetDeletionCosts 定期更新目标HPA的 pod-deletion-cost 注解
+func (s *StrategyController) refreshTargetDeletionCosts(now time.Time) {
+	chpa, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
+		Get(context.Background(), s.target(), metav1.GetOptions{})
+	if err != nil {
+		logger.Errorf("Get current customHPA err: %v", err)
+		return
+	}
+	s.refreshDeletionCosts(chpa, now)
+}
+
+// protectBusyPods 切换到降低副本数的时间段前立即更新 pod-deletion-cost，使缩容优先删除空闲的 pod
+func (s *StrategyController) protectBusyPods(chpa *v1alpha1.CustomedHorizontalPodAutoscaler,
+	spec *v1alpha1.CustomedHorizontalPodAutoscalerSpec) {
+	if utils.Int32Value(spec.MinReplicas) >= utils.Int32Value(chpa.Spec.MinReplicas) &&
+		utils.Int32Value(spec.MaxReplicas) >= utils.Int32Value(chpa.Spec.MaxReplicas) {
+		return
+	}
+	s.refreshDeletionCosts(chpa, time.Now())
+}
+
+func patchDeletionCost(ctx context.Context, pod *corev1.Pod, cost int) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{AnnotationPodDeletionCost: strconv.Itoa(cost)},
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "marshal patch err")
+	}
+	_, err = k8sclient.GetKubeClientSet().CoreV1().Pods(pod.Namespace).
+		Patch(ctx, pod.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return errors.Wrap(err, "patch pod err")
+}
+
+// listWorkloadPods 目标负载的 pod，启动了 pod informer 时从缓存中读取
+func (s *StrategyController) listWorkloadPods(ctx context.Context, ref v1alpha1.ScaleTargetRef) ([]*corev1.Pod,
+	error) {
+	w, err := getWorkloadReplicas(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	selector, err := metav1.LabelSelectorAsSelector(w.Selector)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid workload selector")
+	}
+	s.mu.Lock()
+	podLister := s.podLister
+	s.mu.Unlock()
+	if podLister != nil {
+		pods, err := podLister.Pods(NamespaceDefault).List(selector)
+		return pods, errors.Wrap(err, "list pods from informer err")
+	}
+	list, err := k8sclient.GetKubeClientSet().CoreV1().Pods(NamespaceDefault).
+		List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, errors.Wrap(err, "list pods err")
+	}
+	pods := make([]*corev1.Pod, 0, len(list.Items))
+	for i := range list.Items {
+		pods = append(pods, &list.Items[i])
+	}
+	return pods, nil
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"nanto.io/application-auto-scaling-service/pkg/busystate"
+	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+func Test_deletionCost(t *testing.T) {
+	now := time.Now()
+	started := now.Add(-90 * time.Minute)
+	tests := []struct {
+		name  string
+		state busystate.State
+		known bool
+		want  int
+	}{
+		{"unknown", busystate.State{}, false, deletionCostUnknown},
+		{"idle", busystate.State{}, true, deletionCostIdle},
+		{"busy without start time", busystate.State{Busy: true}, true, deletionCostBusy},
+		{"long running task", busystate.State{Busy: true, ActiveTasks: 1, TaskStartedAt: &started}, true,
+			deletionCostBusy + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deletionCost(tt.state, tt.known, now); got != tt.want {
+				t.Errorf("deletionCost() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrategyController_protectBusyPods(t *testing.T) {
+	tracker, err := busystate.NewTracker(&config.BusyStateConf{Source: busystate.SourcePush, PushTTLSecond: 60,
+		IntervalSecond: 15})
+	if err != nil {
+		t.Fatalf("NewTracker() err: %+v", err)
+	}
+	busystate.SetTracker(tracker)
+	defer busystate.SetTracker(nil)
+
+	now := time.Now()
+	started := now.Add(-30 * time.Minute)
+	tracker.Report("worker-0", busystate.State{Busy: true, ActiveTasks: 1, TaskStartedAt: &started, ReportedAt: now})
+	tracker.Report("worker-1", busystate.State{ReportedAt: now})
+	chpa := newTestCustomedHPA("customedhpa01", nil)
+	chpa.Spec.MinReplicas, chpa.Spec.MaxReplicas = utils.Int32Ptr(3), utils.Int32Ptr(6)
+	setupFakeClientSet([]runtime.Object{
+		newTestDeployment(3, 3),
+		newTestPod("worker-0", corev1.PodRunning, false),
+		newTestPod("worker-1", corev1.PodRunning, false),
+		newTestPod("worker-2", corev1.PodRunning, false),
+	}, chpa)
+	s := &StrategyController{targetHPA: "customedhpa01"}
+
+	// 提高副本数时不更新
+	spec := newTestSpec(4, 8)
+	s.protectBusyPods(chpa, &spec)
+	if cost := getTestDeletionCost(t, "worker-0"); cost != "" {
+		t.Errorf("protectBusyPods() on scale up updated deletion cost: %s", cost)
+	}
+
+	spec = newTestSpec(1, 6)
+	s.protectBusyPods(chpa, &spec)
+	for pod, want := range map[string]string{"worker-0": "1000", "worker-1": "0", "worker-2": "100"} {
+		if cost := getTestDeletionCost(t, pod); cost != want {
+			t.Errorf("pod[%s] deletion cost = %s, want %s", pod, cost, want)
+		}
+	}
+}
+
+func getTestDeletionCost(t *testing.T, name string) string {
+	pod, err := k8sclient.GetKubeClientSet().CoreV1().Pods(NamespaceDefault).
+		Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get pod[%s] err: %v", name, err)
+	}
+	return pod.Annotations[AnnotationPodDeletionCost]
+}
@@ -0,0 +1,539 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/retry"
+
+	"nanto.io/application-auto-scaling-service/pkg/busystate"
+	"nanto.io/application-auto-scaling-service/pkg/grm"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	"nanto.io/application-auto-scaling-service/pkg/metrics"
+)
+
+// AnnotationCordonedBy 本服务为释放节点封锁节点时记录的释放流程 ID，回滚时只解除带有该注解的封锁
+const AnnotationCordonedBy = "aass.nanto.io/cordoned-by"
+
+// 释放节点流程的阶段
+const (
+	NodeReleaseCordoning  = "Cordoning"
+	NodeReleaseDraining   = "Draining"
+	NodeReleaseReleasing  = "Releasing"
+	NodeReleaseSucceeded  = "Succeeded"
+	NodeReleaseRolledBack = "RolledBack"
+)
+
+const (
+	// nodeReleaseHistorySize 保留的释放流程记录数
+	nodeReleaseHistorySize = 10
+	// defaultReleasePollInterval 未配置 GRM 轮询间隔时推进释放流程的间隔
+	defaultReleasePollInterval = 10 * time.Second
+)
+
+// ErrNoNodeRelease 没有进行中的释放节点流程
+var ErrNoNodeRelease = errors.New("no node release in progress")
+
+// ReleaseNode 释放流程中的节点
+type ReleaseNode struct {
+	Name         string `json:"name"`
+	AllocationID string `json:"allocationId"`
+	// 节点上尚未退出的 pod 数（不含 DaemonSet 和静态 pod）
+	RemainingPods int  `json:"remainingPods"`
+	Released      bool `json:"released"`
+}
+
+// NodeRelease 释放节点流程：封锁节点 -> 驱逐 pod（遵守 PodDisruptionBudget）并等待退出 -> 调用 GRM 释放接口，
+// 任一步骤失败超时或被取消时解除封锁
+type NodeRelease struct {
+	ID      string        `json:"id"`
+	Phase   string        `json:"phase"`
+	Message string        `json:"message,omitempty"`
+	Nodes   []ReleaseNode `json:"nodes"`
+	// 发起释放时的所需副本数及集群可容纳的副本数
+	RequiredReplicas int32      `json:"requiredReplicas"`
+	Capacity         int32      `json:"capacity"`
+	StartedAt        time.Time  `json:"startedAt"`
+	PhaseStartedAt   time.Time  `json:"phaseStartedAt"`
+	FinishedAt       *time.Time `json:"finishedAt,omitempty"`
+}
+
+func (r *NodeRelease) finished() bool {
+	return r.Phase == NodeReleaseSucceeded || r.Phase == NodeReleaseRolledBack
+}
+
+func (r *NodeRelease) nodeNames() []string {
+	names := make([]string, 0, len(r.Nodes))
+	for _, node := range r.Nodes {
+		names = append(names, node.Name)
+	}
+	return names
+}
+
+func (r *NodeRelease) copy() *NodeRelease {
+	c := *r
+	c.Nodes = append([]ReleaseNode(nil), r.Nodes...)
+	return &c
+}
+
+// activeNodeRelease 进行中的释放流程，流程状态只在持有 releaseMu 时修改
+func (s *StrategyController) activeNodeRelease() *NodeRelease {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n := len(s.nodeReleases); n > 0 && !s.nodeReleases[n-1].finished() {
+		return s.nodeReleases[n-1]
+	}
+	return nil
+}
+
+// updateNodeRelease 修改释放流程状态，与读取状态的管理接口互斥
+func (s *StrategyController) updateNodeRelease(r *NodeRelease, update func(r *NodeRelease)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	update(r)
+}
+
+// latestNodeRelease 最近一次释放流程
+func (s *StrategyController) latestNodeRelease() *NodeRelease {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n := len(s.nodeReleases); n > 0 {
+		return s.nodeReleases[n-1].copy()
+	}
+	return nil
+}
+
+// NodeReleases 获取目标HPA最近的释放节点流程，按开始时间升序
+func (s *StrategyController) NodeReleases(target string) ([]NodeRelease, error) {
+	if err := s.checkTarget(target); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	releases := make([]NodeRelease, 0, len(s.nodeReleases))
+	for _, r := range s.nodeReleases {
+		releases = append(releases, *r.copy())
+	}
+	return releases, nil
+}
+
+// CancelNodeRelease 取消进行中的释放节点流程，解除尚未释放节点的封锁
+func (s *StrategyController) CancelNodeRelease(target string) (*NodeRelease, error) {
+	if err := s.checkTarget(target); err != nil {
+		return nil, err
+	}
+	s.releaseMu.Lock()
+	defer s.releaseMu.Unlock()
+	r := s.activeNodeRelease()
+	if r == nil {
+		return nil, errors.Wrapf(ErrNoNodeRelease, "customHPA[%s]", target)
+	}
+	chpa, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
+		Get(context.Background(), target, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "get customHPA[%s] err", target)
+	}
+	logger.Infof("Cancel node release[%s] of customHPA[%s] by request", r.ID, target)
+	s.rollbackNodeRelease(context.Background(), chpa, r, "canceled by request", time.Now(), false)
+	return s.latestNodeRelease(), nil
+}
+
+// startNodeRelease 开始释放选出的节点
+func (s *StrategyController) startNodeRelease(chpa *v1alpha1.CustomedHorizontalPodAutoscaler, nodes []ReleaseNode,
+	required, capacity int32, now time.Time) {
+	r := &NodeRelease{
+		ID:               fmt.Sprintf("%s-%d", chpa.Name, now.Unix()),
+		Phase:            NodeReleaseCordoning,
+		Nodes:            nodes,
+		RequiredReplicas: required,
+		Capacity:         capacity,
+		StartedAt:        now,
+		PhaseStartedAt:   now,
+	}
+	s.mu.Lock()
+	s.nodeReleases = append(s.nodeReleases, r)
+	if len(s.nodeReleases) > nodeReleaseHistorySize {
+		s.nodeReleases = s.nodeReleases[len(s.nodeReleases)-nodeReleaseHistorySize:]
+	}
+	s.mu.Unlock()
+	logger.Infof("Start node release[%s] of customHPA[%s], nodes: %v, required replicas %d, capacity %d", r.ID,
+		chpa.Name, r.nodeNames(), required, capacity)
+	recordEvent(chpa, corev1.EventTypeNormal, EventReasonNodeReleaseStarted,
+		"Release %d nodes %v, required replicas %d, capacity %d, release[%s]", len(nodes), r.nodeNames(), required,
+		capacity, r.ID)
+}
+
+// advanceNodeRelease 推进进行中的释放流程，当前步骤未完成时等待下次轮询，超时后回滚
+func (s *StrategyController) advanceNodeRelease(now time.Time) {
+	s.releaseMu.Lock()
+	defer s.releaseMu.Unlock()
+	r := s.activeNodeRelease()
+	if r == nil {
+		return
+	}
+	ctx := context.Background()
+	chpa, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
+		Get(ctx, s.target(), metav1.GetOptions{})
+	if err != nil {
+		logger.Errorf("Get current customHPA err: %v", err)
+		return
+	}
+	client, _ := s.resourcesEnabled()
+	if client == nil {
+		s.rollbackNodeRelease(ctx, chpa, r, "grm resources are disabled", now, false)
+		return
+	}
+
+	switch r.Phase {
+	case NodeReleaseCordoning:
+		if err = s.cordonNodes(ctx, r); err == nil {
+			logger.Infof("Nodes %v of release[%s] cordoned, start draining", r.nodeNames(), r.ID)
+			recordEvent(chpa, corev1.EventTypeNormal, EventReasonNodesCordoned,
+				"Cordoned nodes %v, evicting pods, release[%s]", r.nodeNames(), r.ID)
+			s.enterPhase(r, NodeReleaseDraining, now)
+			s.advanceDrain(ctx, chpa, r, now)
+			return
+		}
+		s.checkPhaseTimeout(ctx, chpa, r, now, s.grmConf.CordonTimeoutSecond, "cordon nodes err: "+err.Error())
+	case NodeReleaseDraining:
+		s.advanceDrain(ctx, chpa, r, now)
+	case NodeReleaseReleasing:
+		s.advanceRelease(ctx, client, chpa, r, now)
+	}
+}
+
+func (s *StrategyController) advanceDrain(ctx context.Context, chpa *v1alpha1.CustomedHorizontalPodAutoscaler,
+	r *NodeRelease, now time.Time) {
+	remaining, blocked, busy, err := s.drainNodes(ctx, chpa, r, now)
+	if err == nil && remaining == 0 {
+		logger.Infof("Nodes %v of release[%s] drained", r.nodeNames(), r.ID)
+		recordEvent(chpa, corev1.EventTypeNormal, EventReasonNodesDrained,
+			"All pods on nodes %v exited, release[%s]", r.nodeNames(), r.ID)
+		s.enterPhase(r, NodeReleaseReleasing, now)
+		client, _ := s.resourcesEnabled()
+		s.advanceRelease(ctx, client, chpa, r, now)
+		return
+	}
+	message := fmt.Sprintf("waiting for %d pods to exit, %d blocked by PodDisruptionBudget, %d busy", remaining,
+		blocked, busy)
+	if err != nil {
+		message = "drain nodes err: " + err.Error()
+	}
+	s.updateNodeRelease(r, func(r *NodeRelease) { r.Message = message })
+	s.checkPhaseTimeout(ctx, chpa, r, now, s.grmConf.DrainTimeoutSecond, message)
+}
+
+func (s *StrategyController) advanceRelease(ctx context.Context, client *grm.Client,
+	chpa *v1alpha1.CustomedHorizontalPodAutoscaler, r *NodeRelease, now time.Time) {
+	if err := s.releaseNodes(ctx, client, r); err != nil {
+		s.updateNodeRelease(r, func(r *NodeRelease) { r.Message = "release nodes err: " + err.Error() })
+		s.checkPhaseTimeout(ctx, chpa, r, now, s.grmConf.ReleaseTimeoutSecond, r.Message)
+		return
+	}
+	s.updateNodeRelease(r, func(r *NodeRelease) {
+		r.Phase, r.Message, r.FinishedAt = NodeReleaseSucceeded, "", &now
+	})
+	logger.Infof("Released nodes %v of customHPA[%s], release[%s]", r.nodeNames(), chpa.Name, r.ID)
+	recordEvent(chpa, corev1.EventTypeNormal, EventReasonNodesReleased,
+		"Released %d nodes %v, required replicas %d, capacity %d, release[%s]", len(r.Nodes), r.nodeNames(),
+		r.RequiredReplicas, r.Capacity, r.ID)
+	metrics.NodeReleasesTotal.WithLabelValues(chpa.Name, "succeeded").Inc()
+	s.refreshGRMNodes(ctx, client, chpa.Name)
+}
+
+func (s *StrategyController) enterPhase(r *NodeRelease, phase string, now time.Time) {
+	s.updateNodeRelease(r, func(r *NodeRelease) {
+		r.Phase, r.Message, r.PhaseStartedAt = phase, "", now
+	})
+}
+
+// checkPhaseTimeout 当前步骤超时后回滚，并在延迟释放时间后重新尝试释放
+func (s *StrategyController) checkPhaseTimeout(ctx context.Context, chpa *v1alpha1.CustomedHorizontalPodAutoscaler,
+	r *NodeRelease, now time.Time, timeoutSecond int, message string) {
+	timeout := time.Duration(timeoutSecond) * time.Second
+	if now.Sub(r.PhaseStartedAt) < timeout {
+		logger.Infof("Node release[%s] is %s: %s", r.ID, strings.ToLower(r.Phase), message)
+		return
+	}
+	s.rollbackNodeRelease(ctx, chpa, r, fmt.Sprintf("%s timeout after %s: %s", r.Phase, timeout, message), now,
+		true)
+}
+
+// rollbackNodeRelease 解除尚未释放节点的封锁并结束流程，retry 为 true 时在延迟释放时间后重新尝试释放
+func (s *StrategyController) rollbackNodeRelease(ctx context.Context, chpa *v1alpha1.CustomedHorizontalPodAutoscaler,
+	r *NodeRelease, reason string, now time.Time, retryLater bool) {
+	for _, node := range r.Nodes {
+		if node.Released {
+			continue
+		}
+		if err := setNodeCordon(ctx, node.Name, r.ID, false); err != nil {
+			logger.Errorf("Uncordon node[%s] of release[%s] err: %+v", node.Name, r.ID, err)
+		}
+	}
+	s.updateNodeRelease(r, func(r *NodeRelease) {
+		r.Phase, r.Message, r.FinishedAt = NodeReleaseRolledBack, reason, &now
+	})
+	logger.Warnf("Node release[%s] of customHPA[%s] rolled back: %s", r.ID, chpa.Name, reason)
+	recordEvent(chpa, corev1.EventTypeWarning, EventReasonNodeReleaseRolledBack,
+		"Release of nodes %v rolled back and nodes uncordoned: %s, release[%s]", r.nodeNames(), reason, r.ID)
+	metrics.NodeReleasesTotal.WithLabelValues(chpa.Name, "rolled_back").Inc()
+	if retryLater && s.grmConf != nil {
+		s.scheduleRelease(now.Add(time.Duration(s.grmConf.ReleaseDelaySecond) * time.Second))
+	}
+}
+
+// cordonNodes 封锁待释放的节点，已不在集群中的节点跳过
+func (s *StrategyController) cordonNodes(ctx context.Context, r *NodeRelease) error {
+	for _, node := range r.Nodes {
+		if err := setNodeCordon(ctx, node.Name, r.ID, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setNodeCordon 封锁或解除封锁节点：只封锁可调度的节点并记录流程 ID，只解除本流程封锁的节点
+func setNodeCordon(ctx context.Context, name, releaseID string, cordon bool) error {
+	nodeCli := k8sclient.GetKubeClientSet().CoreV1().Nodes()
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		node, err := nodeCli.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "get node[%s] err", name)
+		}
+		if cordon {
+			if node.Spec.Unschedulable {
+				return nil
+			}
+			node.Spec.Unschedulable = true
+			if node.Annotations == nil {
+				node.Annotations = map[string]string{}
+			}
+			node.Annotations[AnnotationCordonedBy] = releaseID
+		} else {
+			if node.Annotations[AnnotationCordonedBy] != releaseID {
+				return nil
+			}
+			node.Spec.Unschedulable = false
+			delete(node.Annotations, AnnotationCordonedBy)
+		}
+		_, err = nodeCli.Update(ctx, node, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// uncordonStaleNodes 解除上次运行中断的释放流程遗留的封锁
+func uncordonStaleNodes(ctx context.Context) {
+	nodes, err := k8sclient.GetKubeClientSet().CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		logger.Errorf("List nodes err: %v", err)
+		return
+	}
+	for _, node := range nodes.Items {
+		releaseID, ok := node.Annotations[AnnotationCordonedBy]
+		if !ok {
+			continue
+		}
+		if err = setNodeCordon(ctx, node.Name, releaseID, false); err != nil {
+			logger.Errorf("Uncordon node[%s] of stale release[%s] err: %+v", node.Name, releaseID, err)
+			continue
+		}
+		logger.Infof("Uncordoned node[%s] of stale release[%s]", node.Name, releaseID)
+	}
+}
+
+// drainNodes 驱逐待释放节点上的 pod，返回尚未退出的 pod 数、因 PodDisruptionBudget 暂不能驱逐的 pod 数，
+// 及正在执行任务暂不驱逐的目标负载 pod 数
+func (s *StrategyController) drainNodes(ctx context.Context, chpa *v1alpha1.CustomedHorizontalPodAutoscaler,
+	r *NodeRelease, now time.Time) (remaining, blocked, busy int, err error) {
+	podCli := k8sclient.GetKubeClientSet().CoreV1()
+	nodePods := make([][]*corev1.Pod, len(r.Nodes))
+	var drainable []*corev1.Pod
+	for i, node := range r.Nodes {
+		pods, err := podCli.Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+			FieldSelector: "spec.nodeName=" + node.Name,
+		})
+		if err != nil {
+			return 0, 0, 0, errors.Wrapf(err, "list pods on node[%s] err", node.Name)
+		}
+		for j := range pods.Items {
+			if pod := &pods.Items[j]; pod.Spec.NodeName == node.Name && isDrainablePod(pod) {
+				nodePods[i] = append(nodePods[i], pod)
+			}
+		}
+		drainable = append(drainable, nodePods[i]...)
+	}
+	busyPods, err := busyWorkerPods(ctx, chpa.Spec.ScaleTargetRef, drainable, now)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	counts := make([]int, len(r.Nodes))
+	for i, node := range r.Nodes {
+		for _, pod := range nodePods[i] {
+			counts[i]++
+			if pod.DeletionTimestamp != nil {
+				continue
+			}
+			// 正在执行任务的 pod 等任务结束后再驱逐，超过 drain_timeout_second 仍未结束时回滚本次释放
+			if busyPods[pod.Name] {
+				busy++
+				continue
+			}
+			eviction := &policyv1.Eviction{ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace}}
+			switch err := podCli.Pods(pod.Namespace).EvictV1(ctx, eviction); {
+			case err == nil:
+				logger.Infof("Evicted pod[%s/%s] on node[%s]", pod.Namespace, pod.Name, node.Name)
+			case apierrors.IsNotFound(err):
+				counts[i]--
+			case apierrors.IsTooManyRequests(err):
+				blocked++
+			default:
+				logger.Warnf("Evict pod[%s/%s] on node[%s] err: %v", pod.Namespace, pod.Name, node.Name, err)
+			}
+		}
+		remaining += counts[i]
+	}
+	s.updateNodeRelease(r, func(r *NodeRelease) {
+		for i := range r.Nodes {
+			r.Nodes[i].RemainingPods = counts[i]
+		}
+	})
+	return remaining, blocked, busy, nil
+}
+
+// busyWorkerPods 目标负载中正在执行任务的 pod：任务状态来源判断为忙碌，或 pod-deletion-cost 注解不低于忙碌的取值
+func busyWorkerPods(ctx context.Context, ref v1alpha1.ScaleTargetRef, pods []*corev1.Pod,
+	now time.Time) (map[string]bool, error) {
+	busy := map[string]bool{}
+	if len(pods) == 0 {
+		return busy, nil
+	}
+	w, err := getWorkloadReplicas(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	selector, err := metav1.LabelSelectorAsSelector(w.Selector)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid workload selector")
+	}
+	workers := make([]*corev1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if pod.Namespace == NamespaceDefault && selector.Matches(labels.Set(pod.Labels)) {
+			workers = append(workers, pod)
+		}
+	}
+	var states map[string]busystate.State
+	tracker := busystate.GetTracker()
+	if tracker != nil && len(workers) > 0 {
+		states = tracker.States(ctx, workers, now)
+	}
+	for _, pod := range workers {
+		if state, known := states[pod.Name]; known && deletionCost(state, known, now) >= deletionCostBusy {
+			busy[pod.Name] = true
+		} else if cost, err := strconv.Atoi(pod.Annotations[AnnotationPodDeletionCost]); err == nil &&
+			cost >= deletionCostBusy {
+			busy[pod.Name] = true
+		}
+	}
+	return busy, nil
+}
+
+// releaseNodes 按申请记录调用 GRM 释放接口，已释放的节点不重复释放
+func (s *StrategyController) releaseNodes(ctx context.Context, client *grm.Client, r *NodeRelease) error {
+	byAllocation := map[string][]string{}
+	var ids []string
+	for _, node := range r.Nodes {
+		if node.Released {
+			continue
+		}
+		if _, ok := byAllocation[node.AllocationID]; !ok {
+			ids = append(ids, node.AllocationID)
+		}
+		byAllocation[node.AllocationID] = append(byAllocation[node.AllocationID], node.Name)
+	}
+	for _, id := range ids {
+		nodes := byAllocation[id]
+		key := grm.IdempotencyKey("release", r.ID, id, nodes)
+		if _, err := client.Release(ctx, id, &grm.ReleaseRequest{Nodes: nodes}, key); err != nil &&
+			!errors.Is(err, grm.ErrNotFound) {
+			return errors.Wrapf(err, "release nodes %v of allocation[%s] err", nodes, id)
+		}
+		logger.Infof("Released nodes %v of allocation[%s], release[%s]", nodes, id, r.ID)
+		s.updateNodeRelease(r, func(r *NodeRelease) {
+			for i := range r.Nodes {
+				if r.Nodes[i].AllocationID == id {
+					r.Nodes[i].Released = true
+				}
+			}
+		})
+	}
+	return nil
+}
+
+// pickReleaseNodes 从持有的 GRM 节点中选出最多 count 个可释放的节点：跳过运行着无控制器管理的 pod 的节点
+// （驱逐后无法重建），优先选择 pod 较少的节点，pod 数相同时优先最近申请的节点
+func pickReleaseNodes(ctx context.Context, allocations []grm.Allocation, count int32) ([]ReleaseNode, error) {
+	pods, err := k8sclient.GetKubeClientSet().CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "list pods err")
+	}
+	podCount, unmanaged := map[string]int{}, map[string]bool{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName == "" || !isDrainablePod(pod) {
+			continue
+		}
+		podCount[pod.Spec.NodeName]++
+		if metav1.GetControllerOf(pod) == nil {
+			unmanaged[pod.Spec.NodeName] = true
+		}
+	}
+
+	var candidates []ReleaseNode
+	for i := len(allocations) - 1; i >= 0; i-- {
+		nodes := allocations[i].Nodes
+		for j := len(nodes) - 1; j >= 0; j-- {
+			if unmanaged[nodes[j]] {
+				logger.Infof("Node[%s] runs pods without controller, skip releasing", nodes[j])
+				continue
+			}
+			candidates = append(candidates, ReleaseNode{Name: nodes[j], AllocationID: allocations[i].ID,
+				RemainingPods: podCount[nodes[j]]})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].RemainingPods < candidates[j].RemainingPods
+	})
+	if int32(len(candidates)) > count {
+		candidates = candidates[:count]
+	}
+	return candidates, nil
+}
+
+// isDrainablePod 释放节点前需要驱逐并等待退出的 pod，DaemonSet 和静态 pod 随节点释放，已结束的 pod 不需要等待
+func isDrainablePod(pod *corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	if _, ok := pod.Annotations[corev1.MirrorPodAnnotationKey]; ok {
+		return false
+	}
+	if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "DaemonSet" {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,229 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+
+	"nanto.io/application-auto-scaling-service/pkg/busystate"
+	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/grm"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+// newTestNodePod 运行在指定节点上、由 ownerKind 类型控制器管理的 pod，ownerKind 为空时无控制器
+func newTestNodePod(name, node, ownerKind string) *corev1.Pod {
+	pod := newTestPod(name, corev1.PodRunning, false)
+	pod.Spec.NodeName = node
+	if ownerKind != "" {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: ownerKind, Name: name + "-owner",
+			Controller: &controller}}
+	}
+	return pod
+}
+
+// setupTestEviction 驱逐时删除 pod，blocked 中的 pod 返回 429（PodDisruptionBudget 不允许驱逐）
+func setupTestEviction(blocked map[string]bool) {
+	cs := k8sclient.GetKubeClientSet().(*kubefake.Clientset)
+	cs.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		eviction := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+		if blocked[eviction.Name] {
+			return true, nil, apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's "+
+				"disruption budget.", 10)
+		}
+		return true, nil, cs.Tracker().Delete(corev1.SchemeGroupVersion.WithResource("pods"), eviction.Namespace,
+			eviction.Name)
+	})
+}
+
+func getTestNode(t *testing.T, name string) *corev1.Node {
+	node, err := k8sclient.GetKubeClientSet().CoreV1().Nodes().Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get node[%s] err: %v", name, err)
+	}
+	return node
+}
+
+func setupTestNodeRelease(t *testing.T) (*grm.FakeServer, *StrategyController, map[string]bool,
+	*record.FakeRecorder) {
+	fake := setupTestGRM(t)
+	fake.AddAllocation("customedhpa01", "grm-node-1")
+	fake.AddAllocation("customedhpa01", "grm-node-2", "grm-node-3")
+	chpa := newTestCustomedHPA("customedhpa01", nil)
+	chpa.Spec.MinReplicas = utils.Int32Ptr(2)
+	recorder := setupFakeClientSet([]runtime.Object{
+		newTestNode("node-1", true), newTestNode("grm-node-1", true), newTestNode("grm-node-2", true),
+		newTestNode("grm-node-3", true), newTestCapacityDeployment(3),
+		// grm-node-1 上的 pod 没有控制器，驱逐后无法重建，不释放该节点
+		newTestNodePod("bare", "grm-node-1", ""),
+		newTestNodePod("worker-a", "grm-node-2", "ReplicaSet"),
+		newTestNodePod("worker-b", "grm-node-3", "ReplicaSet"),
+		newTestNodePod("agent", "grm-node-3", "DaemonSet"),
+	}, chpa)
+	blocked := map[string]bool{"worker-a": true}
+	setupTestEviction(blocked)
+	return fake, newTestResourcesController(&ResourceConf{Flavor: "c6.2xlarge", ReplicasPerNode: 2}), blocked, recorder
+}
+
+func TestStrategyController_advanceNodeRelease(t *testing.T) {
+	fake, s, blocked, recorder := setupTestNodeRelease(t)
+
+	now := time.Now()
+	s.scheduleRelease(now)
+	s.releaseSurplusNodes(now)
+	r := s.latestNodeRelease()
+	if r == nil || r.Phase != NodeReleaseDraining || len(r.Nodes) != 2 {
+		t.Fatalf("releaseSurplusNodes() node release = %+v", r)
+	}
+	for _, node := range r.Nodes {
+		if node.Name == "grm-node-1" {
+			t.Errorf("releaseSurplusNodes() picked node running pod without controller")
+		}
+		n := getTestNode(t, node.Name)
+		if !n.Spec.Unschedulable || n.Annotations[AnnotationCordonedBy] != r.ID {
+			t.Errorf("node[%s] should be cordoned by release[%s]", node.Name, r.ID)
+		}
+	}
+	if fake.Allocations[1].Status == grm.StatusReleased {
+		t.Fatalf("nodes released before drained")
+	}
+	if events := drainEvents(recorder); !hasEvent(events, EventReasonNodeReleaseStarted) ||
+		!hasEvent(events, EventReasonNodesCordoned) {
+		t.Errorf("expect events %s and %s", EventReasonNodeReleaseStarted, EventReasonNodesCordoned)
+	}
+
+	// PodDisruptionBudget 允许驱逐后，等待被驱逐的 pod 退出再释放，DaemonSet pod 不需要驱逐
+	blocked["worker-a"] = false
+	s.advanceNodeRelease(now.Add(10 * time.Second))
+	if r = s.latestNodeRelease(); r.Phase != NodeReleaseDraining {
+		t.Fatalf("advanceNodeRelease() after eviction node release = %+v", r)
+	}
+	s.advanceNodeRelease(now.Add(20 * time.Second))
+	if r = s.latestNodeRelease(); r.Phase != NodeReleaseSucceeded {
+		t.Fatalf("advanceNodeRelease() node release = %+v", r)
+	}
+	if fake.Allocations[1].Status != grm.StatusReleased || len(fake.Allocations[0].Nodes) != 1 {
+		t.Errorf("advanceNodeRelease() allocations = %+v, %+v", *fake.Allocations[0], *fake.Allocations[1])
+	}
+	if events := drainEvents(recorder); !hasEvent(events, EventReasonNodesDrained) ||
+		!hasEvent(events, EventReasonNodesReleased) {
+		t.Errorf("expect events %s and %s", EventReasonNodesDrained, EventReasonNodesReleased)
+	}
+	releases, err := s.NodeReleases("customedhpa01")
+	if err != nil || len(releases) != 1 {
+		t.Errorf("NodeReleases() = %+v, %v", releases, err)
+	}
+}
+
+// 正在执行任务的 worker 等任务结束后再驱逐
+func TestStrategyController_advanceNodeRelease_busyWorker(t *testing.T) {
+	tracker, err := busystate.NewTracker(&config.BusyStateConf{Source: busystate.SourcePush, PushTTLSecond: 600,
+		IntervalSecond: 15})
+	if err != nil {
+		t.Fatalf("NewTracker() err: %+v", err)
+	}
+	busystate.SetTracker(tracker)
+	defer busystate.SetTracker(nil)
+	_, s, blocked, _ := setupTestNodeRelease(t)
+	blocked["worker-a"] = false
+
+	now := time.Now()
+	tracker.Report("worker-b", busystate.State{Busy: true, ActiveTasks: 1, ReportedAt: now})
+	s.scheduleRelease(now)
+	s.releaseSurplusNodes(now)
+	for i := 1; i <= 2; i++ {
+		s.advanceNodeRelease(now.Add(time.Duration(i) * 10 * time.Second))
+	}
+	r := s.latestNodeRelease()
+	if r == nil || r.Phase != NodeReleaseDraining || !strings.Contains(r.Message, "1 busy") {
+		t.Fatalf("advanceNodeRelease() with busy worker node release = %+v", r)
+	}
+	if _, err = k8sclient.GetKubeClientSet().CoreV1().Pods(NamespaceDefault).Get(context.Background(), "worker-b",
+		metav1.GetOptions{}); err != nil {
+		t.Fatalf("busy worker-b should not be evicted, err: %v", err)
+	}
+
+	// 任务结束后驱逐并释放
+	tracker.Report("worker-b", busystate.State{ReportedAt: now.Add(30 * time.Second)})
+	s.advanceNodeRelease(now.Add(40 * time.Second))
+	s.advanceNodeRelease(now.Add(50 * time.Second))
+	if r = s.latestNodeRelease(); r.Phase != NodeReleaseSucceeded {
+		t.Errorf("advanceNodeRelease() after task finished node release = %+v", r)
+	}
+}
+
+func TestStrategyController_rollbackNodeRelease(t *testing.T) {
+	fake, s, _, recorder := setupTestNodeRelease(t)
+
+	now := time.Now()
+	s.scheduleRelease(now)
+	s.releaseSurplusNodes(now)
+	r := s.latestNodeRelease()
+	if r == nil || r.Phase != NodeReleaseDraining {
+		t.Fatalf("releaseSurplusNodes() node release = %+v", r)
+	}
+
+	// 驱逐超时后解除封锁，延迟释放时间后重新尝试
+	s.advanceNodeRelease(now.Add(11 * time.Minute))
+	if r = s.latestNodeRelease(); r.Phase != NodeReleaseRolledBack {
+		t.Fatalf("advanceNodeRelease() after drain timeout node release = %+v", r)
+	}
+	for _, node := range r.Nodes {
+		if n := getTestNode(t, node.Name); n.Spec.Unschedulable || n.Annotations[AnnotationCordonedBy] != "" {
+			t.Errorf("node[%s] should be uncordoned", node.Name)
+		}
+	}
+	if fake.Allocations[1].Status == grm.StatusReleased {
+		t.Errorf("nodes released after rollback")
+	}
+	if s.releaseAt.IsZero() {
+		t.Errorf("rollback after timeout should schedule release again")
+	}
+	if !hasEvent(drainEvents(recorder), EventReasonNodeReleaseRolledBack) {
+		t.Errorf("expect event %s", EventReasonNodeReleaseRolledBack)
+	}
+
+	// 取消进行中的释放，不再重新尝试
+	s.releaseSurplusNodes(s.releaseAt)
+	if r, err := s.CancelNodeRelease("customedhpa01"); err != nil || r.Phase != NodeReleaseRolledBack {
+		t.Fatalf("CancelNodeRelease() = %+v, %v", r, err)
+	}
+	if !s.releaseAt.IsZero() {
+		t.Errorf("canceled release should not be retried")
+	}
+	if _, err := s.CancelNodeRelease("customedhpa01"); err == nil {
+		t.Errorf("CancelNodeRelease() without active release should fail")
+	}
+}
+
+func Test_uncordonStaleNodes(t *testing.T) {
+	node := newTestNode("grm-node-1", true)
+	node.Spec.Unschedulable = true
+	node.Annotations = map[string]string{AnnotationCordonedBy: "customedhpa01-1"}
+	manual := newTestNode("node-1", true)
+	manual.Spec.Unschedulable = true
+	setupFakeClientSet([]runtime.Object{node, manual})
+
+	uncordonStaleNodes(context.Background())
+	if getTestNode(t, "grm-node-1").Spec.Unschedulable {
+		t.Errorf("stale cordon should be removed")
+	}
+	if !getTestNode(t, "node-1").Spec.Unschedulable {
+		t.Errorf("node cordoned by others should stay unschedulable")
+	}
+}
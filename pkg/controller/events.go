@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+)
+
+// 记录在目标 CustomedHPA 上的 event reason，可通过 kubectl describe 查看策略变更历史
+const (
+	// EventReasonWindowTransition 进入新的策略时间段
+	EventReasonWindowTransition = "StrategyWindowTransition"
+	// EventReasonStrategyApplied 策略更新成功
+	EventReasonStrategyApplied = "StrategyApplied"
+	// EventReasonStrategyUpdateFailed 策略更新失败
+	EventReasonStrategyUpdateFailed = "StrategyUpdateFailed"
+	// EventReasonStrategyInvalid 策略文件校验失败
+	EventReasonStrategyInvalid = "StrategyInvalid"
+	// EventReasonStrategyUpdateSkipped 策略更新被跳过（如目标HPA被暂停）
+	EventReasonStrategyUpdateSkipped = "StrategyUpdateSkipped"
+	// EventReasonStrategyResumed 暂停解除，恢复策略调度
+	EventReasonStrategyResumed = "StrategyResumed"
+	// EventReasonStrategyRolledBack 策略回滚到历史版本
+	EventReasonStrategyRolledBack = "StrategyRolledBack"
+	// EventReasonOriginalSpecRestored 恢复为首次接管前的原始 spec
+	EventReasonOriginalSpecRestored = "OriginalSpecRestored"
+	// EventReasonScaleVerified 策略更新后目标负载副本数已收敛
+	EventReasonScaleVerified = "ScaleVerified"
+	// EventReasonScaleVerifyTimeout 策略更新后目标负载副本数未在期限内收敛
+	EventReasonScaleVerifyTimeout = "ScaleVerifyTimeout"
+	// EventReasonScaleStuck 策略更新后目标负载存在长时间 Pending 的 pod
+	EventReasonScaleStuck = "ScaleStuck"
+	// EventReasonPredictiveScale 按请求量预测提高策略时间段的 minReplicas
+	EventReasonPredictiveScale = "PredictiveScale"
+	// EventReasonScaleDownGated 系统负载较高，切换时间段时保留当前 minReplicas
+	EventReasonScaleDownGated = "ScaleDownGated"
+	// EventReasonNodesAcquired 提高 minReplicas 前从 GRM 申请到节点
+	EventReasonNodesAcquired = "NodesAcquired"
+	// EventReasonNodesAcquireFailed 从 GRM 申请节点失败或超时
+	EventReasonNodesAcquireFailed = "NodesAcquireFailed"
+	// EventReasonNodesReleased 降低 minReplicas 后释放 GRM 节点
+	EventReasonNodesReleased = "NodesReleased"
+	// EventReasonNodeReleaseStarted 选出可释放的节点，开始封锁、驱逐并释放
+	EventReasonNodeReleaseStarted = "NodeReleaseStarted"
+	// EventReasonNodesCordoned 待释放的节点已封锁，开始驱逐节点上的 pod
+	EventReasonNodesCordoned = "NodesCordoned"
+	// EventReasonNodesDrained 待释放节点上的 pod 已全部退出
+	EventReasonNodesDrained = "NodesDrained"
+	// EventReasonNodeReleaseRolledBack 释放节点的某个步骤失败、超时或被取消，已解除封锁
+	EventReasonNodeReleaseRolledBack = "NodeReleaseRolledBack"
+	// EventReasonCapacityShortage 策略时间段的 minReplicas 超出集群可容纳的副本数
+	EventReasonCapacityShortage = "CapacityShortage"
+	// EventReasonQuotaCeilingExceeded 策略时间段的 maxReplicas 超出命名空间 ResourceQuota 允许的副本数
+	EventReasonQuotaCeilingExceeded = "QuotaCeilingExceeded"
+	// EventReasonNodeScaleOut 目标负载 pod 因资源不足 Pending，向 GRM 申请节点
+	EventReasonNodeScaleOut = "NodeScaleOut"
+	// EventReasonNodeScaleOutFailed Pending pod 触发的节点申请失败或节点未按时加入集群
+	EventReasonNodeScaleOutFailed = "NodeScaleOutFailed"
+)
+
+// recordEvent 在目标对象上记录 event
+func recordEvent(obj runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	k8sclient.GetEventRecorder().Eventf(obj, eventType, reason, messageFmt, args...)
+}
+
+// recordEventOnTarget 在指定名称的 CustomedHPA 上记录 event，获取不到对象时仅记录日志
+func recordEventOnTarget(targetHPA, eventType, reason, messageFmt string, args ...interface{}) {
+	if targetHPA == "" {
+		return
+	}
+	chpa, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
+		Get(context.Background(), targetHPA, metav1.GetOptions{})
+	if err != nil {
+		logger.Warnf("Get customHPA[%s] for recording event[%s] err: %v", targetHPA, reason, err)
+		return
+	}
+	recordEvent(chpa, eventType, reason, messageFmt, args...)
+}
+
+// recordStrategiesInvalid 策略文件校验失败时，在当前生效的目标HPA上记录 warning event
+func (s *StrategyController) recordStrategiesInvalid(err error) {
+	recordEventOnTarget(s.target(), corev1.EventTypeWarning, EventReasonStrategyInvalid,
+		"Load strategies from %s failed: %v", s.LocalPath, err)
+}
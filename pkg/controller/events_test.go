@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	crdfake "nanto.io/application-auto-scaling-service/pkg/k8sclient/clientset/versioned/fake"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+func newTestCustomedHPA(name string, annotations map[string]string) *v1alpha1.CustomedHorizontalPodAutoscaler {
+	return &v1alpha1.CustomedHorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: NamespaceDefault, Annotations: annotations},
+		Spec: v1alpha1.CustomedHorizontalPodAutoscalerSpec{
+			MinReplicas:    utils.Int32Ptr(1),
+			MaxReplicas:    utils.Int32Ptr(2),
+			ScaleTargetRef: v1alpha1.ScaleTargetRef{ApiVersion: "apps/v1", Kind: "Deployment", Name: "worker"},
+		},
+	}
+}
+
+// setupFakeClientSet 注入 fake clientset，返回 fake recorder 用于校验 event
+func setupFakeClientSet(kubeObjs []runtime.Object, chpas ...*v1alpha1.CustomedHorizontalPodAutoscaler) *record.FakeRecorder {
+	crdObjs := make([]runtime.Object, 0, len(chpas))
+	for _, obj := range chpas {
+		crdObjs = append(crdObjs, obj)
+	}
+	recorder := record.NewFakeRecorder(100)
+	k8sclient.SetK8sClientSet(kubefake.NewSimpleClientset(kubeObjs...), crdfake.NewSimpleClientset(crdObjs...), recorder)
+	return recorder
+}
+
+func drainEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case e := <-recorder.Events:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func hasEvent(events []string, reason string) bool {
+	for _, e := range events {
+		if strings.Contains(e, " "+reason+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCronFuncRecordEvents(t *testing.T) {
+	strategy := Strategy{
+		ValidTime: "0:00-09:30",
+		Spec: v1alpha1.CustomedHorizontalPodAutoscalerSpec{
+			MinReplicas: utils.Int32Ptr(3),
+			MaxReplicas: utils.Int32Ptr(9),
+		},
+	}
+
+	t.Run("applied", func(t *testing.T) {
+		recorder := setupFakeClientSet(nil, newTestCustomedHPA("chpa", nil))
+		(&StrategyController{history: NewHistoryStore(0)}).genCronFunc("chpa", strategy)()
+
+		events := drainEvents(recorder)
+		if !hasEvent(events, EventReasonWindowTransition) || !hasEvent(events, EventReasonStrategyApplied) {
+			t.Errorf("unexpected events: %v", events)
+		}
+		got, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
+			Get(context.Background(), "chpa", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("get chpa err: %v", err)
+		}
+		if *got.Spec.MinReplicas != 3 || *got.Spec.MaxReplicas != 9 || got.Spec.ScaleTargetRef.Name != "worker" {
+			t.Errorf("unexpected spec after apply: %+v", got.Spec)
+		}
+	})
+
+	t.Run("skipped", func(t *testing.T) {
+		recorder := setupFakeClientSet(nil, newTestCustomedHPA("chpa", map[string]string{AnnotationPaused: "true"}))
+		s := &StrategyController{history: NewHistoryStore(0)}
+		s.genCronFunc("chpa", strategy)()
+
+		events := drainEvents(recorder)
+		if !hasEvent(events, EventReasonStrategyUpdateSkipped) || hasEvent(events, EventReasonStrategyApplied) {
+			t.Errorf("unexpected events: %v", events)
+		}
+		if !s.isPaused() {
+			t.Errorf("expect paused to be true")
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	"nanto.io/application-auto-scaling-service/pkg/metrics"
+	"nanto.io/application-auto-scaling-service/pkg/promsource"
+	"nanto.io/application-auto-scaling-service/pkg/sysload"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+func (s *StrategyController) setGatedWindow(window string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gatedWindow = window
+}
+
+func (s *StrategyController) getGatedWindow() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gatedWindow
+}
+
+// gateScaleDown 即将更新的 spec 会降低 minReplicas 且系统负载较高时，保留当前的 minReplicas，
+// 记录被限制的时间段，由 retryGatedWindow 在负载下降后重新执行
+func (s *StrategyController) gateScaleDown(chpa *v1alpha1.CustomedHorizontalPodAutoscaler, window string,
+	spec *v1alpha1.CustomedHorizontalPodAutoscalerSpec) {
+	curMin, newMin := utils.Int32Value(chpa.Spec.MinReplicas), utils.Int32Value(spec.MinReplicas)
+	if newMin >= curMin {
+		s.setGatedWindow("")
+		return
+	}
+	blocked, reason := s.checkGate(chpa)
+	if !blocked {
+		s.setGatedWindow("")
+		return
+	}
+
+	keep := curMin
+	if max := utils.Int32Value(spec.MaxReplicas); max > 0 && keep > max {
+		keep = max
+	}
+	spec.MinReplicas = utils.Int32Ptr(keep)
+	s.setGatedWindow(window)
+	logger.Infof("Keep minReplicas %d of customHPA[%s] instead of %d for window[%s]: %s",
+		keep, chpa.Name, newMin, window, reason)
+	recordEvent(chpa, corev1.EventTypeNormal, EventReasonScaleDownGated,
+		"Keep minReplicas %d instead of %d for window[%s]: %s", keep, newMin, window, reason)
+	metrics.ScaleDownGatedTotal.WithLabelValues(chpa.Name).Inc()
+}
+
+// checkGate 根据当前加载策略的门控配置检查系统负载，返回是否限制降低 minReplicas 及原因；
+// 获取不到负载数据时不限制
+func (s *StrategyController) checkGate(chpa *v1alpha1.CustomedHorizontalPodAutoscaler) (bool, string) {
+	info := s.Strategies()
+	if info == nil || info.Gate == nil {
+		return false, ""
+	}
+	gate := info.Gate
+	ctx := context.Background()
+
+	if blocked, reason := checkQueryGates(ctx, gate.Queries); blocked {
+		return true, reason
+	}
+	if s.collector == nil {
+		return false, ""
+	}
+	if gate.MaxPodCPURatio > 0 {
+		ratio, err := s.targetCPURatio(ctx, chpa.Spec.ScaleTargetRef)
+		if err != nil {
+			logger.Warnf("Get cpu ratio of customHPA[%s] target err, gate skipped: %+v", chpa.Name, err)
+		} else if ratio > gate.MaxPodCPURatio {
+			return true, fmt.Sprintf("pod cpu ratio %.2f is above %.2f", ratio, gate.MaxPodCPURatio)
+		}
+	}
+	if gate.MaxNodeCPURatio > 0 {
+		ratio, err := sysload.NodesCPURatio(ctx, s.collector)
+		if err != nil {
+			logger.Warnf("Get cpu ratio of nodes err, gate skipped: %+v", err)
+		} else if ratio > gate.MaxNodeCPURatio {
+			return true, fmt.Sprintf("node cpu ratio %.2f is above %.2f", ratio, gate.MaxNodeCPURatio)
+		}
+	}
+	return false, ""
+}
+
+// checkQueryGates 执行 Prometheus 门控条件，未配置 Prometheus 或查询失败时不限制
+func checkQueryGates(ctx context.Context, queries []QueryGate) (bool, string) {
+	if len(queries) == 0 {
+		return false, ""
+	}
+	client := promsource.GetClient()
+	if client == nil {
+		logger.Warnf("Prometheus is not configured, %d gate queries skipped", len(queries))
+		return false, ""
+	}
+	for _, q := range queries {
+		value, err := client.Query(ctx, q.Query)
+		if err != nil {
+			logger.Warnf("Query gate[%s] from prometheus err, gate skipped: %+v", q.Name, err)
+			continue
+		}
+		if value > q.Max {
+			return true, fmt.Sprintf("%s %.2f is above %.2f", q.Name, value, q.Max)
+		}
+	}
+	return false, ""
+}
+
+// targetCPURatio 目标负载 pod 的 CPU 使用量与 request 的比值
+func (s *StrategyController) targetCPURatio(ctx context.Context, ref v1alpha1.ScaleTargetRef) (float64, error) {
+	w, err := getWorkloadReplicas(ctx, ref)
+	if err != nil {
+		return 0, err
+	}
+	selector, err := metav1.LabelSelectorAsSelector(w.Selector)
+	if err != nil {
+		return 0, err
+	}
+	return sysload.PodsCPURatio(ctx, s.collector, NamespaceDefault, selector)
+}
+
+// retryGatedWindow 被限制的时间段仍在生效且系统负载已下降时，重新执行该时间段策略
+func (s *StrategyController) retryGatedWindow() {
+	window := s.getGatedWindow()
+	if window == "" {
+		return
+	}
+	if window != s.activeWindow() {
+		s.setGatedWindow("")
+		return
+	}
+	target := s.target()
+	chpa, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
+		Get(context.Background(), target, metav1.GetOptions{})
+	if err != nil {
+		logger.Errorf("Get current customHPA err: %v", err)
+		return
+	}
+	if blocked, _ := s.checkGate(chpa); blocked {
+		return
+	}
+
+	s.mu.Lock()
+	strategy := findStrategy(s.strategiesInfo, window)
+	sourceRevision := s.localDataKey
+	s.mu.Unlock()
+	if strategy == nil {
+		s.setGatedWindow("")
+		return
+	}
+	logger.Infof("System load of customHPA[%s] dropped, re-apply window[%s]", target, window)
+	if err = s.applyStrategy(target, *strategy, sourceRevision, false); err != nil {
+		logger.Errorf("Re-apply strategy window[%s] err: %+v", window, err)
+	}
+}
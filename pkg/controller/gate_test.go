@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/loadstore"
+	"nanto.io/application-auto-scaling-service/pkg/promsource"
+	"nanto.io/application-auto-scaling-service/pkg/sysload"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+	"nanto.io/application-auto-scaling-service/pkg/utils/cronutil"
+)
+
+func TestStrategyController_gateScaleDown(t *testing.T) {
+	pod := newTestPod("worker-1", corev1.PodRunning, false)
+	pod.Spec.Containers = []corev1.Container{{Name: "worker", Resources: corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+	}}}
+	chpa := newTestCustomedHPA("customedhpa01", nil)
+	chpa.Spec.MinReplicas = utils.Int32Ptr(5)
+	chpa.Spec.MaxReplicas = utils.Int32Ptr(10)
+	recorder := setupFakeClientSet([]runtime.Object{newTestDeployment(5, 5), pod}, chpa)
+	cronutil.InitCron()
+	defer cronutil.GetCron().Stop()
+
+	// conf/local-strategies.yaml 中 gate.maxPodCPURatio 为 0.6，各时间段 minReplicas 均小于 5
+	collector := &sysload.FakeCollector{Pods: []sysload.PodUsage{
+		{Namespace: NamespaceDefault, Name: "worker-1", CPUMilli: 900},
+	}}
+	// apply 模式，没有负载数据时预测建议值即为时间段配置的 minReplicas
+	s := &StrategyController{LocalPath: "../../conf/local-strategies.yaml", history: NewHistoryStore(0),
+		collector: collector, loadStore: loadstore.NewStore(time.Hour, 10, 0), predictConf: &config.PredictConf{
+			Mode: PredictModeApply, StepMinute: 10, HorizonHour: 6, Headroom: 1, Alpha: 0.5, Beta: 0.01, Gamma: 0.3}}
+	if err := s.execLocalStrategies(); err != nil {
+		t.Fatalf("execLocalStrategies() err: %+v", err)
+	}
+	getMin := func() int32 {
+		cur, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
+			Get(context.Background(), "customedhpa01", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("get customHPA err: %v", err)
+		}
+		return utils.Int32Value(cur.Spec.MinReplicas)
+	}
+
+	active := s.activeWindow()
+	if got := getMin(); got != 5 || s.getGatedWindow() != active {
+		t.Errorf("minReplicas with high load = %d, gated window = %s, want 5, %s", got, s.getGatedWindow(), active)
+	}
+	if !hasEvent(drainEvents(recorder), EventReasonScaleDownGated) {
+		t.Errorf("expect event %s", EventReasonScaleDownGated)
+	}
+
+	// 预测建议值与时间段要求的值一致，刷新预测不重新执行策略
+	s.refreshPrediction(time.Now())
+	if hasEvent(drainEvents(recorder), EventReasonStrategyApplied) {
+		t.Errorf("refreshPrediction() re-applied the gated window")
+	}
+
+	// 负载未下降，不重新执行
+	s.retryGatedWindow()
+	if got := getMin(); got != 5 {
+		t.Errorf("minReplicas after retry with high load = %d, want 5", got)
+	}
+
+	// 负载下降后按时间段配置降低 minReplicas
+	collector.Pods[0].CPUMilli = 100
+	s.retryGatedWindow()
+	want := utils.Int32Value(findStrategy(s.Strategies(), active).Spec.MinReplicas)
+	if got := getMin(); got != want || s.getGatedWindow() != "" {
+		t.Errorf("minReplicas after load dropped = %d, gated window = %s, want %d", got, s.getGatedWindow(), want)
+	}
+}
+
+func TestCheckQueryGates(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("query") == "sum(conductor_queue_length)" {
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{},"value":[1600000000,"120"]}]}}`)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"parse error"}`)
+	}))
+	defer srv.Close()
+	defer promsource.SetClient(nil)
+
+	queries := []QueryGate{{Name: "queueLength", Query: "sum(conductor_queue_length)", Max: 100}}
+	// 未配置 Prometheus 时不限制
+	promsource.SetClient(nil)
+	if blocked, _ := checkQueryGates(context.Background(), queries); blocked {
+		t.Errorf("checkQueryGates() without prometheus should not block")
+	}
+
+	c, err := promsource.NewClient(&config.PrometheusConf{Endpoint: srv.URL, TimeoutSecond: 1})
+	if err != nil {
+		t.Fatalf("NewClient() err: %+v", err)
+	}
+	promsource.SetClient(c)
+	if blocked, reason := checkQueryGates(context.Background(), queries); !blocked {
+		t.Errorf("checkQueryGates() queue length above max should block, reason: %s", reason)
+	}
+	queries[0].Max = 200
+	if blocked, _ := checkQueryGates(context.Background(), queries); blocked {
+		t.Errorf("checkQueryGates() queue length below max should not block")
+	}
+	// 查询失败时不限制
+	if blocked, _ := checkQueryGates(context.Background(), []QueryGate{{Name: "bad", Query: "bad(", Max: 0}}); blocked {
+		t.Errorf("checkQueryGates() query err should not block")
+	}
+}
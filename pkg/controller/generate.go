@@ -0,0 +1,471 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	"nanto.io/application-auto-scaling-service/pkg/loadstore"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+const (
+	defaultGenerateStep       = 30 * time.Minute
+	defaultGenerateMaxWindows = 6
+	defaultGenerateMinWindow  = time.Hour
+	defaultGeneratePercentile = 0.9
+	defaultGenerateHeadroom   = 1.2
+	// 生成的 maxReplicas 相对时间段内峰值所需副本数的倍数
+	generateMaxReplicasFactor = 1.5
+
+	generateScaleUpThreshold   float32 = 0.6
+	generateScaleDownThreshold float32 = 0.2
+	generateCoolDownTime               = "1m"
+)
+
+// GenerateOptions 根据历史负载生成策略的参数
+type GenerateOptions struct {
+	// 目标HPA 及分流配置，分流配置中的 replicaCapacity 用于计算各任务类型需要的副本数
+	TargetHPA string
+	Shunting  []ShuntingConf
+	// 分流配置中没有的任务类型使用的单副本处理能力（每分钟请求数），为 0 时忽略这些任务类型
+	ReplicaThroughput float64
+	// 一天按 Step 划分为若干时间片，相邻且所需副本数相近的时间片合并为一个策略时间段
+	Step       time.Duration
+	MaxWindows int
+	MinWindow  time.Duration
+	// 各时间片取多天请求量的百分位数
+	Percentile float64
+	// 在所需副本数基础上预留的余量倍数
+	Headroom float64
+	Location *time.Location
+}
+
+// StrategyProposal 根据历史负载生成的策略建议
+type StrategyProposal struct {
+	Info         *StrategiesInfo     `json:"info"`
+	Explanations []WindowExplanation `json:"explanations"`
+}
+
+// WindowExplanation 生成的策略时间段及其边界的选取原因
+type WindowExplanation struct {
+	ValidTime string `json:"validTime"`
+	// 时间段内各时间片所需副本数的最小值、最大值
+	LowReplicas  int32  `json:"lowReplicas"`
+	PeakReplicas int32  `json:"peakReplicas"`
+	Reason       string `json:"reason"`
+}
+
+// slotProfile 一天中某个时间片的负载画像
+type slotProfile struct {
+	// 各任务类型的请求量（每分钟，多天的百分位数）
+	demand   map[string]float64
+	required int32
+}
+
+// window 相邻时间片 [start, end) 合并成的时间段
+type window struct {
+	start, end int
+}
+
+func (o *GenerateOptions) complete() error {
+	if o.TargetHPA == "" {
+		return errors.New("target hpa must be set")
+	}
+	if o.Step <= 0 {
+		o.Step = defaultGenerateStep
+	}
+	if o.Step > 24*time.Hour || (24*time.Hour)%o.Step != 0 {
+		return errors.Errorf("step[%s] must divide a day", o.Step)
+	}
+	if o.MaxWindows <= 0 {
+		o.MaxWindows = defaultGenerateMaxWindows
+	}
+	if o.MinWindow <= 0 {
+		o.MinWindow = defaultGenerateMinWindow
+	}
+	if o.Percentile <= 0 || o.Percentile > 1 {
+		o.Percentile = defaultGeneratePercentile
+	}
+	if o.Headroom <= 0 {
+		o.Headroom = defaultGenerateHeadroom
+	}
+	if o.Location == nil {
+		o.Location = time.Local
+	}
+	return nil
+}
+
+func (o *GenerateOptions) replicaThroughput(taskType string) float64 {
+	for _, conf := range o.Shunting {
+		if conf.TaskType == taskType {
+			return conf.ReplicaCapacity
+		}
+	}
+	return o.ReplicaThroughput
+}
+
+// GenerateStrategies 根据历史负载（按任务类型分组、时间升序）生成策略时间段：
+// 统计一天中各时间片的请求量、计算所需副本数，再将相邻且副本数相近的时间片合并为策略时间段
+func GenerateStrategies(samples map[string][]loadstore.Sample, opts GenerateOptions) (*StrategyProposal, error) {
+	if err := opts.complete(); err != nil {
+		return nil, err
+	}
+	profiles, taskTypes, err := buildDailyProfile(samples, &opts)
+	if err != nil {
+		return nil, err
+	}
+	windows := segmentDay(profiles, opts.MaxWindows, int(opts.MinWindow/opts.Step))
+
+	proposal := &StrategyProposal{
+		Info: &StrategiesInfo{TargetHPA: opts.TargetHPA, Shunting: opts.Shunting, Strategies: []Strategy{}},
+	}
+	for i, w := range windows {
+		low, peak := requiredRange(profiles[w.start:w.end])
+		validTime := fmt.Sprintf("%s-%s", slotClock(w.start, opts.Step), slotClock(w.end, opts.Step))
+		proposal.Info.Strategies = append(proposal.Info.Strategies, Strategy{
+			ValidTime:    validTime,
+			ExpectedLoad: meanDemand(profiles[w.start:w.end], taskTypes),
+			Spec:         genWindowSpec(low, peak),
+		})
+
+		reason := "start of day"
+		if i > 0 {
+			prev := windows[i-1]
+			reason = explainBoundary(profiles[prev.start:prev.end], profiles[w.start:w.end], taskTypes,
+				slotClock(w.start, opts.Step))
+		}
+		proposal.Explanations = append(proposal.Explanations, WindowExplanation{
+			ValidTime:    validTime,
+			LowReplicas:  low,
+			PeakReplicas: peak,
+			Reason:       reason,
+		})
+	}
+	if err = checkAndCompleteInfo(proposal.Info); err != nil {
+		return nil, errors.Wrap(err, "check generated strategies err")
+	}
+	return proposal, nil
+}
+
+// buildDailyProfile 按一天中的时间片汇总多天的负载，每个时间片取多天请求量的百分位数
+func buildDailyProfile(samples map[string][]loadstore.Sample, opts *GenerateOptions) ([]slotProfile, []string, error) {
+	slots := int(24 * time.Hour / opts.Step)
+	profiles := make([]slotProfile, slots)
+	for i := range profiles {
+		profiles[i].demand = map[string]float64{}
+	}
+
+	taskTypes := []string{}
+	for taskType, taskSamples := range samples {
+		throughput := opts.replicaThroughput(taskType)
+		if throughput <= 0 || len(taskSamples) == 0 {
+			logger.Warnf("Ignore task type[%s] without replica throughput or samples", taskType)
+			continue
+		}
+		taskTypes = append(taskTypes, taskType)
+
+		// 按 天、时间片 累加请求数，并记录有数据的时间片
+		perDay := map[string][]float64{}
+		sampled := map[string][]bool{}
+		for _, sample := range taskSamples {
+			t := sample.Time.In(opts.Location)
+			day := t.Format("2006-01-02")
+			if _, ok := perDay[day]; !ok {
+				perDay[day] = make([]float64, slots)
+				sampled[day] = make([]bool, slots)
+			}
+			midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, opts.Location)
+			slot := int(t.Sub(midnight)/opts.Step) % slots
+			perDay[day][slot] += sample.Requests
+			sampled[day][slot] = true
+		}
+		// 首尾不完整的一天及中间缺失数据的时间片不参与计算，避免被当作无请求拉低百分位数
+		for slot := 0; slot < slots; slot++ {
+			rates := make([]float64, 0, len(perDay))
+			for day, daySlots := range perDay {
+				if sampled[day][slot] {
+					rates = append(rates, daySlots[slot]/opts.Step.Minutes())
+				}
+			}
+			profiles[slot].demand[taskType] = percentile(rates, opts.Percentile)
+		}
+	}
+	if len(taskTypes) == 0 {
+		return nil, nil, errors.New("no load samples of task types with replica throughput")
+	}
+	sort.Strings(taskTypes)
+
+	for i := range profiles {
+		replicas := 0.0
+		for _, taskType := range taskTypes {
+			replicas += profiles[i].demand[taskType] / opts.replicaThroughput(taskType)
+		}
+		profiles[i].required = int32(math.Ceil(replicas * opts.Headroom))
+		if profiles[i].required < 1 {
+			profiles[i].required = 1
+		}
+	}
+	return profiles, taskTypes, nil
+}
+
+// segmentDay 先将所需副本数相同的相邻时间片合并，再反复合并峰值副本数最接近的相邻时间段，
+// 直到时间段个数不超过 maxWindows 且每个时间段不短于 minSlots 个时间片
+func segmentDay(profiles []slotProfile, maxWindows, minSlots int) []window {
+	windows := []window{}
+	for i := range profiles {
+		if len(windows) > 0 && profiles[i].required == profiles[windows[len(windows)-1].start].required {
+			windows[len(windows)-1].end = i + 1
+			continue
+		}
+		windows = append(windows, window{start: i, end: i + 1})
+	}
+
+	for len(windows) > 1 {
+		hasShort := false
+		for _, w := range windows {
+			if w.end-w.start < minSlots {
+				hasShort = true
+				break
+			}
+		}
+		if len(windows) <= maxWindows && !hasShort {
+			break
+		}
+		// 存在过短的时间段时，只合并包含过短时间段的相邻对
+		best, bestDiff := -1, int32(math.MaxInt32)
+		for i := 0; i < len(windows)-1; i++ {
+			a, b := windows[i], windows[i+1]
+			if hasShort && a.end-a.start >= minSlots && b.end-b.start >= minSlots {
+				continue
+			}
+			_, peakA := requiredRange(profiles[a.start:a.end])
+			_, peakB := requiredRange(profiles[b.start:b.end])
+			diff := peakA - peakB
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff < bestDiff {
+				best, bestDiff = i, diff
+			}
+		}
+		windows[best].end = windows[best+1].end
+		windows = append(windows[:best+1], windows[best+2:]...)
+	}
+	return windows
+}
+
+// genWindowSpec 生成时间段的 spec：minReplicas 保证时间段内的最低负载，maxReplicas 为峰值预留余量，
+// 负载波动越大，扩容步长越大
+func genWindowSpec(low, peak int32) v1alpha1.CustomedHorizontalPodAutoscalerSpec {
+	max := int32(math.Ceil(float64(peak) * generateMaxReplicasFactor))
+	if max <= low {
+		max = low + 1
+	}
+	upStep := (peak - low + 1) / 2
+	if upStep < 1 {
+		upStep = 1
+	}
+	upValue, downValue := generateScaleUpThreshold, generateScaleDownThreshold
+	downStep := int32(1)
+	return v1alpha1.CustomedHorizontalPodAutoscalerSpec{
+		CoolDownTime: generateCoolDownTime,
+		MinReplicas:  &low,
+		MaxReplicas:  &max,
+		Rules: []v1alpha1.Rule{
+			{
+				RuleName: "up",
+				Actions: []v1alpha1.Action{{MetricRange: fmt.Sprintf("%.2f,+Infinity", upValue),
+					OperationValue: &upStep}},
+				MetricTrigger: v1alpha1.MetricTrigger{MetricOperation: MetricOptScaleUp, MetricValue: &upValue},
+			},
+			{
+				RuleName: "down",
+				Actions: []v1alpha1.Action{{MetricRange: fmt.Sprintf("0.00,%.2f", downValue),
+					OperationValue: &downStep}},
+				MetricTrigger: v1alpha1.MetricTrigger{MetricOperation: MetricOptScaleDown, MetricValue: &downValue},
+			},
+		},
+	}
+}
+
+// explainBoundary 说明相邻两个时间段的边界选取原因
+func explainBoundary(prev, cur []slotProfile, taskTypes []string, clock string) string {
+	_, prevPeak := requiredRange(prev)
+	_, curPeak := requiredRange(cur)
+	trend := "rises"
+	if curPeak < prevPeak {
+		trend = "drops"
+	} else if curPeak == prevPeak {
+		trend = "stays"
+	}
+	prevLoad, curLoad := meanDemand(prev, taskTypes), meanDemand(cur, taskTypes)
+	changes := []string{}
+	for _, taskType := range taskTypes {
+		changes = append(changes, fmt.Sprintf("%s %.1f->%.1f req/min", taskType, prevLoad[taskType],
+			curLoad[taskType]))
+	}
+	return fmt.Sprintf("at %s peak required replicas %s from %d to %d (average load %s)",
+		clock, trend, prevPeak, curPeak, strings.Join(changes, ", "))
+}
+
+func requiredRange(profiles []slotProfile) (int32, int32) {
+	low, peak := int32(math.MaxInt32), int32(0)
+	for _, p := range profiles {
+		if p.required < low {
+			low = p.required
+		}
+		if p.required > peak {
+			peak = p.required
+		}
+	}
+	return low, peak
+}
+
+func meanDemand(profiles []slotProfile, taskTypes []string) map[string]float64 {
+	result := map[string]float64{}
+	for _, taskType := range taskTypes {
+		sum := 0.0
+		for _, p := range profiles {
+			sum += p.demand[taskType]
+		}
+		result[taskType] = math.Round(sum/float64(len(profiles))*10) / 10
+	}
+	return result
+}
+
+// percentile 线性插值计算百分位数
+func percentile(vals []float64, p float64) float64 {
+	if len(vals) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, vals...)
+	sort.Float64s(sorted)
+	pos := p * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(pos-float64(lower))
+}
+
+// slotClock 时间片起点对应的时刻，eg："9:30"，一天结束为 "24:00"
+func slotClock(slot int, step time.Duration) string {
+	d := time.Duration(slot) * step
+	return fmt.Sprintf("%d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// YAML 生成与 local-strategies.yaml 格式一致的策略文件，各时间段的选取原因以注释形式写在文件头
+func (p *StrategyProposal) YAML() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("# Generated from recorded load history, review before use.\n")
+	for _, e := range p.Explanations {
+		fmt.Fprintf(buf, "# %s: replicas %d-%d, %s\n", e.ValidTime, e.LowReplicas, e.PeakReplicas, e.Reason)
+	}
+	out, err := yaml.Marshal(newStrategiesFile(p.Info))
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal strategies err")
+	}
+	buf.Write(out)
+	return buf.Bytes(), nil
+}
+
+// strategiesFile 策略文件格式，只包含用户需要填写的字段（其余字段加载时自动补全）
+type strategiesFile struct {
+	TargetHPA  string         `yaml:"targetHPA"`
+	Shunting   []ShuntingConf `yaml:"shunting,omitempty"`
+	Gate       *GateConf      `yaml:"gate,omitempty"`
+	Resources  *ResourceConf  `yaml:"resources,omitempty"`
+	Capacity   *CapacityConf  `yaml:"capacity,omitempty"`
+	Strategies []strategyFile `yaml:"strategies"`
+}
+
+type strategyFile struct {
+	ValidTime    string             `yaml:"validTime"`
+	ExpectedLoad map[string]float64 `yaml:"expectedLoad,omitempty"`
+	Spec         specFile           `yaml:"spec"`
+}
+
+type specFile struct {
+	CoolDownTime string     `yaml:"coolDownTime"`
+	MaxReplicas  int32      `yaml:"maxReplicas"`
+	MinReplicas  int32      `yaml:"minReplicas"`
+	Rules        []ruleFile `yaml:"rules"`
+}
+
+type ruleFile struct {
+	Actions       []actionFile `yaml:"actions"`
+	MetricTrigger triggerFile  `yaml:"metricTrigger"`
+	RuleName      string       `yaml:"ruleName"`
+}
+
+type actionFile struct {
+	MetricRange    string `yaml:"metricRange"`
+	OperationValue int32  `yaml:"operationValue"`
+}
+
+type triggerFile struct {
+	MetricOperation string  `yaml:"metricOperation"`
+	MetricValue     float32 `yaml:"metricValue"`
+}
+
+func newStrategiesFile(info *StrategiesInfo) *strategiesFile {
+	f := &strategiesFile{TargetHPA: info.TargetHPA, Shunting: info.Shunting, Gate: info.Gate,
+		Resources: info.Resources, Capacity: info.Capacity, Strategies: []strategyFile{}}
+	for _, strategy := range info.Strategies {
+		spec := specFile{
+			CoolDownTime: strategy.Spec.CoolDownTime,
+			MaxReplicas:  utils.Int32Value(strategy.Spec.MaxReplicas),
+			MinReplicas:  utils.Int32Value(strategy.Spec.MinReplicas),
+			Rules:        []ruleFile{},
+		}
+		for _, rule := range strategy.Spec.Rules {
+			r := ruleFile{RuleName: rule.RuleName, MetricTrigger: triggerFile{
+				MetricOperation: rule.MetricTrigger.MetricOperation}}
+			if rule.MetricTrigger.MetricValue != nil {
+				r.MetricTrigger.MetricValue = *rule.MetricTrigger.MetricValue
+			}
+			for _, action := range rule.Actions {
+				r.Actions = append(r.Actions, actionFile{MetricRange: action.MetricRange,
+					OperationValue: utils.Int32Value(action.OperationValue)})
+			}
+			spec.Rules = append(spec.Rules, r)
+		}
+		f.Strategies = append(f.Strategies, strategyFile{ValidTime: strategy.ValidTime,
+			ExpectedLoad: strategy.ExpectedLoad, Spec: spec})
+	}
+	return f
+}
+
+// ProposeStrategies 根据上报的负载数据生成策略建议，目标HPA和分流配置沿用当前加载的策略
+func (s *StrategyController) ProposeStrategies(opts GenerateOptions) (*StrategyProposal, error) {
+	if s.loadStore == nil {
+		return nil, errors.New("load store is not configured")
+	}
+	info := s.Strategies()
+	if info == nil {
+		return nil, errors.New("strategies not loaded")
+	}
+	opts.TargetHPA = info.TargetHPA
+	opts.Shunting = info.Shunting
+	if opts.ReplicaThroughput == 0 && s.predictConf != nil {
+		opts.ReplicaThroughput = s.predictConf.ReplicaThroughput
+	}
+	now := time.Now()
+	samples := map[string][]loadstore.Sample{}
+	for _, taskType := range s.loadStore.TaskTypes() {
+		samples[taskType] = s.loadStore.Range(taskType, time.Time{}, now)
+	}
+	return GenerateStrategies(samples, opts)
+}
+
+// ReadStrategiesFile 读取、校验并补全本地策略文件
+func ReadStrategiesFile(path string) (*StrategiesInfo, error) {
+	info, _, err := (&StrategyController{}).getLocalStrategies(path)
+	return info, err
+}
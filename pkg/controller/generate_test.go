@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"nanto.io/application-auto-scaling-service/pkg/loadstore"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+func TestGenerateStrategies(t *testing.T) {
+	// 三天数据：0-8 点每分钟 10 个请求，8-20 点 100 个，20-24 点 40 个
+	start := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	samples := []loadstore.Sample{}
+	for m := 0; m < 3*24*60; m += 10 {
+		ts := start.Add(time.Duration(m) * time.Minute)
+		rate := 10.0
+		if ts.Hour() >= 8 && ts.Hour() < 20 {
+			rate = 100
+		} else if ts.Hour() >= 20 {
+			rate = 40
+		}
+		samples = append(samples, loadstore.Sample{Time: ts, Requests: rate * 10})
+	}
+
+	opts := GenerateOptions{
+		TargetHPA: "chpa",
+		Shunting:  []ShuntingConf{{TaskType: "transcode", ReplicaCapacity: 10}},
+		Headroom:  1,
+		Location:  time.UTC,
+	}
+	got, err := GenerateStrategies(map[string][]loadstore.Sample{"transcode": samples}, opts)
+	if err != nil {
+		t.Fatalf("GenerateStrategies() err: %v", err)
+	}
+
+	want := []struct {
+		validTime string
+		min       int32
+	}{{"0:00-8:00", 1}, {"8:00-20:00", 10}, {"20:00-24:00", 4}}
+	if len(got.Info.Strategies) != len(want) {
+		t.Fatalf("GenerateStrategies() got %d windows: %+v", len(got.Info.Strategies), got.Explanations)
+	}
+	for i, w := range want {
+		strategy := got.Info.Strategies[i]
+		if strategy.ValidTime != w.validTime || utils.Int32Value(strategy.Spec.MinReplicas) != w.min ||
+			utils.Int32Value(strategy.Spec.MaxReplicas) <= w.min || len(strategy.Spec.Rules) != 2 {
+			t.Errorf("window[%d] = %s min %d max %d, want %s min %d", i, strategy.ValidTime,
+				utils.Int32Value(strategy.Spec.MinReplicas), utils.Int32Value(strategy.Spec.MaxReplicas),
+				w.validTime, w.min)
+		}
+	}
+	if !strings.Contains(got.Explanations[1].Reason, "rises from 1 to 10") {
+		t.Errorf("explanation of window[1] = %s", got.Explanations[1].Reason)
+	}
+
+	// 生成的 yaml 可以按策略文件格式加载
+	out, err := got.YAML()
+	if err != nil {
+		t.Fatalf("YAML() err: %v", err)
+	}
+	info := &StrategiesInfo{}
+	if err = yaml.Unmarshal(out, info); err != nil {
+		t.Fatalf("unmarshal generated yaml err: %v\n%s", err, out)
+	}
+	if err = checkAndCompleteInfo(info); err != nil || info.TargetHPA != "chpa" || len(info.Strategies) != 3 ||
+		*info.Strategies[1].Spec.Rules[0].MetricTrigger.MetricValue != generateScaleUpThreshold {
+		t.Errorf("generated yaml invalid, err: %v\n%s", err, out)
+	}
+}
+
+func TestGenerateStrategies_partialDays(t *testing.T) {
+	// 从第一天 12 点到第二天 12 点，每个时间片只有一天有数据：8-20 点每分钟 100 个请求，其余 10 个
+	start := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	samples := []loadstore.Sample{}
+	for m := 0; m < 24*60; m += 10 {
+		ts := start.Add(time.Duration(m) * time.Minute)
+		rate := 10.0
+		if ts.Hour() >= 8 && ts.Hour() < 20 {
+			rate = 100
+		}
+		samples = append(samples, loadstore.Sample{Time: ts, Requests: rate * 10})
+	}
+
+	got, err := GenerateStrategies(map[string][]loadstore.Sample{"transcode": samples}, GenerateOptions{
+		TargetHPA: "chpa",
+		Shunting:  []ShuntingConf{{TaskType: "transcode", ReplicaCapacity: 10}},
+		Headroom:  1,
+		Location:  time.UTC,
+	})
+	if err != nil {
+		t.Fatalf("GenerateStrategies() err: %v", err)
+	}
+	// 没有数据的一天不按 0 请求量参与百分位数计算
+	for _, explanation := range got.Explanations {
+		if explanation.ValidTime == "8:00-20:00" && explanation.PeakReplicas != 10 {
+			t.Errorf("window %s peak replicas = %d, want 10", explanation.ValidTime, explanation.PeakReplicas)
+		}
+	}
+	if len(got.Explanations) < 2 || got.Explanations[1].ValidTime != "8:00-20:00" {
+		t.Errorf("GenerateStrategies() got = %+v", got.Explanations)
+	}
+}
+
+func Test_segmentDay(t *testing.T) {
+	required := []int32{1, 1, 2, 1, 1, 5, 5, 6, 5, 3, 3, 3}
+	profiles := make([]slotProfile, len(required))
+	for i := range required {
+		profiles[i].required = required[i]
+	}
+	got := segmentDay(profiles, 3, 2)
+	want := []window{{0, 5}, {5, 9}, {9, 12}}
+	if len(got) != len(want) {
+		t.Fatalf("segmentDay() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("segmentDay() = %v, want %v", got, want)
+			break
+		}
+	}
+}
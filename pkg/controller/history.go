@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+)
+
+const (
+	// 保存策略更新历史的 configmap
+	historyConfigMapName = "aass-apply-history"
+	// configmap 中记录受管理目标HPA列表的 key，不带 .json 后缀以免与目标HPA的历史记录冲突
+	managedTargetsKey = "managed-targets"
+
+	defaultHistoryMaxRecords = 100
+)
+
+// ApplyRecord 一次策略更新记录
+type ApplyRecord struct {
+	// 记录序号，同一目标HPA内递增
+	Revision int `json:"revision"`
+	// 更新时间
+	Time time.Time `json:"time"`
+	// 策略生效时间段，eg："0:00-09:30"；回滚时为空
+	Window string `json:"window,omitempty"`
+	// 策略来源版本（本地策略文件 md5）
+	SourceRevision string `json:"sourceRevision,omitempty"`
+	// 回滚记录恢复的来源，eg："revision 3"；非回滚记录为空
+	RollbackFrom string `json:"rollbackFrom,omitempty"`
+	// 更新后的 spec
+	Spec v1alpha1.CustomedHorizontalPodAutoscalerSpec `json:"spec"`
+	// 更新前的 spec
+	PreviousSpec v1alpha1.CustomedHorizontalPodAutoscalerSpec `json:"previousSpec"`
+}
+
+// HistoryStore 策略更新历史，持久化在 configmap 中，每个目标HPA对应一个 key，只追加不修改
+type HistoryStore struct {
+	namespace string
+	name      string
+	// 每个目标HPA最多保留的记录数，超出后丢弃最早的记录（configmap 有 1MB 大小限制）
+	maxRecords int
+}
+
+func NewHistoryStore(maxRecords int) *HistoryStore {
+	if maxRecords <= 0 {
+		maxRecords = defaultHistoryMaxRecords
+	}
+	return &HistoryStore{
+		namespace:  NamespaceDefault,
+		name:       historyConfigMapName,
+		maxRecords: maxRecords,
+	}
+}
+
+// List 获取目标HPA的策略更新历史，按序号升序
+func (h *HistoryStore) List(target string) ([]ApplyRecord, error) {
+	cm, err := k8sclient.GetKubeClientSet().CoreV1().ConfigMaps(h.namespace).
+		Get(context.Background(), h.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return []ApplyRecord{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "get configmap[%s] err", h.name)
+	}
+	return decodeRecords(cm, target)
+}
+
+// Get 获取目标HPA指定序号的记录
+func (h *HistoryStore) Get(target string, revision int) (*ApplyRecord, error) {
+	records, err := h.List(target)
+	if err != nil {
+		return nil, err
+	}
+	for i := range records {
+		if records[i].Revision == revision {
+			return &records[i], nil
+		}
+	}
+	return nil, errors.Wrapf(ErrRevisionNotFound, "revision[%d] of target[%s]", revision, target)
+}
+
+// Append 追加一条记录，自动分配序号
+func (h *HistoryStore) Append(target string, record *ApplyRecord) error {
+	cmCli := k8sclient.GetKubeClientSet().CoreV1().ConfigMaps(h.namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ctx := context.Background()
+		cm, err := cmCli.Get(ctx, h.name, metav1.GetOptions{})
+		notFound := apierrors.IsNotFound(err)
+		if err != nil && !notFound {
+			return errors.Wrapf(err, "get configmap[%s] err", h.name)
+		}
+		if notFound {
+			cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: h.name, Namespace: h.namespace}}
+		}
+
+		records, err := decodeRecords(cm, target)
+		if err != nil {
+			return err
+		}
+		record.Revision = 1
+		if len(records) > 0 {
+			record.Revision = records[len(records)-1].Revision + 1
+		}
+		records = append(records, *record)
+		if len(records) > h.maxRecords {
+			records = records[len(records)-h.maxRecords:]
+		}
+		if err = encodeRecords(cm, target, records); err != nil {
+			return err
+		}
+
+		if notFound {
+			_, err = cmCli.Create(ctx, cm, metav1.CreateOptions{})
+		} else {
+			_, err = cmCli.Update(ctx, cm, metav1.UpdateOptions{})
+		}
+		return err
+	})
+}
+
+// ManagedTargets 获取持久化的受管理目标HPA列表，用于重启后识别已从策略中移除的目标
+func (h *HistoryStore) ManagedTargets() ([]string, error) {
+	cm, err := k8sclient.GetKubeClientSet().CoreV1().ConfigMaps(h.namespace).
+		Get(context.Background(), h.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "get configmap[%s] err", h.name)
+	}
+	targets := []string{}
+	data, ok := cm.Data[managedTargetsKey]
+	if !ok {
+		return targets, nil
+	}
+	if err = json.Unmarshal([]byte(data), &targets); err != nil {
+		return nil, errors.Wrap(err, "unmarshal managed targets err")
+	}
+	return targets, nil
+}
+
+// SetManagedTargets 持久化受管理目标HPA列表
+func (h *HistoryStore) SetManagedTargets(targets []string) error {
+	bytes, err := json.Marshal(targets)
+	if err != nil {
+		return errors.Wrap(err, "marshal managed targets err")
+	}
+	cmCli := k8sclient.GetKubeClientSet().CoreV1().ConfigMaps(h.namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ctx := context.Background()
+		cm, err := cmCli.Get(ctx, h.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			cm = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: h.name, Namespace: h.namespace},
+				Data: map[string]string{managedTargetsKey: string(bytes)}}
+			_, err = cmCli.Create(ctx, cm, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return errors.Wrapf(err, "get configmap[%s] err", h.name)
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[managedTargetsKey] = string(bytes)
+		_, err = cmCli.Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+func decodeRecords(cm *corev1.ConfigMap, target string) ([]ApplyRecord, error) {
+	records := []ApplyRecord{}
+	data, ok := cm.Data[historyKey(target)]
+	if !ok {
+		return records, nil
+	}
+	if err := json.Unmarshal([]byte(data), &records); err != nil {
+		return nil, errors.Wrapf(err, "unmarshal history of target[%s] err", target)
+	}
+	return records, nil
+}
+
+func encodeRecords(cm *corev1.ConfigMap, target string, records []ApplyRecord) error {
+	bytes, err := json.Marshal(records)
+	if err != nil {
+		return errors.Wrapf(err, "marshal history of target[%s] err", target)
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[historyKey(target)] = string(bytes)
+	return nil
+}
+
+func historyKey(target string) string {
+	return target + ".json"
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+func newTestSpec(minReplicas, maxReplicas int32) v1alpha1.CustomedHorizontalPodAutoscalerSpec {
+	return v1alpha1.CustomedHorizontalPodAutoscalerSpec{
+		MinReplicas: utils.Int32Ptr(minReplicas),
+		MaxReplicas: utils.Int32Ptr(maxReplicas),
+	}
+}
+
+func TestHistoryStore(t *testing.T) {
+	setupFakeClientSet(nil)
+	history := NewHistoryStore(2)
+	for i := int32(1); i <= 3; i++ {
+		record := &ApplyRecord{Time: time.Now(), Window: "0:00-09:30", Spec: newTestSpec(i, 10)}
+		if err := history.Append("chpa", record); err != nil {
+			t.Fatalf("Append() err: %+v", err)
+		}
+	}
+
+	records, err := history.List("chpa")
+	if err != nil {
+		t.Fatalf("List() err: %+v", err)
+	}
+	if len(records) != 2 || records[0].Revision != 2 || records[1].Revision != 3 {
+		t.Errorf("List() got = %+v, want revision 2 and 3", records)
+	}
+	if records, _ = history.List("other"); len(records) != 0 {
+		t.Errorf("List() of other target got = %+v, want empty", records)
+	}
+}
+
+func TestRollbackStrategy(t *testing.T) {
+	chpa := newTestCustomedHPA("chpa", nil)
+	chpa.Spec.MinReplicas = utils.Int32Ptr(5)
+	setupFakeClientSet(nil, chpa)
+	history := NewHistoryStore(0)
+	_ = history.Append("chpa", &ApplyRecord{Spec: newTestSpec(3, 10), PreviousSpec: newTestSpec(1, 2)})
+	_ = history.Append("chpa", &ApplyRecord{Spec: newTestSpec(5, 10), PreviousSpec: newTestSpec(3, 10)})
+
+	tests := []struct {
+		name     string
+		revision int
+		wantMin  int32
+	}{
+		{"previous", 0, 3},
+		{"revision 1", 1, 3},
+		{"revision 3 is rollback of previous", 3, 3},
+		{"revision 2", 2, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := RollbackStrategy(history, "chpa", tt.revision); err != nil {
+				t.Fatalf("RollbackStrategy() err: %+v", err)
+			}
+			got, _ := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
+				Get(context.Background(), "chpa", metav1.GetOptions{})
+			if *got.Spec.MinReplicas != tt.wantMin || got.Spec.ScaleTargetRef.Name != "worker" {
+				t.Errorf("RollbackStrategy() spec = %+v, want minReplicas %d", got.Spec, tt.wantMin)
+			}
+			if got.Annotations[AnnotationPaused] != "true" {
+				t.Errorf("RollbackStrategy() should pause target, annotations: %v", got.Annotations)
+			}
+		})
+	}
+
+	if _, err := RollbackStrategy(history, "chpa", 100); err == nil {
+		t.Errorf("RollbackStrategy() to unknown revision should fail")
+	}
+}
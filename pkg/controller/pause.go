@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	"nanto.io/application-auto-scaling-service/pkg/utils/cronutil"
+)
+
+const (
+	// AnnotationPaused 目标HPA上的暂停注解，值为 "true" 时跳过定时策略更新
+	AnnotationPaused = "aass.nanto.io/paused"
+	// AnnotationPausedUntil 目标HPA上的暂停截止时间注解（RFC3339格式），截止前跳过定时策略更新
+	AnnotationPausedUntil = "aass.nanto.io/paused-until"
+)
+
+// getPauseState 根据目标HPA的注解判断策略调度是否暂停，返回是否暂停及原因
+func getPauseState(hpa *v1alpha1.CustomedHorizontalPodAutoscaler, now time.Time) (bool, string) {
+	annotations := hpa.GetAnnotations()
+	if val, ok := annotations[AnnotationPaused]; ok {
+		paused, err := strconv.ParseBool(val)
+		if err != nil {
+			logger.Warnf("Invalid annotation %s[%s] on customHPA[%s], ignored", AnnotationPaused, val, hpa.Name)
+		} else if paused {
+			return true, fmt.Sprintf("annotation %s is %q", AnnotationPaused, val)
+		}
+	}
+	if val, ok := annotations[AnnotationPausedUntil]; ok {
+		until, err := time.Parse(time.RFC3339, val)
+		if err != nil {
+			logger.Warnf("Invalid annotation %s[%s] on customHPA[%s], ignored", AnnotationPausedUntil, val, hpa.Name)
+		} else if now.Before(until) {
+			return true, fmt.Sprintf("annotation %s is %s", AnnotationPausedUntil, val)
+		}
+	}
+	return false, ""
+}
+
+func (s *StrategyController) setPaused(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = paused
+}
+
+func (s *StrategyController) isPaused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+// resumeIfUnpaused 检查目标HPA的暂停状态，暂停解除（注解删除或过期）后补执行当前时间段策略
+func (s *StrategyController) resumeIfUnpaused() {
+	curHpa, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
+		Get(context.Background(), s.target(), metav1.GetOptions{})
+	if err != nil {
+		logger.Errorf("Get current customHPA err: %v", err)
+		return
+	}
+	if paused, _ := getPauseState(curHpa, time.Now()); paused {
+		// 暂停可能由外部设置（如回滚），记录下来以便解除后恢复
+		s.setPaused(true)
+		return
+	}
+	if !s.isPaused() {
+		return
+	}
+	s.setPaused(false)
+
+	logger.Infof("CustomHPA[%s] is no longer paused, resume current strategy, status: %s",
+		s.target(), formatHPAStatus(&curHpa.Status))
+	recordEvent(curHpa, corev1.EventTypeNormal, EventReasonStrategyResumed,
+		"Pause annotation removed or expired, resume scheduled strategy")
+	jobExecNow, err := cronutil.FindJobNeedExecNow()
+	if err != nil {
+		logger.Errorf("Find job need exec now err: %+v", err)
+		return
+	}
+	jobExecNow.Run()
+}
+
+// SetTargetPaused 设置或清除目标HPA的暂停注解
+func SetTargetPaused(target string, paused bool) error {
+	chpaCli := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ctx := context.Background()
+		chpa, err := chpaCli.Get(ctx, target, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "get customHPA[%s] err", target)
+		}
+		if paused {
+			if chpa.Annotations == nil {
+				chpa.Annotations = map[string]string{}
+			}
+			chpa.Annotations[AnnotationPaused] = "true"
+		} else {
+			delete(chpa.Annotations, AnnotationPaused)
+			delete(chpa.Annotations, AnnotationPausedUntil)
+		}
+		_, err = chpaCli.Update(ctx, chpa, metav1.UpdateOptions{})
+		return err
+	})
+}
@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+	"nanto.io/application-auto-scaling-service/pkg/utils/cronutil"
+)
+
+func Test_getPauseState(t *testing.T) {
+	now := time.Date(2021, 10, 20, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{
+		{"no annotation", nil, false},
+		{"paused true", map[string]string{AnnotationPaused: "true"}, true},
+		{"paused false", map[string]string{AnnotationPaused: "false"}, false},
+		{"paused invalid", map[string]string{AnnotationPaused: "yes"}, false},
+		{"paused until future", map[string]string{AnnotationPausedUntil: "2021-10-20T13:00:00Z"}, true},
+		{"paused until expired", map[string]string{AnnotationPausedUntil: "2021-10-20T11:00:00Z"}, false},
+		{"paused until invalid", map[string]string{AnnotationPausedUntil: "13:00"}, false},
+		{"paused false but until future", map[string]string{
+			AnnotationPaused: "false", AnnotationPausedUntil: "2021-10-20T21:00:00+08:00"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hpa := &v1alpha1.CustomedHorizontalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{Name: "chpa", Annotations: tt.annotations},
+			}
+			got, reason := getPauseState(hpa, now)
+			if got != tt.want {
+				t.Errorf("getPauseState() got = %v, want %v, reason: %s", got, tt.want, reason)
+			}
+		})
+	}
+}
+
+// updateTestCustomedHPA 修改目标HPA：设置注解（值为空时删除），并将 maxReplicas 改为不属于任何时间段的值
+func updateTestCustomedHPA(t *testing.T, annotations map[string]string) {
+	chpa := getTestCustomedHPA(t, "customedhpa01")
+	if chpa.Annotations == nil {
+		chpa.Annotations = map[string]string{}
+	}
+	for key, val := range annotations {
+		if val == "" {
+			delete(chpa.Annotations, key)
+			continue
+		}
+		chpa.Annotations[key] = val
+	}
+	chpa.Spec.MaxReplicas = utils.Int32Ptr(99)
+	if _, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
+		Update(context.Background(), chpa, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update customHPA err: %v", err)
+	}
+}
+
+func TestStrategyController_resumeIfUnpaused(t *testing.T) {
+	recorder := setupFakeClientSet(nil, newTestCustomedHPA("customedhpa01", nil))
+	cronutil.InitCron()
+	defer cronutil.GetCron().Stop()
+	s := &StrategyController{LocalPath: "../../conf/local-strategies.yaml", history: NewHistoryStore(0)}
+	if err := s.execLocalStrategies(); err != nil {
+		t.Fatalf("execLocalStrategies() err: %+v", err)
+	}
+	target, err := s.Target("customedhpa01")
+	if err != nil {
+		t.Fatalf("Target() err: %+v", err)
+	}
+	wantMax := utils.Int32Value(target.Spec.MaxReplicas)
+	drainEvents(recorder)
+
+	// 暂停期间不执行策略
+	now := time.Now()
+	updateTestCustomedHPA(t, map[string]string{AnnotationPausedUntil: now.Add(time.Hour).Format(time.RFC3339)})
+	s.resumeIfUnpaused()
+	if !s.isPaused() || utils.Int32Value(getTestCustomedHPA(t, "customedhpa01").Spec.MaxReplicas) != 99 {
+		t.Fatalf("resumeIfUnpaused() while paused should not apply strategy")
+	}
+
+	// 暂停过期后补执行当前时间段策略
+	updateTestCustomedHPA(t, map[string]string{AnnotationPausedUntil: now.Add(-time.Minute).Format(time.RFC3339)})
+	s.resumeIfUnpaused()
+	if s.isPaused() || utils.Int32Value(getTestCustomedHPA(t, "customedhpa01").Spec.MaxReplicas) != wantMax {
+		t.Errorf("resumeIfUnpaused() after expiry should apply active window, maxReplicas = %d, want %d",
+			utils.Int32Value(getTestCustomedHPA(t, "customedhpa01").Spec.MaxReplicas), wantMax)
+	}
+	if events := drainEvents(recorder); !hasEvent(events, EventReasonStrategyResumed) {
+		t.Errorf("events = %v, want %s", events, EventReasonStrategyResumed)
+	}
+
+	// 只补执行一次
+	updateTestCustomedHPA(t, nil)
+	s.resumeIfUnpaused()
+	if utils.Int32Value(getTestCustomedHPA(t, "customedhpa01").Spec.MaxReplicas) != 99 {
+		t.Errorf("resumeIfUnpaused() should apply strategy only once after resume")
+	}
+
+	// 手动暂停后恢复时清除注解并补执行
+	if err = s.SetPaused("customedhpa01", true); err != nil {
+		t.Fatalf("SetPaused(true) err: %+v", err)
+	}
+	if err = s.SetPaused("customedhpa01", false); err != nil {
+		t.Fatalf("SetPaused(false) err: %+v", err)
+	}
+	chpa := getTestCustomedHPA(t, "customedhpa01")
+	if _, ok := chpa.Annotations[AnnotationPaused]; ok || utils.Int32Value(chpa.Spec.MaxReplicas) != wantMax {
+		t.Errorf("SetPaused(false) got annotations %v, maxReplicas %d, want %d", chpa.Annotations,
+			utils.Int32Value(chpa.Spec.MaxReplicas), wantMax)
+	}
+}
@@ -0,0 +1,278 @@
+package controller
+
+import (
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	"nanto.io/application-auto-scaling-service/pkg/metrics"
+	"nanto.io/application-auto-scaling-service/pkg/predictor"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+const (
+	PredictModeOff       = "off"
+	PredictModeRecommend = "recommend"
+	PredictModeApply     = "apply"
+)
+
+// ErrPredictionDisabled 未开启预测
+var ErrPredictionDisabled = errors.New("prediction is disabled")
+
+// Prediction 请求量预测结果，及据此给出的各策略时间段 minReplicas 建议
+type Prediction struct {
+	Mode        string                 `json:"mode"`
+	Target      string                 `json:"target"`
+	GeneratedAt time.Time              `json:"generatedAt"`
+	Forecasts   []*predictor.Forecast  `json:"forecasts"`
+	Windows     []WindowRecommendation `json:"windows"`
+	// 无法预测的任务类型及原因，如历史数据不足
+	Skipped map[string]string `json:"skipped,omitempty"`
+}
+
+// WindowRecommendation 预测范围内某个策略时间段的 minReplicas 建议
+type WindowRecommendation struct {
+	Window string    `json:"window"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	// 时间段内各任务类型的预测峰值请求量（每分钟）
+	PeakDemand map[string]float64 `json:"peakDemand"`
+	// 承载预测峰值（含余量）需要的副本数
+	RequiredReplicas int32 `json:"requiredReplicas"`
+	// 策略文件中配置的 minReplicas、maxReplicas
+	StaticMinReplicas int32 `json:"staticMinReplicas"`
+	MaxReplicas       int32 `json:"maxReplicas"`
+	// 建议的 minReplicas，不低于策略配置的 minReplicas，不超过 maxReplicas
+	RecommendedMinReplicas int32 `json:"recommendedMinReplicas"`
+}
+
+// windowRange 某个策略时间段的一次生效时间范围
+type windowRange struct {
+	Window     string
+	Start, End time.Time
+}
+
+func newPredictorConfig(conf *config.PredictConf) *predictor.Config {
+	return &predictor.Config{
+		Step:    time.Duration(conf.StepMinute) * time.Minute,
+		Horizon: time.Duration(conf.HorizonHour) * time.Hour,
+		Alpha:   conf.Alpha,
+		Beta:    conf.Beta,
+		Gamma:   conf.Gamma,
+	}
+}
+
+// checkPredictConf 校验预测配置，未开启预测时不校验
+func checkPredictConf(conf *config.PredictConf) error {
+	if conf == nil {
+		return nil
+	}
+	switch conf.Mode {
+	case "", PredictModeOff:
+		return nil
+	case PredictModeRecommend, PredictModeApply:
+	default:
+		return errors.Errorf("invalid predict mode[%s], must be %s, %s or %s", conf.Mode, PredictModeOff,
+			PredictModeRecommend, PredictModeApply)
+	}
+	if conf.Headroom <= 0 {
+		return errors.Errorf("invalid predict headroom[%v]", conf.Headroom)
+	}
+	return newPredictorConfig(conf).Validate()
+}
+
+func (s *StrategyController) predictEnabled() bool {
+	return s.predictConf != nil && s.predictConf.Mode != "" && s.predictConf.Mode != PredictModeOff &&
+		s.loadStore != nil
+}
+
+// Prediction 获取最近一次的预测结果
+func (s *StrategyController) Prediction() (*Prediction, error) {
+	if !s.predictEnabled() {
+		return nil, ErrPredictionDisabled
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.prediction == nil {
+		return nil, errors.New("prediction is not ready")
+	}
+	return s.prediction, nil
+}
+
+// refreshPrediction 根据历史请求量预测之后的请求量，计算各策略时间段的 minReplicas 建议；
+// apply 模式下建议值与当前时间段上次要求的值不一致时，重新执行当前时间段策略；
+// 与门控、容量限制后实际生效的值比较会导致每次刷新都重新执行
+func (s *StrategyController) refreshPrediction(now time.Time) {
+	info := s.Strategies()
+	if info == nil {
+		return
+	}
+	prediction := s.predict(info, s.NextTransitions(), now)
+	for _, rec := range prediction.Windows {
+		metrics.PredictedMinReplicas.WithLabelValues(info.TargetHPA, rec.Window).
+			Set(float64(rec.RecommendedMinReplicas))
+	}
+
+	s.mu.Lock()
+	s.prediction = prediction
+	appliedWindow, requestedMinReplicas := s.appliedWindow, s.requestedMinReplicas
+	sourceRevision := s.localDataKey
+	s.mu.Unlock()
+
+	if s.predictConf.Mode != PredictModeApply || len(prediction.Windows) == 0 || s.isPaused() {
+		return
+	}
+	current := prediction.Windows[0]
+	if current.Window != appliedWindow || current.RecommendedMinReplicas == requestedMinReplicas {
+		return
+	}
+	strategy := findStrategy(info, current.Window)
+	if strategy == nil {
+		return
+	}
+	logger.Infof("Predicted minReplicas of window[%s] changed from %d to %d, re-apply strategy",
+		current.Window, requestedMinReplicas, current.RecommendedMinReplicas)
+	if err := s.applyStrategy(info.TargetHPA, *strategy, sourceRevision, false); err != nil {
+		logger.Errorf("Re-apply strategy window[%s] err: %+v", current.Window, err)
+	}
+}
+
+// predict 预测各任务类型的请求量，并计算预测范围内各策略时间段的 minReplicas 建议
+func (s *StrategyController) predict(info *StrategiesInfo, transitions []Transition, now time.Time) *Prediction {
+	conf := newPredictorConfig(s.predictConf)
+	prediction := &Prediction{
+		Mode:        s.predictConf.Mode,
+		Target:      info.TargetHPA,
+		GeneratedAt: now,
+		Forecasts:   []*predictor.Forecast{},
+		Windows:     []WindowRecommendation{},
+		Skipped:     map[string]string{},
+	}
+	for _, taskType := range s.loadStore.TaskTypes() {
+		if s.replicaThroughput(info, taskType) <= 0 {
+			prediction.Skipped[taskType] = "replica throughput not configured"
+			continue
+		}
+		forecast, err := predictor.Predict(taskType, s.loadStore.Range(taskType, time.Time{}, now), now, conf)
+		if err != nil {
+			logger.Debugf("Predict task type[%s] err: %v", taskType, err)
+			prediction.Skipped[taskType] = err.Error()
+			continue
+		}
+		prediction.Forecasts = append(prediction.Forecasts, forecast)
+	}
+
+	for _, r := range upcomingWindows(transitions, now, now.Add(conf.Horizon)) {
+		strategy := findStrategy(info, r.Window)
+		if strategy == nil {
+			continue
+		}
+		rec := WindowRecommendation{
+			Window:            r.Window,
+			Start:             r.Start,
+			End:               r.End,
+			PeakDemand:        map[string]float64{},
+			StaticMinReplicas: utils.Int32Value(strategy.Spec.MinReplicas),
+			MaxReplicas:       utils.Int32Value(strategy.Spec.MaxReplicas),
+		}
+		required := 0.0
+		for _, forecast := range prediction.Forecasts {
+			peak := peakDemand(forecast.Points, r.Start, r.End, conf.Step)
+			rec.PeakDemand[forecast.TaskType] = peak
+			required += peak / s.replicaThroughput(info, forecast.TaskType)
+		}
+		rec.RequiredReplicas = int32(math.Ceil(required * s.predictConf.Headroom))
+		rec.RecommendedMinReplicas = recommendMinReplicas(rec.RequiredReplicas, rec.StaticMinReplicas, rec.MaxReplicas)
+		prediction.Windows = append(prediction.Windows, rec)
+	}
+	return prediction
+}
+
+// replicaThroughput 单个副本处理某任务类型的能力（每分钟请求数），优先使用分流配置
+func (s *StrategyController) replicaThroughput(info *StrategiesInfo, taskType string) float64 {
+	for _, conf := range info.Shunting {
+		if conf.TaskType == taskType {
+			return conf.ReplicaCapacity
+		}
+	}
+	return s.predictConf.ReplicaThroughput
+}
+
+// recommendMinReplicas 建议的 minReplicas 不低于策略配置的值，不超过 maxReplicas
+func recommendMinReplicas(required, staticMin, max int32) int32 {
+	if required > max && max > 0 {
+		required = max
+	}
+	if required < staticMin {
+		return staticMin
+	}
+	return required
+}
+
+// peakDemand 预测结果中与 [start, end) 有重叠的时间间隔内的最大请求量
+func peakDemand(points []predictor.Point, start, end time.Time, step time.Duration) float64 {
+	peak := 0.0
+	for _, point := range points {
+		if point.Time.Add(step).After(start) && point.Time.Before(end) && point.Requests > peak {
+			peak = point.Requests
+		}
+	}
+	return peak
+}
+
+// upcomingWindows 根据即将发生的策略时间段切换（按时间升序）计算 [now, until) 内各策略时间段的生效范围，
+// 第一个为当前所处的时间段
+func upcomingWindows(transitions []Transition, now, until time.Time) []windowRange {
+	ranges := []windowRange{}
+	if len(transitions) == 0 {
+		return ranges
+	}
+	// 下次触发最晚的为当前时间段，持续到最近一次切换
+	ranges = append(ranges, windowRange{Window: transitions[len(transitions)-1].Window, Start: now,
+		End: transitions[0].Time})
+	for i := 0; i < len(transitions)-1; i++ {
+		if !transitions[i].Time.Before(until) {
+			break
+		}
+		ranges = append(ranges, windowRange{Window: transitions[i].Window, Start: transitions[i].Time,
+			End: transitions[i+1].Time})
+	}
+	for i := range ranges {
+		if ranges[i].End.After(until) {
+			ranges[i].End = until
+		}
+	}
+	return ranges
+}
+
+// adjustMinReplicas apply 模式下，按预测建议提高即将更新的策略时间段的 minReplicas
+func (s *StrategyController) adjustMinReplicas(chpa *v1alpha1.CustomedHorizontalPodAutoscaler, window string,
+	spec *v1alpha1.CustomedHorizontalPodAutoscalerSpec) {
+	if !s.predictEnabled() || s.predictConf.Mode != PredictModeApply {
+		return
+	}
+	s.mu.Lock()
+	prediction := s.prediction
+	s.mu.Unlock()
+	// 预测结果过旧时不使用
+	if prediction == nil || time.Since(prediction.GeneratedAt) > 2*newPredictorConfig(s.predictConf).Step {
+		return
+	}
+	for _, rec := range prediction.Windows {
+		if rec.Window != window {
+			continue
+		}
+		staticMin := utils.Int32Value(spec.MinReplicas)
+		if rec.RecommendedMinReplicas > staticMin {
+			spec.MinReplicas = utils.Int32Ptr(rec.RecommendedMinReplicas)
+			recordEvent(chpa, corev1.EventTypeNormal, EventReasonPredictiveScale,
+				"Raise minReplicas of window[%s] from %d to %d by predicted load %v",
+				window, staticMin, rec.RecommendedMinReplicas, rec.PeakDemand)
+		}
+		return
+	}
+}
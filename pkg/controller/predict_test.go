@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/loadstore"
+)
+
+func Test_upcomingWindows(t *testing.T) {
+	now := time.Date(2021, 10, 1, 10, 0, 0, 0, time.Local)
+	transitions := []Transition{
+		{Window: "12:00-18:00", Time: now.Add(2 * time.Hour)},
+		{Window: "18:00-9:00", Time: now.Add(8 * time.Hour)},
+		{Window: "9:00-12:00", Time: now.Add(23 * time.Hour)},
+	}
+	got := upcomingWindows(transitions, now, now.Add(6*time.Hour))
+	if len(got) != 2 {
+		t.Fatalf("upcomingWindows() got %+v, want 2 windows", got)
+	}
+	if got[0].Window != "9:00-12:00" || !got[0].Start.Equal(now) || !got[0].End.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("upcomingWindows()[0] = %+v", got[0])
+	}
+	// 结束时间截止到预测范围
+	if got[1].Window != "12:00-18:00" || !got[1].End.Equal(now.Add(6*time.Hour)) {
+		t.Errorf("upcomingWindows()[1] = %+v", got[1])
+	}
+}
+
+func Test_recommendMinReplicas(t *testing.T) {
+	tests := []struct {
+		name                     string
+		required, staticMin, max int32
+		want                     int32
+	}{
+		{"below static", 1, 3, 10, 3},
+		{"raise", 6, 3, 10, 6},
+		{"clamp to max", 20, 3, 10, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := recommendMinReplicas(tt.required, tt.staticMin, tt.max); got != tt.want {
+				t.Errorf("recommendMinReplicas() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrategyController_predict(t *testing.T) {
+	// 数据按当前时间过期，取前一天 7:30 作为预测时间
+	y, m, d := time.Now().AddDate(0, 0, -1).Date()
+	now := time.Date(y, m, d, 7, 30, 0, 0, time.Local)
+	store := loadstore.NewStore(30*24*time.Hour, 1000, 0)
+	// 三天历史数据，每天 8 点到 20 点为高峰（每分钟 100 个请求）
+	start := now.Truncate(time.Hour).Add(-3 * 24 * time.Hour)
+	for h := 0; h < 3*24; h++ {
+		ts := start.Add(time.Duration(h) * time.Hour)
+		requests := 600.0
+		if ts.Hour() >= 8 && ts.Hour() < 20 {
+			requests = 6000
+		}
+		store.Add("transcode", loadstore.Sample{Time: ts, Requests: requests})
+	}
+	store.Add("unknown", loadstore.Sample{Time: start, Requests: 1})
+
+	s := &StrategyController{
+		loadStore: store,
+		predictConf: &config.PredictConf{Mode: PredictModeRecommend, StepMinute: 60, HorizonHour: 4,
+			Headroom: 1, Alpha: 0.5, Beta: 0.01, Gamma: 0.3},
+	}
+	info := &StrategiesInfo{
+		TargetHPA: "chpa",
+		Shunting:  []ShuntingConf{{TaskType: "transcode", ReplicaCapacity: 20}},
+		Strategies: []Strategy{
+			{ValidTime: "20:00-8:00", Spec: newTestSpec(1, 10)},
+			{ValidTime: "8:00-20:00", Spec: newTestSpec(2, 10)},
+		},
+	}
+	transitions := []Transition{
+		{Window: "8:00-20:00", Time: now.Add(30 * time.Minute)},
+		{Window: "20:00-8:00", Time: now.Add(12*time.Hour + 30*time.Minute)},
+	}
+	got := s.predict(info, transitions, now)
+
+	if len(got.Forecasts) != 1 || got.Skipped["unknown"] == "" {
+		t.Fatalf("predict() got forecasts %d, skipped %v", len(got.Forecasts), got.Skipped)
+	}
+	if len(got.Windows) != 2 {
+		t.Fatalf("predict() got windows %+v", got.Windows)
+	}
+	// 夜间时间段预测请求量约为 10/min，不超过策略配置的 minReplicas
+	if rec := got.Windows[0]; rec.Window != "20:00-8:00" || rec.RecommendedMinReplicas != 1 {
+		t.Errorf("predict() window[0] = %+v", rec)
+	}
+	// 高峰时间段预测请求量约为 100/min，需要 5 个副本
+	if rec := got.Windows[1]; rec.Window != "8:00-20:00" || rec.RecommendedMinReplicas != 5 {
+		t.Errorf("predict() window[1] = %+v", rec)
+	}
+}
+
+func Test_checkPredictConf(t *testing.T) {
+	valid := func() *config.PredictConf {
+		return &config.PredictConf{Mode: PredictModeApply, StepMinute: 10, HorizonHour: 6, Headroom: 1.2,
+			Alpha: 0.5, Beta: 0.01, Gamma: 0.3}
+	}
+	tests := []struct {
+		name    string
+		modify  func(conf *config.PredictConf)
+		wantErr bool
+	}{
+		{"valid", func(conf *config.PredictConf) {}, false},
+		{"off skips validation", func(conf *config.PredictConf) { conf.Mode, conf.StepMinute = PredictModeOff, 0 }, false},
+		{"invalid mode", func(conf *config.PredictConf) { conf.Mode = "auto" }, true},
+		{"zero step", func(conf *config.PredictConf) { conf.StepMinute = 0 }, true},
+		{"step over a day", func(conf *config.PredictConf) { conf.StepMinute = 25 * 60 }, true},
+		{"zero horizon", func(conf *config.PredictConf) { conf.HorizonHour = 0 }, true},
+		{"zero headroom", func(conf *config.PredictConf) { conf.Headroom = 0 }, true},
+		{"alpha over 1", func(conf *config.PredictConf) { conf.Alpha = 1.5 }, true},
+		{"negative gamma", func(conf *config.PredictConf) { conf.Gamma = -0.1 }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := valid()
+			tt.modify(conf)
+			if err := checkPredictConf(conf); (err != nil) != tt.wantErr {
+				t.Errorf("checkPredictConf() err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
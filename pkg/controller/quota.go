@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	"nanto.io/application-auto-scaling-service/pkg/metrics"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+const (
+	// AnnotationQuotaCeiling 最近一次更新策略时，命名空间 ResourceQuota 允许的目标负载最大副本数
+	AnnotationQuotaCeiling = "aass.nanto.io/quota-ceiling"
+	// AnnotationQuotaExceeded 最近一次更新的策略时间段 maxReplicas 超出配额上限时记录，eg："window=0:00-9:00,maxReplicas=10"
+	AnnotationQuotaExceeded = "aass.nanto.io/quota-exceeded"
+)
+
+// QuotaCeiling 命名空间 ResourceQuota 对目标负载副本数的限制
+type QuotaCeiling struct {
+	// 配额允许的最大副本数：当前副本数 + 剩余配额可容纳的副本数
+	Replicas int32 `json:"replicas"`
+	// 限制副本数的 ResourceQuota 及资源
+	Quota    string `json:"quota"`
+	Resource string `json:"resource"`
+	// maxReplicas 超出上限的策略时间段
+	ExceededWindows []string  `json:"exceededWindows,omitempty"`
+	CheckedAt       time.Time `json:"checkedAt"`
+}
+
+func (s *StrategyController) setQuotaCeiling(ceiling *QuotaCeiling) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quotaCeiling = ceiling
+}
+
+// validateQuota 加载策略后，校验各时间段的 maxReplicas 是否超出命名空间配额允许的副本数，超出时记录 warning event
+func (s *StrategyController) validateQuota(info *StrategiesInfo) {
+	chpa, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
+		Get(context.Background(), info.TargetHPA, metav1.GetOptions{})
+	if err != nil {
+		logger.Errorf("Get customHPA[%s] err, quota check skipped: %v", info.TargetHPA, err)
+		return
+	}
+	ceiling, err := computeQuotaCeiling(context.Background(), chpa.Spec.ScaleTargetRef)
+	if err != nil {
+		logger.Errorf("Compute quota ceiling of customHPA[%s] err, quota check skipped: %+v", chpa.Name, err)
+		return
+	}
+	s.setQuotaCeiling(ceiling)
+	if ceiling == nil {
+		metrics.QuotaCeilingReplicas.DeleteLabelValues(chpa.Name)
+		return
+	}
+	metrics.QuotaCeilingReplicas.WithLabelValues(chpa.Name).Set(float64(ceiling.Replicas))
+	for _, strategy := range info.Strategies {
+		if utils.Int32Value(strategy.Spec.MaxReplicas) > ceiling.Replicas {
+			ceiling.ExceededWindows = append(ceiling.ExceededWindows, strategy.ValidTime)
+		}
+	}
+	if len(ceiling.ExceededWindows) > 0 {
+		logger.Warnf("MaxReplicas of windows %v exceeds quota ceiling %d of customHPA[%s] (%s/%s)",
+			ceiling.ExceededWindows, ceiling.Replicas, chpa.Name, ceiling.Quota, ceiling.Resource)
+		recordEvent(chpa, corev1.EventTypeWarning, EventReasonQuotaCeilingExceeded,
+			"MaxReplicas of windows %v exceeds quota ceiling %d limited by %s/%s", ceiling.ExceededWindows,
+			ceiling.Replicas, ceiling.Quota, ceiling.Resource)
+	}
+}
+
+// annotateQuota 更新策略前，计算命名空间配额允许的副本数并记录在目标HPA注解中，
+// 即将更新的 maxReplicas 超出时记录 warning event。计算出错时不做处理
+func (s *StrategyController) annotateQuota(chpa *v1alpha1.CustomedHorizontalPodAutoscaler, window string,
+	spec *v1alpha1.CustomedHorizontalPodAutoscalerSpec) {
+	ceiling, err := computeQuotaCeiling(context.Background(), chpa.Spec.ScaleTargetRef)
+	if err != nil {
+		logger.Errorf("Compute quota ceiling of customHPA[%s] err, quota check skipped: %+v", chpa.Name, err)
+		return
+	}
+	if ceiling == nil {
+		delete(chpa.Annotations, AnnotationQuotaCeiling)
+		delete(chpa.Annotations, AnnotationQuotaExceeded)
+		s.setQuotaCeiling(nil)
+		metrics.QuotaCeilingReplicas.DeleteLabelValues(chpa.Name)
+		return
+	}
+	metrics.QuotaCeilingReplicas.WithLabelValues(chpa.Name).Set(float64(ceiling.Replicas))
+	if chpa.Annotations == nil {
+		chpa.Annotations = map[string]string{}
+	}
+	chpa.Annotations[AnnotationQuotaCeiling] = strconv.Itoa(int(ceiling.Replicas))
+
+	maxReplicas := utils.Int32Value(spec.MaxReplicas)
+	if maxReplicas <= ceiling.Replicas {
+		delete(chpa.Annotations, AnnotationQuotaExceeded)
+	} else {
+		ceiling.ExceededWindows = []string{window}
+		chpa.Annotations[AnnotationQuotaExceeded] = fmt.Sprintf("window=%s,maxReplicas=%d", window, maxReplicas)
+		logger.Warnf("MaxReplicas %d of customHPA[%s] window[%s] exceeds quota ceiling %d (%s/%s)",
+			maxReplicas, chpa.Name, window, ceiling.Replicas, ceiling.Quota, ceiling.Resource)
+		recordEvent(chpa, corev1.EventTypeWarning, EventReasonQuotaCeilingExceeded,
+			"Window[%s] maxReplicas %d exceeds quota ceiling %d limited by %s/%s", window, maxReplicas,
+			ceiling.Replicas, ceiling.Quota, ceiling.Resource)
+	}
+	s.setQuotaCeiling(ceiling)
+}
+
+// computeQuotaCeiling 根据目标负载所在命名空间的 ResourceQuota 剩余配额，及 pod 模板的 request/limit，
+// 计算配额允许的最大副本数，取各配额、各资源中最小的值；没有限制目标负载的配额时返回 nil。
+// 带 scope 的配额不参与计算
+func computeQuotaCeiling(ctx context.Context, ref v1alpha1.ScaleTargetRef) (*QuotaCeiling, error) {
+	quotas, err := k8sclient.GetKubeClientSet().CoreV1().ResourceQuotas(NamespaceDefault).
+		List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "list resource quotas err")
+	}
+	if len(quotas.Items) == 0 {
+		return nil, nil
+	}
+	w, err := getWorkloadReplicas(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	perPod := podQuotaUsage(&w.Template.Spec)
+
+	var ceiling *QuotaCeiling
+	for _, quota := range quotas.Items {
+		if len(quota.Spec.Scopes) > 0 || quota.Spec.ScopeSelector != nil {
+			continue
+		}
+		for name, hard := range quota.Status.Hard {
+			usage, ok := perPod[name]
+			if !ok || usage.IsZero() {
+				continue
+			}
+			used := quota.Status.Used[name]
+			remain := hard.MilliValue() - used.MilliValue()
+			if remain < 0 {
+				remain = 0
+			}
+			replicas := w.Replicas + int32(remain/usage.MilliValue())
+			if ceiling == nil || replicas < ceiling.Replicas {
+				ceiling = &QuotaCeiling{Replicas: replicas, Quota: quota.Name, Resource: string(name),
+					CheckedAt: time.Now()}
+			}
+		}
+	}
+	return ceiling, nil
+}
+
+// podQuotaUsage 单个 pod 占用的配额：pod 数、request 和 limit（未设置 limit 的资源不计入 limits.*）
+func podQuotaUsage(spec *corev1.PodSpec) corev1.ResourceList {
+	usage := corev1.ResourceList{
+		corev1.ResourcePods:               resource.MustParse("1"),
+		corev1.ResourceName("count/pods"): resource.MustParse("1"),
+	}
+	requests := podRequests(spec)
+	limits := corev1.ResourceList{}
+	for _, c := range spec.Containers {
+		addResources(limits, c.Resources.Limits)
+	}
+	for name, quantity := range requests {
+		usage[name] = quantity
+		usage[corev1.ResourceName("requests."+string(name))] = quantity
+	}
+	for name, quantity := range limits {
+		if !strings.Contains(string(name), "/") {
+			usage[corev1.ResourceName("limits."+string(name))] = quantity
+		}
+	}
+	return usage
+}
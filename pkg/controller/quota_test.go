@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+func newTestQuota(name string, hard, used corev1.ResourceList) *corev1.ResourceQuota {
+	return &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: NamespaceDefault},
+		Spec:       corev1.ResourceQuotaSpec{Hard: hard},
+		Status:     corev1.ResourceQuotaStatus{Hard: hard, Used: used},
+	}
+}
+
+func newTestQuotaObjects() []runtime.Object {
+	scoped := newTestQuota("scoped", corev1.ResourceList{corev1.ResourcePods: resource.MustParse("1")},
+		corev1.ResourceList{corev1.ResourcePods: resource.MustParse("1")})
+	scoped.Spec.Scopes = []corev1.ResourceQuotaScope{corev1.ResourceQuotaScopeBestEffort}
+	return []runtime.Object{
+		// 剩余 6 个 pod
+		newTestQuota("pods", corev1.ResourceList{corev1.ResourcePods: resource.MustParse("10")},
+			corev1.ResourceList{corev1.ResourcePods: resource.MustParse("4")}),
+		// 剩余 5 核，每个副本 request 1 核
+		newTestQuota("compute", corev1.ResourceList{
+			corev1.ResourceRequestsCPU: resource.MustParse("8"),
+			corev1.ResourceLimitsCPU:   resource.MustParse("100"),
+		}, corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("3")}),
+		scoped,
+		newTestCapacityDeployment(2),
+	}
+}
+
+func Test_computeQuotaCeiling(t *testing.T) {
+	ref := newTestCustomedHPA("customedhpa01", nil).Spec.ScaleTargetRef
+	setupFakeClientSet([]runtime.Object{newTestCapacityDeployment(2)})
+	if ceiling, err := computeQuotaCeiling(context.Background(), ref); err != nil || ceiling != nil {
+		t.Errorf("computeQuotaCeiling() without quota = %+v, err: %v", ceiling, err)
+	}
+
+	setupFakeClientSet(newTestQuotaObjects())
+	ceiling, err := computeQuotaCeiling(context.Background(), ref)
+	if err != nil || ceiling == nil {
+		t.Fatalf("computeQuotaCeiling() = %+v, err: %v", ceiling, err)
+	}
+	// 当前 2 个副本 + 剩余 cpu 配额可容纳 5 个；pod 模板未设置 limit，不受 limits.cpu 限制
+	if ceiling.Replicas != 7 || ceiling.Quota != "compute" || ceiling.Resource != string(corev1.ResourceRequestsCPU) {
+		t.Errorf("computeQuotaCeiling() = %+v, want 7 limited by compute/requests.cpu", ceiling)
+	}
+}
+
+func TestStrategyController_annotateQuota(t *testing.T) {
+	chpa := newTestCustomedHPA("customedhpa01", nil)
+	recorder := setupFakeClientSet(newTestQuotaObjects(), chpa)
+	s := &StrategyController{targetHPA: "customedhpa01"}
+
+	// 加载策略时校验各时间段
+	info := &StrategiesInfo{TargetHPA: "customedhpa01", Strategies: []Strategy{
+		{ValidTime: "0:00-9:00", Spec: newTestSpec(1, 5)},
+		{ValidTime: "9:00-0:00", Spec: newTestSpec(1, 10)},
+	}}
+	s.validateQuota(info)
+	if s.quotaCeiling == nil || len(s.quotaCeiling.ExceededWindows) != 1 ||
+		s.quotaCeiling.ExceededWindows[0] != "9:00-0:00" {
+		t.Errorf("validateQuota() ceiling = %+v", s.quotaCeiling)
+	}
+	if !hasEvent(drainEvents(recorder), EventReasonQuotaCeilingExceeded) {
+		t.Errorf("expect event %s", EventReasonQuotaCeilingExceeded)
+	}
+
+	spec := newTestSpec(1, 10)
+	s.annotateQuota(chpa, "9:00-0:00", &spec)
+	if chpa.Annotations[AnnotationQuotaCeiling] != "7" ||
+		chpa.Annotations[AnnotationQuotaExceeded] != "window=9:00-0:00,maxReplicas=10" {
+		t.Errorf("annotateQuota() annotations = %v", chpa.Annotations)
+	}
+	if !hasEvent(drainEvents(recorder), EventReasonQuotaCeilingExceeded) {
+		t.Errorf("expect event %s", EventReasonQuotaCeilingExceeded)
+	}
+	// maxReplicas 未超出时不限制，仅记录上限
+	spec = newTestSpec(1, 5)
+	s.annotateQuota(chpa, "0:00-9:00", &spec)
+	if _, ok := chpa.Annotations[AnnotationQuotaExceeded]; ok || utils.Int32Value(spec.MaxReplicas) != 5 {
+		t.Errorf("annotateQuota() within ceiling annotations = %v", chpa.Annotations)
+	}
+
+	// 配额删除后清理注解
+	for _, name := range []string{"pods", "compute", "scoped"} {
+		err := k8sclient.GetKubeClientSet().CoreV1().ResourceQuotas(NamespaceDefault).
+			Delete(context.Background(), name, metav1.DeleteOptions{})
+		if err != nil {
+			t.Fatalf("delete quota[%s] err: %v", name, err)
+		}
+	}
+	s.annotateQuota(chpa, "0:00-9:00", &spec)
+	if _, ok := chpa.Annotations[AnnotationQuotaCeiling]; ok || s.quotaCeiling != nil {
+		t.Errorf("annotateQuota() without quota annotations = %v", chpa.Annotations)
+	}
+}
@@ -0,0 +1,290 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"nanto.io/application-auto-scaling-service/pkg/grm"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	"nanto.io/application-auto-scaling-service/pkg/metrics"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+// resourcesEnabled 配置了 GRM 地址且当前策略包含节点资源配置时，按 minReplicas 申请/释放节点
+func (s *StrategyController) resourcesEnabled() (*grm.Client, *ResourceConf) {
+	info := s.Strategies()
+	client := grm.GetClient()
+	if client == nil || info == nil || info.Resources == nil || s.grmConf == nil {
+		return nil, nil
+	}
+	return client, info.Resources
+}
+
+// requestNodes 向 GRM 申请补齐容量缺口所需的节点；已申请但尚未加入集群的节点也计入容量，避免重复申请
+func (s *StrategyController) requestNodes(ctx context.Context, client *grm.Client, conf *ResourceConf,
+	chpa *v1alpha1.CustomedHorizontalPodAutoscaler, window string, newMin int32, capacity *workloadCapacity) {
+	allocations, err := client.List(ctx, chpa.Name)
+	if err != nil {
+		logger.Errorf("List grm allocations of customHPA[%s] err, skip acquiring nodes: %+v", chpa.Name, err)
+		return
+	}
+	incoming, held := incomingNodes(allocations, capacity.Nodes)
+	schedulable := capacity.Schedulable() + incoming*conf.ReplicasPerNode
+	if newMin <= schedulable {
+		logger.Infof("CustomHPA[%s] is waiting for %d incoming grm nodes", chpa.Name, incoming)
+		return
+	}
+	count := (newMin - schedulable + conf.ReplicasPerNode - 1) / conf.ReplicasPerNode
+	if conf.MaxNodes > 0 && held+count > conf.MaxNodes {
+		count = conf.MaxNodes - held
+		if count <= 0 {
+			logger.Warnf("CustomHPA[%s] already holds %d grm nodes, max %d", chpa.Name, held, conf.MaxNodes)
+			return
+		}
+	}
+	s.acquireNodes(ctx, client, conf, chpa, window, newMin, schedulable, count)
+}
+
+// nodeAcquisition 为提高 minReplicas 向 GRM 申请、尚未交付的节点
+type nodeAcquisition struct {
+	allocationID string
+	window       string
+	flavor       string
+	minReplicas  int32
+	count        int32
+	createdAt    time.Time
+}
+
+// acquireNodes 申请节点，不等待交付，由 trackAcquisitions 定期跟踪；同一天内同一时间段的相同申请只会执行一次
+func (s *StrategyController) acquireNodes(ctx context.Context, client *grm.Client, conf *ResourceConf,
+	chpa *v1alpha1.CustomedHorizontalPodAutoscaler, window string, newMin, capacity, count int32) {
+	req := &grm.AcquireRequest{
+		Owner:  chpa.Name,
+		Flavor: conf.Flavor,
+		Count:  count,
+		Reason: "raise minReplicas of window[" + window + "]",
+	}
+	key := grm.IdempotencyKey("acquire", chpa.Name, window, time.Now().Format("20060102"), newMin, count)
+	logger.Infof("MinReplicas %d of customHPA[%s] exceeds cluster capacity %d, acquire %d %s nodes from grm",
+		newMin, chpa.Name, capacity, count, conf.Flavor)
+	allocation, err := client.Acquire(ctx, req, key)
+	if err != nil {
+		logger.Errorf("Acquire %d nodes for customHPA[%s] err: %+v", count, chpa.Name, err)
+		recordEvent(chpa, corev1.EventTypeWarning, EventReasonNodesAcquireFailed,
+			"Acquire %d %s nodes for minReplicas %d (capacity %d) failed: %v", count, conf.Flavor, newMin,
+			capacity, err)
+		return
+	}
+	acquisition := &nodeAcquisition{allocationID: allocation.ID, window: window, flavor: conf.Flavor,
+		minReplicas: newMin, count: count, createdAt: time.Now()}
+	if allocation.Status == grm.StatusFulfilled {
+		s.recordNodesAcquired(ctx, client, chpa, acquisition, allocation)
+		return
+	}
+	logger.Infof("Grm allocation[%s] of customHPA[%s] is %s, track it until fulfilled", allocation.ID, chpa.Name,
+		allocation.Status)
+	s.mu.Lock()
+	s.acquisitions = append(s.acquisitions, acquisition)
+	s.mu.Unlock()
+}
+
+func (s *StrategyController) recordNodesAcquired(ctx context.Context, client *grm.Client,
+	chpa *v1alpha1.CustomedHorizontalPodAutoscaler, acquisition *nodeAcquisition, allocation *grm.Allocation) {
+	logger.Infof("Acquired nodes %v for customHPA[%s], allocation[%s]", allocation.Nodes, chpa.Name, allocation.ID)
+	recordEvent(chpa, corev1.EventTypeNormal, EventReasonNodesAcquired,
+		"Acquired %d %s nodes for minReplicas %d of window[%s], allocation[%s]", len(allocation.Nodes),
+		acquisition.flavor, acquisition.minReplicas, acquisition.window, allocation.ID)
+	s.refreshGRMNodes(ctx, client, chpa.Name)
+}
+
+// trackAcquisitions 跟踪为提高 minReplicas 申请的节点：交付、失败或超过 acquire_timeout_second 后不再跟踪
+func (s *StrategyController) trackAcquisitions(now time.Time) {
+	s.mu.Lock()
+	acquisitions := s.acquisitions
+	s.mu.Unlock()
+	if len(acquisitions) == 0 {
+		return
+	}
+	client, _ := s.resourcesEnabled()
+	if client == nil {
+		return
+	}
+	ctx := context.Background()
+	chpa, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
+		Get(ctx, s.target(), metav1.GetOptions{})
+	if err != nil {
+		logger.Errorf("Get current customHPA err: %v", err)
+		return
+	}
+
+	timeout := time.Duration(s.grmConf.AcquireTimeoutSecond) * time.Second
+	done := map[string]bool{}
+	for _, acquisition := range acquisitions {
+		allocation, err := client.Get(ctx, acquisition.allocationID)
+		if err != nil && !errors.Is(err, grm.ErrNotFound) {
+			logger.Warnf("Get grm allocation[%s] err: %v", acquisition.allocationID, err)
+			continue
+		}
+		switch {
+		case err == nil && allocation.Status == grm.StatusFulfilled:
+			s.recordNodesAcquired(ctx, client, chpa, acquisition, allocation)
+		case err != nil || allocation.Status == grm.StatusFailed || allocation.Status == grm.StatusReleased:
+			reason := "not found"
+			if err == nil {
+				reason = allocation.Status + ": " + allocation.Message
+			}
+			logger.Warnf("Grm allocation[%s] of customHPA[%s] is %s", acquisition.allocationID, chpa.Name, reason)
+			recordEvent(chpa, corev1.EventTypeWarning, EventReasonNodesAcquireFailed,
+				"Acquire %d %s nodes for minReplicas %d of window[%s] failed, allocation[%s] is %s",
+				acquisition.count, acquisition.flavor, acquisition.minReplicas, acquisition.window,
+				acquisition.allocationID, reason)
+		case now.Sub(acquisition.createdAt) > timeout:
+			logger.Warnf("Grm allocation[%s] of customHPA[%s] is not fulfilled in %s", acquisition.allocationID,
+				chpa.Name, timeout)
+			recordEvent(chpa, corev1.EventTypeWarning, EventReasonNodesAcquireFailed,
+				"Acquire %d %s nodes for minReplicas %d of window[%s] timed out, allocation[%s] is %s",
+				acquisition.count, acquisition.flavor, acquisition.minReplicas, acquisition.window,
+				acquisition.allocationID, allocation.Status)
+		default:
+			continue
+		}
+		done[acquisition.allocationID] = true
+	}
+
+	// 跟踪期间可能有新的申请（定时任务在其他 goroutine 执行），只移除已结束的
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	remain := make([]*nodeAcquisition, 0, len(s.acquisitions))
+	for _, acquisition := range s.acquisitions {
+		if !done[acquisition.allocationID] {
+			remain = append(remain, acquisition)
+		}
+	}
+	s.acquisitions = remain
+}
+
+func (s *StrategyController) scheduleRelease(at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releaseAt = at
+}
+
+// releaseSurplusNodes 到达延迟释放时间后，选出超出当前所需副本数的 GRM 节点，封锁、驱逐后释放；
+// 已有进行中的释放流程时等待其结束
+func (s *StrategyController) releaseSurplusNodes(now time.Time) {
+	s.mu.Lock()
+	releaseAt := s.releaseAt
+	s.mu.Unlock()
+	if releaseAt.IsZero() || now.Before(releaseAt) || s.activeNodeRelease() != nil {
+		return
+	}
+	s.scheduleRelease(time.Time{})
+	client, conf := s.resourcesEnabled()
+	if client == nil {
+		return
+	}
+
+	ctx := context.Background()
+	chpa, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
+		Get(ctx, s.target(), metav1.GetOptions{})
+	if err != nil {
+		logger.Errorf("Get current customHPA err: %v", err)
+		return
+	}
+	allocations, held, err := heldAllocations(ctx, client, chpa.Name)
+	if err != nil || held == 0 {
+		if err != nil {
+			logger.Errorf("List grm allocations of customHPA[%s] err: %+v", chpa.Name, err)
+		}
+		return
+	}
+	// 所需副本数取 minReplicas 与目标负载当前副本数的较大值，避免释放仍在运行负载的节点
+	required := utils.Int32Value(chpa.Spec.MinReplicas)
+	if w, err := getWorkloadReplicas(ctx, chpa.Spec.ScaleTargetRef); err != nil {
+		logger.Errorf("Get workload replicas of customHPA[%s] err, skip releasing nodes: %+v", chpa.Name, err)
+		return
+	} else if w.Replicas > required {
+		required = w.Replicas
+	}
+	c, err := computeCapacity(ctx, chpa.Spec.ScaleTargetRef)
+	if err != nil {
+		logger.Errorf("Compute cluster capacity of customHPA[%s] err, skip releasing nodes: %+v", chpa.Name, err)
+		return
+	}
+	capacity := c.Schedulable()
+	surplus := (capacity - required) / conf.ReplicasPerNode
+	if surplus > held {
+		surplus = held
+	}
+	if surplus <= 0 {
+		return
+	}
+
+	nodes, err := pickReleaseNodes(ctx, allocations, surplus)
+	if err != nil {
+		logger.Errorf("Pick nodes to release of customHPA[%s] err: %+v", chpa.Name, err)
+		return
+	}
+	if len(nodes) == 0 {
+		logger.Infof("CustomHPA[%s] has %d surplus grm nodes, but none can be released", chpa.Name, surplus)
+		return
+	}
+	s.startNodeRelease(chpa, nodes, required, capacity, now)
+	s.advanceNodeRelease(now)
+}
+
+// incomingNodes 统计尚未加入集群（或尚不可调度）的已申请节点数（含申请中的节点），及持有的节点总数
+func incomingNodes(allocations []grm.Allocation, schedulable map[string]bool) (incoming, held int32) {
+	for _, allocation := range allocations {
+		switch allocation.Status {
+		case grm.StatusPending:
+			incoming += allocation.Count
+			held += allocation.Count
+		case grm.StatusFulfilled:
+			for _, node := range allocation.Nodes {
+				if !schedulable[node] {
+					incoming++
+				}
+			}
+			held += int32(len(allocation.Nodes))
+		}
+	}
+	return incoming, held
+}
+
+// heldAllocations 申请方已交付且未释放节点的申请记录（按申请时间升序），及持有的节点数
+func heldAllocations(ctx context.Context, client *grm.Client, owner string) ([]grm.Allocation, int32, error) {
+	allocations, err := client.List(ctx, owner)
+	if err != nil {
+		return nil, 0, err
+	}
+	held := make([]grm.Allocation, 0, len(allocations))
+	var count int32
+	for _, allocation := range allocations {
+		if allocation.Status == grm.StatusFulfilled && len(allocation.Nodes) > 0 {
+			held = append(held, allocation)
+			count += int32(len(allocation.Nodes))
+		}
+	}
+	sortAllocations(held)
+	return held, count, nil
+}
+
+// refreshGRMNodes 更新持有的 GRM 节点数指标
+func (s *StrategyController) refreshGRMNodes(ctx context.Context, client *grm.Client, owner string) {
+	if _, held, err := heldAllocations(ctx, client, owner); err == nil {
+		metrics.GRMNodes.WithLabelValues(owner).Set(float64(held))
+	}
+}
+
+func sortAllocations(allocations []grm.Allocation) {
+	sort.SliceStable(allocations, func(i, j int) bool {
+		return allocations[i].CreatedAt.Before(allocations[j].CreatedAt)
+	})
+}
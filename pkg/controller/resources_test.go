@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/grm"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+// newTestNode 2 核 8G 的节点
+func newTestNode(name string, ready bool) *corev1.Node {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"pool": "worker"}},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}},
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("2"),
+				corev1.ResourceMemory: resource.MustParse("8Gi"),
+				corev1.ResourcePods:   resource.MustParse("110"),
+			},
+		},
+	}
+}
+
+// newTestCapacityDeployment pod 模板 request 为 1 核 1G 的负载，2 核 8G 的节点可容纳 2 个副本
+func newTestCapacityDeployment(replicas int32) *appsv1.Deployment {
+	d := newTestDeployment(replicas, replicas)
+	d.Spec.Template.Labels = map[string]string{"app": "worker"}
+	d.Spec.Template.Spec.Containers = []corev1.Container{{Name: "worker", Resources: corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("1"),
+			corev1.ResourceMemory: resource.MustParse("1Gi"),
+		},
+	}}}
+	return d
+}
+
+// setupTestGRM 启动 fake GRM 并初始化全局客户端
+func setupTestGRM(t *testing.T) *grm.FakeServer {
+	fake := grm.NewFakeServer()
+	srv := httptest.NewServer(fake)
+	c, err := grm.NewClient(&config.GRMConf{Endpoint: srv.URL, TimeoutSecond: 1, RetryIntervalMillisecond: 1},
+		"cluster-1")
+	if err != nil {
+		t.Fatalf("grm.NewClient() err: %+v", err)
+	}
+	grm.SetClient(c)
+	t.Cleanup(func() {
+		grm.SetClient(nil)
+		srv.Close()
+	})
+	return fake
+}
+
+func newTestResourcesController(resources *ResourceConf) *StrategyController {
+	return &StrategyController{
+		targetHPA:      "customedhpa01",
+		strategiesInfo: &StrategiesInfo{TargetHPA: "customedhpa01", Resources: resources},
+		grmConf: &config.GRMConf{AcquireTimeoutSecond: 1, PollIntervalSecond: 1,
+			ReleaseDelaySecond: 600, CordonTimeoutSecond: 60, DrainTimeoutSecond: 600, ReleaseTimeoutSecond: 300},
+	}
+}
+
+func TestStrategyController_requestNodes(t *testing.T) {
+	fake := setupTestGRM(t)
+	fake.Pending = true
+	chpa := newTestCustomedHPA("customedhpa01", nil)
+	chpa.Spec.MinReplicas = utils.Int32Ptr(3)
+	recorder := setupFakeClientSet([]runtime.Object{
+		newTestNode("node-1", true), newTestNode("node-2", true), newTestNode("node-3", false),
+		newTestCapacityDeployment(0),
+	}, chpa)
+	s := newTestResourcesController(&ResourceConf{Flavor: "c6.2xlarge", ReplicasPerNode: 2, MaxNodes: 3})
+
+	// 容量 2 个节点 × 2 = 4，minReplicas 提高到 7 需要申请 2 个节点
+	spec := newTestSpec(7, 10)
+	s.checkCapacity(chpa, "8:00-9:00", &spec)
+	if len(fake.Allocations) != 1 || fake.Allocations[0].Count != 2 || fake.Allocations[0].Status != grm.StatusPending {
+		t.Fatalf("checkCapacity() allocations = %+v", fake.Allocations)
+	}
+	// 不等待交付，按要求的 minReplicas 更新
+	if utils.Int32Value(spec.MinReplicas) != 7 || len(s.acquisitions) != 1 {
+		t.Errorf("checkCapacity() minReplicas = %d, acquisitions = %d", utils.Int32Value(spec.MinReplicas),
+			len(s.acquisitions))
+	}
+	if hasEvent(drainEvents(recorder), EventReasonNodesAcquired) {
+		t.Errorf("unexpected event %s before fulfilled", EventReasonNodesAcquired)
+	}
+	// 定期跟踪，交付后不再跟踪
+	s.trackAcquisitions(time.Now())
+	if !hasEvent(drainEvents(recorder), EventReasonNodesAcquired) || len(s.acquisitions) != 0 {
+		t.Errorf("trackAcquisitions() expect event %s, acquisitions = %d", EventReasonNodesAcquired,
+			len(s.acquisitions))
+	}
+
+	// 同一时间段重复执行不重复申请，申请的节点尚未加入集群时计入容量
+	s.checkCapacity(chpa, "8:00-9:00", &spec)
+	if len(fake.Allocations) != 1 {
+		t.Errorf("checkCapacity() again allocations = %d, want 1", len(fake.Allocations))
+	}
+
+	// 最多持有 3 个节点，只能再申请 1 个
+	spec = newTestSpec(11, 12)
+	s.checkCapacity(chpa, "9:00-10:00", &spec)
+	if len(fake.Allocations) != 2 || fake.Allocations[1].Count != 1 {
+		t.Errorf("checkCapacity() with max nodes allocations = %+v", fake.Allocations)
+	}
+
+	// 降低 minReplicas 时延迟释放
+	spec = newTestSpec(1, 2)
+	s.checkCapacity(chpa, "10:00-11:00", &spec)
+	if s.releaseAt.IsZero() {
+		t.Errorf("checkCapacity() scale down should schedule release")
+	}
+}
+
+func TestStrategyController_releaseSurplusNodes(t *testing.T) {
+	fake := setupTestGRM(t)
+	fake.AddAllocation("customedhpa01", "grm-node-1")
+	fake.AddAllocation("customedhpa01", "grm-node-2", "grm-node-3")
+	chpa := newTestCustomedHPA("customedhpa01", nil)
+	chpa.Spec.MinReplicas = utils.Int32Ptr(2)
+	recorder := setupFakeClientSet([]runtime.Object{
+		newTestNode("node-1", true), newTestNode("grm-node-1", true), newTestNode("grm-node-2", true),
+		newTestNode("grm-node-3", true), newTestCapacityDeployment(3),
+	}, chpa)
+	s := newTestResourcesController(&ResourceConf{Flavor: "c6.2xlarge", ReplicasPerNode: 2})
+
+	now := time.Now()
+	s.scheduleRelease(now.Add(time.Minute))
+	s.releaseSurplusNodes(now)
+	if len(fake.Allocations[1].Nodes) != 2 {
+		t.Errorf("releaseSurplusNodes() before release time released nodes")
+	}
+
+	// 容量 4 个节点 × 2 = 8，负载当前 3 个副本，可释放 2 个节点，节点上没有 pod 时优先释放最近申请的节点
+	s.releaseSurplusNodes(now.Add(time.Minute))
+	if fake.Allocations[1].Status != grm.StatusReleased || len(fake.Allocations[0].Nodes) != 1 {
+		t.Errorf("releaseSurplusNodes() allocations = %+v, %+v", *fake.Allocations[0], *fake.Allocations[1])
+	}
+	if !hasEvent(drainEvents(recorder), EventReasonNodesReleased) {
+		t.Errorf("expect event %s", EventReasonNodesReleased)
+	}
+	if !s.releaseAt.IsZero() {
+		t.Errorf("releaseSurplusNodes() should clear release time")
+	}
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+)
+
+// RollbackStrategy 将目标HPA的 spec 恢复为历史记录中的 spec，并暂停定时策略直到手动恢复（删除暂停注解）。
+// revision 为 0 时恢复为最近一次更新前的 spec，否则恢复为指定序号更新后的 spec
+func RollbackStrategy(history *HistoryStore, target string, revision int) (*ApplyRecord, error) {
+	var (
+		restoreSpec  v1alpha1.CustomedHorizontalPodAutoscalerSpec
+		rollbackFrom string
+	)
+	if revision == 0 {
+		records, err := history.List(target)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, errors.Errorf("no apply history of target[%s]", target)
+		}
+		latest := records[len(records)-1]
+		restoreSpec = latest.PreviousSpec
+		rollbackFrom = fmt.Sprintf("previous spec of revision %d", latest.Revision)
+	} else {
+		record, err := history.Get(target, revision)
+		if err != nil {
+			return nil, err
+		}
+		restoreSpec = record.Spec
+		rollbackFrom = fmt.Sprintf("revision %d", revision)
+	}
+
+	chpaCli := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault)
+	var (
+		update   *v1alpha1.CustomedHorizontalPodAutoscaler
+		prevSpec *v1alpha1.CustomedHorizontalPodAutoscalerSpec
+	)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ctx := context.Background()
+		chpa, err := chpaCli.Get(ctx, target, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "get customHPA[%s] err", target)
+		}
+		prevSpec = chpa.Spec.DeepCopy()
+		newSpec := restoreSpec.DeepCopy()
+		newSpec.ScaleTargetRef = chpa.Spec.ScaleTargetRef
+		newSpec.DeepCopyInto(&chpa.Spec)
+		// 回滚后暂停定时策略，避免下个时间段覆盖回滚结果
+		if chpa.Annotations == nil {
+			chpa.Annotations = map[string]string{}
+		}
+		chpa.Annotations[AnnotationPaused] = "true"
+		update, err = chpaCli.Update(ctx, chpa, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "rollback customHPA[%s] to %s err", target, rollbackFrom)
+	}
+	logger.Infof("Rollback customHPA[%s] to %s success, strategy schedule paused", target, rollbackFrom)
+	recordEvent(update, corev1.EventTypeNormal, EventReasonStrategyRolledBack,
+		"Rollback to %s, scheduled strategy paused until annotation %s is removed", rollbackFrom, AnnotationPaused)
+
+	record := &ApplyRecord{
+		Time:         time.Now(),
+		RollbackFrom: rollbackFrom,
+		Spec:         update.Spec,
+		PreviousSpec: *prevSpec,
+	}
+	if err = history.Append(target, record); err != nil {
+		return nil, errors.Wrap(err, "append rollback record err")
+	}
+	return record, nil
+}
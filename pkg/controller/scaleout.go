@@ -0,0 +1,427 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+
+	"nanto.io/application-auto-scaling-service/pkg/grm"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	"nanto.io/application-auto-scaling-service/pkg/metrics"
+)
+
+// NodeRequest 因目标负载 pod Pending 向 GRM 申请、尚未全部加入集群的节点
+type NodeRequest struct {
+	AllocationID string `json:"allocationId"`
+	Flavor       string `json:"flavor"`
+	Count        int32  `json:"count"`
+	// 触发申请的 Pending pod，节点加入集群前这些 pod 不会重复触发申请
+	Pods []string `json:"pods"`
+	// GRM 申请记录的状态，及已交付的节点
+	Status    string    `json:"status"`
+	Nodes     []string  `json:"nodes,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// allocatable 节点规格模板的可分配资源
+func (t *NodeTemplate) allocatable() (corev1.ResourceList, error) {
+	list := corev1.ResourceList{}
+	for name, value := range map[corev1.ResourceName]string{corev1.ResourceCPU: t.CPU,
+		corev1.ResourceMemory: t.Memory} {
+		if value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s[%s]", name, value)
+		}
+		list[name] = quantity
+	}
+	if t.Pods > 0 {
+		list[corev1.ResourcePods] = *resource.NewQuantity(int64(t.Pods), resource.DecimalSI)
+	}
+	return list, nil
+}
+
+// startPodInformer 启动 pod informer，目标命名空间有 pod 因资源不足无法调度时通知扩容检查（合并连续的通知）
+func (s *StrategyController) startPodInformer(ctx context.Context) <-chan struct{} {
+	triggerCh := make(chan struct{}, 1)
+	factory := informers.NewSharedInformerFactoryWithOptions(k8sclient.GetKubeClientSet(), 0,
+		informers.WithNamespace(NamespaceDefault))
+	podInformer := factory.Core().V1().Pods()
+	notify := func(obj interface{}) {
+		if pod, ok := obj.(*corev1.Pod); ok && isUnschedulableForResources(pod) {
+			select {
+			case triggerCh <- struct{}{}:
+			default:
+			}
+		}
+	}
+	podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    notify,
+		UpdateFunc: func(_, obj interface{}) { notify(obj) },
+	})
+	factory.Start(ctx.Done())
+	factory.WaitForCacheSync(ctx.Done())
+	// 定时任务与 HTTP 请求可能同时读取，缓存同步后再在锁内设置
+	s.mu.Lock()
+	s.podLister = podInformer.Lister()
+	s.mu.Unlock()
+	return triggerCh
+}
+
+func (s *StrategyController) getNodeRequests() []NodeRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := make([]NodeRequest, 0, len(s.nodeRequests))
+	for _, req := range s.nodeRequests {
+		requests = append(requests, *req)
+	}
+	return requests
+}
+
+// scaleOutPendingPods 目标负载的 pod 因资源不足 Pending 超过阈值时，按 pod 的 request 和节点规格模板计算
+// 所需的节点数，向 GRM 申请节点。已被未加入集群的申请覆盖的 pod 不重复申请，两次申请之间至少间隔 minIntervalSeconds
+func (s *StrategyController) scaleOutPendingPods(now time.Time) {
+	client, conf := s.resourcesEnabled()
+	if client == nil || conf.ScaleOut == nil {
+		return
+	}
+	ctx := context.Background()
+	chpa, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
+		Get(ctx, s.target(), metav1.GetOptions{})
+	if err != nil {
+		logger.Errorf("Get current customHPA err: %v", err)
+		return
+	}
+	s.trackNodeRequests(ctx, client, chpa, now, time.Duration(conf.ScaleOut.JoinTimeoutSeconds)*time.Second)
+
+	pods, err := s.listPendingPods(ctx, chpa.Spec.ScaleTargetRef)
+	if err != nil {
+		logger.Errorf("List pending pods of customHPA[%s] err: %+v", chpa.Name, err)
+		return
+	}
+	metrics.PendingPods.WithLabelValues(chpa.Name).Set(float64(len(pods)))
+	pods = s.uncoveredPods(pods, now, time.Duration(conf.ScaleOut.PendingSeconds)*time.Second)
+	if len(pods) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	lastScaleOut := s.lastScaleOut
+	s.mu.Unlock()
+	if interval := time.Duration(conf.ScaleOut.MinIntervalSeconds) * time.Second; now.Sub(lastScaleOut) < interval {
+		logger.Infof("%d pods of customHPA[%s] are pending, last node request at %s, wait for %s", len(pods),
+			chpa.Name, lastScaleOut.Format(time.RFC3339), interval)
+		return
+	}
+
+	flavor, count, err := planNodes(pods, conf)
+	if err != nil {
+		logger.Errorf("Plan nodes for %d pending pods of customHPA[%s] err: %+v", len(pods), chpa.Name, err)
+		recordEvent(chpa, corev1.EventTypeWarning, EventReasonNodeScaleOutFailed,
+			"Plan nodes for %d pending pods failed: %v", len(pods), err)
+		return
+	}
+	allocations, err := client.List(ctx, chpa.Name)
+	if err != nil {
+		logger.Errorf("List grm allocations of customHPA[%s] err, skip scale out: %+v", chpa.Name, err)
+		return
+	}
+	schedulable, err := schedulableNodes(ctx)
+	if err != nil {
+		logger.Errorf("Skip scale out of customHPA[%s]: %v", chpa.Name, err)
+		return
+	}
+	// 其他途径（如提高 minReplicas 时）申请、尚未加入集群的节点也会容纳 Pending 的 pod，只申请差额；
+	// 跟踪中的申请已按 pod 去重，不重复计入
+	joinTimeout := time.Duration(conf.ScaleOut.JoinTimeoutSeconds) * time.Second
+	incoming, _ := incomingNodes(untrackedAllocations(allocations, s.getNodeRequests(), now.Add(-joinTimeout)),
+		schedulable)
+	if count -= incoming; count <= 0 {
+		logger.Infof("%d pods of customHPA[%s] are pending, wait for %d incoming grm nodes", len(pods), chpa.Name,
+			incoming)
+		return
+	}
+	if _, held := incomingNodes(allocations, nil); conf.MaxNodes > 0 && held+count > conf.MaxNodes {
+		count = conf.MaxNodes - held
+		if count <= 0 {
+			logger.Warnf("CustomHPA[%s] already holds max %d grm nodes, %d pods stay pending", chpa.Name,
+				conf.MaxNodes, len(pods))
+			return
+		}
+	}
+	s.requestScaleOut(ctx, client, chpa, pods, flavor, count, now)
+}
+
+// requestScaleOut 申请节点并跟踪，幂等 key 由触发申请的 pod 及申请时间决定
+func (s *StrategyController) requestScaleOut(ctx context.Context, client *grm.Client,
+	chpa *v1alpha1.CustomedHorizontalPodAutoscaler, pods []*corev1.Pod, flavor string, count int32, now time.Time) {
+	names := make([]string, 0, len(pods))
+	uids := make([]interface{}, 0, len(pods)+2)
+	uids = append(uids, "scale-out", now.Unix())
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+		uids = append(uids, pod.UID)
+	}
+	req := &grm.AcquireRequest{Owner: chpa.Name, Flavor: flavor, Count: count,
+		Reason: "pending pods: " + strings.Join(names, ",")}
+	allocation, err := client.Acquire(ctx, req, grm.IdempotencyKey(uids...))
+	s.mu.Lock()
+	s.lastScaleOut = now
+	s.mu.Unlock()
+	if err != nil {
+		logger.Errorf("Request %d %s nodes for pending pods %v err: %+v", count, flavor, names, err)
+		metrics.NodeScaleOutTotal.WithLabelValues(chpa.Name, "error").Inc()
+		recordEvent(chpa, corev1.EventTypeWarning, EventReasonNodeScaleOutFailed,
+			"Request %d %s nodes for %d pending pods failed: %v", count, flavor, len(pods), err)
+		return
+	}
+
+	logger.Infof("Requested %d %s nodes for pending pods %v of customHPA[%s], allocation[%s]", count, flavor, names,
+		chpa.Name, allocation.ID)
+	metrics.NodeScaleOutTotal.WithLabelValues(chpa.Name, "success").Inc()
+	recordEvent(chpa, corev1.EventTypeNormal, EventReasonNodeScaleOut,
+		"Request %d %s nodes for %d pending pods, allocation[%s]", count, flavor, len(pods), allocation.ID)
+	s.mu.Lock()
+	s.nodeRequests = append(s.nodeRequests, &NodeRequest{AllocationID: allocation.ID, Flavor: flavor, Count: count,
+		Pods: names, Status: allocation.Status, Nodes: allocation.Nodes, CreatedAt: now})
+	s.mu.Unlock()
+}
+
+// trackNodeRequests 刷新跟踪中的申请：节点全部加入集群、申请失败或超时后不再跟踪
+func (s *StrategyController) trackNodeRequests(ctx context.Context, client *grm.Client,
+	chpa *v1alpha1.CustomedHorizontalPodAutoscaler, now time.Time, joinTimeout time.Duration) {
+	s.mu.Lock()
+	requests := s.nodeRequests
+	s.mu.Unlock()
+	if len(requests) == 0 {
+		return
+	}
+	ready, err := schedulableNodes(ctx)
+	if err != nil {
+		logger.Errorf("Track node requests err: %v", err)
+		return
+	}
+
+	remain := make([]*NodeRequest, 0, len(requests))
+	for _, req := range requests {
+		allocation, err := client.Get(ctx, req.AllocationID)
+		if err != nil && !errors.Is(err, grm.ErrNotFound) {
+			logger.Warnf("Get grm allocation[%s] err: %v", req.AllocationID, err)
+			remain = append(remain, req)
+			continue
+		}
+		switch {
+		case err != nil || allocation.Status == grm.StatusFailed || allocation.Status == grm.StatusReleased:
+			reason := "not found"
+			if err == nil {
+				reason = allocation.Status + ": " + allocation.Message
+			}
+			logger.Warnf("Grm allocation[%s] for pending pods %v is %s", req.AllocationID, req.Pods, reason)
+			recordEvent(chpa, corev1.EventTypeWarning, EventReasonNodeScaleOutFailed,
+				"Allocation[%s] of %d %s nodes is %s", req.AllocationID, req.Count, req.Flavor, reason)
+		case allocation.Status == grm.StatusFulfilled && allNodesReady(allocation.Nodes, ready):
+			logger.Infof("Nodes %v of allocation[%s] joined the cluster", allocation.Nodes, req.AllocationID)
+		case now.Sub(req.CreatedAt) > joinTimeout:
+			logger.Warnf("Nodes of allocation[%s] did not join the cluster in %s", req.AllocationID, joinTimeout)
+			recordEvent(chpa, corev1.EventTypeWarning, EventReasonNodeScaleOutFailed,
+				"Nodes %v of allocation[%s] did not join the cluster in %s", allocation.Nodes, req.AllocationID,
+				joinTimeout)
+		default:
+			updated := *req
+			updated.Status, updated.Nodes = allocation.Status, allocation.Nodes
+			remain = append(remain, &updated)
+		}
+	}
+	s.mu.Lock()
+	s.nodeRequests = remain
+	s.mu.Unlock()
+}
+
+// schedulableNodes 集群中各节点是否可调度
+func schedulableNodes(ctx context.Context) (map[string]bool, error) {
+	nodes, err := k8sclient.GetKubeClientSet().CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "list nodes err")
+	}
+	schedulable := make(map[string]bool, len(nodes.Items))
+	for i := range nodes.Items {
+		schedulable[nodes.Items[i].Name] = isNodeSchedulable(&nodes.Items[i])
+	}
+	return schedulable, nil
+}
+
+// untrackedAllocations 不在跟踪中、且在 since 之后创建的申请记录；更早的申请视为节点无法加入集群，不再计入
+func untrackedAllocations(allocations []grm.Allocation, requests []NodeRequest, since time.Time) []grm.Allocation {
+	tracked := map[string]bool{}
+	for _, req := range requests {
+		tracked[req.AllocationID] = true
+	}
+	result := make([]grm.Allocation, 0, len(allocations))
+	for _, allocation := range allocations {
+		if !tracked[allocation.ID] && allocation.CreatedAt.After(since) {
+			result = append(result, allocation)
+		}
+	}
+	return result
+}
+
+func allNodesReady(nodes []string, ready map[string]bool) bool {
+	if len(nodes) == 0 {
+		return false
+	}
+	for _, node := range nodes {
+		if !ready[node] {
+			return false
+		}
+	}
+	return true
+}
+
+// listPendingPods 目标负载中因资源不足无法调度的 pod
+func (s *StrategyController) listPendingPods(ctx context.Context, ref v1alpha1.ScaleTargetRef) ([]*corev1.Pod,
+	error) {
+	pods, err := s.listWorkloadPods(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]*corev1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if isUnschedulableForResources(pod) {
+			pending = append(pending, pod)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Name < pending[j].Name })
+	return pending, nil
+}
+
+// uncoveredPods 过滤出 Pending 超过阈值、且未被跟踪中的申请覆盖的 pod
+func (s *StrategyController) uncoveredPods(pods []*corev1.Pod, now time.Time, pendingFor time.Duration) []*corev1.Pod {
+	covered := map[string]bool{}
+	for _, req := range s.getNodeRequests() {
+		for _, name := range req.Pods {
+			covered[name] = true
+		}
+	}
+	result := make([]*corev1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if !covered[pod.Name] && now.Sub(unschedulableSince(pod)) >= pendingFor {
+			result = append(result, pod)
+		}
+	}
+	return result
+}
+
+// isUnschedulableForResources pod 因 cpu、内存或 pod 数不足无法调度
+func isUnschedulableForResources(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodPending || pod.DeletionTimestamp != nil {
+		return false
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionFalse &&
+			cond.Reason == corev1.PodReasonUnschedulable {
+			return strings.Contains(cond.Message, "Insufficient") || strings.Contains(cond.Message, "Too many pods")
+		}
+	}
+	return false
+}
+
+// unschedulableSince pod 无法调度的起始时间
+func unschedulableSince(pod *corev1.Pod) time.Time {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodScheduled && !cond.LastTransitionTime.IsZero() {
+			return cond.LastTransitionTime.Time
+		}
+	}
+	return pod.CreationTimestamp.Time
+}
+
+// planNodes 计算容纳 Pending pod 所需的节点规格和数量：按顺序选择第一个能容纳单个 pod 的节点规格模板，
+// 按 request 从大到小首次适应装箱；未配置模板时按 flavor 和 replicasPerNode 计算
+func planNodes(pods []*corev1.Pod, conf *ResourceConf) (string, int32, error) {
+	if len(conf.NodeTemplates) == 0 {
+		return conf.Flavor, (int32(len(pods)) + conf.ReplicasPerNode - 1) / conf.ReplicasPerNode, nil
+	}
+	requests := make([]corev1.ResourceList, 0, len(pods))
+	for _, pod := range pods {
+		requests = append(requests, podRequests(&pod.Spec))
+	}
+	sort.SliceStable(requests, func(i, j int) bool {
+		ci, cj := requests[i][corev1.ResourceCPU], requests[j][corev1.ResourceCPU]
+		if c := ci.Cmp(cj); c != 0 {
+			return c > 0
+		}
+		mi, mj := requests[i][corev1.ResourceMemory], requests[j][corev1.ResourceMemory]
+		return mi.Cmp(mj) > 0
+	})
+
+	for i := range conf.NodeTemplates {
+		template := &conf.NodeTemplates[i]
+		allocatable, err := template.allocatable()
+		if err != nil {
+			return "", 0, err
+		}
+		if !fitsInto(requests[0], allocatable) {
+			continue
+		}
+		var bins []corev1.ResourceList
+		for _, req := range requests {
+			placed := false
+			for _, bin := range bins {
+				if fitsInto(req, bin) {
+					subtractPod(bin, req)
+					placed = true
+					break
+				}
+			}
+			if !placed {
+				bin := allocatable.DeepCopy()
+				subtractPod(bin, req)
+				bins = append(bins, bin)
+			}
+		}
+		return template.Flavor, int32(len(bins)), nil
+	}
+	return "", 0, errors.Errorf("no node template fits pod requests %v", requests[0])
+}
+
+// fitsInto 剩余资源能容纳 pod 的 cpu、内存 request 及 pod 数
+func fitsInto(req, remain corev1.ResourceList) bool {
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		quantity, ok := req[name]
+		if !ok {
+			continue
+		}
+		if free, ok := remain[name]; ok && quantity.Cmp(free) > 0 {
+			return false
+		}
+	}
+	if pods, ok := remain[corev1.ResourcePods]; ok && pods.Value() < 1 {
+		return false
+	}
+	return true
+}
+
+func subtractPod(remain, req corev1.ResourceList) {
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		if quantity, ok := req[name]; ok {
+			if free, ok := remain[name]; ok {
+				free.Sub(quantity)
+				remain[name] = free
+			}
+		}
+	}
+	if pods, ok := remain[corev1.ResourcePods]; ok {
+		remain[corev1.ResourcePods] = *resource.NewQuantity(pods.Value()-1, resource.DecimalSI)
+	}
+}
@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+)
+
+// newTestPendingPod 请求 cpu 核、1G 内存，since 起因资源不足无法调度的 pod
+func newTestPendingPod(name, cpu, message string, since time.Time) *corev1.Pod {
+	pod := newTestPod(name, corev1.PodPending, true)
+	pod.UID = types.UID("uid-" + name)
+	pod.Status.Conditions[0].Message = message
+	pod.Status.Conditions[0].LastTransitionTime = metav1.NewTime(since)
+	pod.Spec.Containers = []corev1.Container{{Name: "worker", Resources: corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse("1Gi"),
+		},
+	}}}
+	return pod
+}
+
+func newTestScaleOutConf(maxNodes int32) *ResourceConf {
+	return &ResourceConf{Flavor: "c6.2xlarge", ReplicasPerNode: 2, MaxNodes: maxNodes,
+		NodeTemplates: []NodeTemplate{
+			{Flavor: "c6.large", CPU: "1", Memory: "512Mi"},
+			{Flavor: "c6.xlarge", CPU: "2", Memory: "8Gi", Pods: 110},
+		},
+		ScaleOut: &ScaleOutConf{PendingSeconds: 30, MinIntervalSeconds: 120, JoinTimeoutSeconds: 900},
+	}
+}
+
+func Test_planNodes(t *testing.T) {
+	now := time.Now()
+	insufficient := "0/3 nodes are available: 3 Insufficient cpu."
+	tests := []struct {
+		name       string
+		pods       []*corev1.Pod
+		conf       *ResourceConf
+		wantFlavor string
+		wantCount  int32
+		wantErr    bool
+	}{
+		{
+			name: "bin packing with first fitting template",
+			pods: []*corev1.Pod{newTestPendingPod("p1", "1", insufficient, now),
+				newTestPendingPod("p2", "500m", insufficient, now), newTestPendingPod("p3", "1500m", insufficient, now)},
+			conf:       newTestScaleOutConf(0),
+			wantFlavor: "c6.xlarge",
+			wantCount:  2,
+		},
+		{
+			name:       "without templates",
+			pods:       []*corev1.Pod{newTestPendingPod("p1", "1", insufficient, now), newTestPendingPod("p2", "1", insufficient, now), newTestPendingPod("p3", "1", insufficient, now)},
+			conf:       &ResourceConf{Flavor: "c6.2xlarge", ReplicasPerNode: 2},
+			wantFlavor: "c6.2xlarge",
+			wantCount:  2,
+		},
+		{
+			name:    "no template fits",
+			pods:    []*corev1.Pod{newTestPendingPod("p1", "4", insufficient, now)},
+			conf:    newTestScaleOutConf(0),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flavor, count, err := planNodes(tt.pods, tt.conf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("planNodes() err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if flavor != tt.wantFlavor || count != tt.wantCount {
+				t.Errorf("planNodes() = %s, %d, want %s, %d", flavor, count, tt.wantFlavor, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestStrategyController_scaleOutPendingPods(t *testing.T) {
+	fake := setupTestGRM(t)
+	fake.Pending = true
+	now := time.Now()
+	insufficient := "0/3 nodes are available: 3 Insufficient cpu."
+	recorder := setupFakeClientSet([]runtime.Object{
+		newTestNode("node-1", true), newTestCapacityDeployment(5),
+		newTestPendingPod("p1", "1", insufficient, now.Add(-time.Minute)),
+		newTestPendingPod("p2", "1", "0/3 nodes are available: 3 Too many pods.", now.Add(-time.Minute)),
+		newTestPendingPod("p3", "1", insufficient, now.Add(-time.Minute)),
+		// 刚刚无法调度、或非资源不足原因无法调度的 pod 不触发申请
+		newTestPendingPod("p4", "1", insufficient, now),
+		newTestPendingPod("p5", "1", "0/3 nodes are available: 3 node(s) had taint.", now.Add(-time.Minute)),
+		newTestPod("p6", corev1.PodRunning, false),
+	}, newTestCustomedHPA("customedhpa01", nil))
+	s := newTestResourcesController(newTestScaleOutConf(0))
+
+	s.scaleOutPendingPods(now)
+	if len(fake.Allocations) != 1 || fake.Allocations[0].Flavor != "c6.xlarge" || fake.Allocations[0].Count != 2 {
+		t.Fatalf("scaleOutPendingPods() allocations = %+v", fake.Allocations)
+	}
+	if !hasEvent(drainEvents(recorder), EventReasonNodeScaleOut) {
+		t.Errorf("expect event %s", EventReasonNodeScaleOut)
+	}
+	requests := s.getNodeRequests()
+	if len(requests) != 1 || len(requests[0].Pods) != 3 {
+		t.Fatalf("scaleOutPendingPods() node requests = %+v", requests)
+	}
+
+	// 已申请的 pod 在节点加入集群前不重复申请，p4 Pending 超过阈值但未到最小申请间隔
+	s.scaleOutPendingPods(now.Add(time.Minute))
+	if len(fake.Allocations) != 1 {
+		t.Fatalf("scaleOutPendingPods() again allocations = %d, want 1", len(fake.Allocations))
+	}
+
+	// 节点全部加入集群后不再跟踪
+	for _, name := range fake.Allocations[0].Nodes {
+		_, err := k8sclient.GetKubeClientSet().CoreV1().Nodes().Create(context.Background(), newTestNode(name, true),
+			metav1.CreateOptions{})
+		if err != nil {
+			t.Fatalf("create node err: %v", err)
+		}
+	}
+	s.scaleOutPendingPods(now.Add(time.Minute))
+	if requests = s.getNodeRequests(); len(requests) != 0 {
+		t.Errorf("scaleOutPendingPods() after nodes joined node requests = %+v", requests)
+	}
+
+	// 超过最小申请间隔后为仍 Pending 的 pod 申请节点，受最大节点数限制
+	s.strategiesInfo.Resources.MaxNodes = 3
+	s.scaleOutPendingPods(now.Add(3 * time.Minute))
+	if len(fake.Allocations) != 2 || fake.Allocations[1].Count != 1 {
+		t.Errorf("scaleOutPendingPods() with max nodes allocations = %+v", fake.Allocations)
+	}
+}
+
+func TestStrategyController_trackNodeRequests_timeout(t *testing.T) {
+	fake := setupTestGRM(t)
+	now := time.Now()
+	recorder := setupFakeClientSet([]runtime.Object{newTestNode("node-1", true), newTestCapacityDeployment(1),
+		newTestPendingPod("p1", "1", "0/1 nodes are available: 1 Insufficient cpu.", now.Add(-time.Minute)),
+	}, newTestCustomedHPA("customedhpa01", nil))
+	s := newTestResourcesController(newTestScaleOutConf(0))
+
+	s.scaleOutPendingPods(now)
+	if len(fake.Allocations) != 1 || len(s.getNodeRequests()) != 1 {
+		t.Fatalf("scaleOutPendingPods() allocations = %+v", fake.Allocations)
+	}
+	drainEvents(recorder)
+
+	// 节点未在 joinTimeoutSeconds 内加入集群
+	s.scaleOutPendingPods(now.Add(16 * time.Minute))
+	if !hasEvent(drainEvents(recorder), EventReasonNodeScaleOutFailed) {
+		t.Errorf("expect event %s", EventReasonNodeScaleOutFailed)
+	}
+	if len(fake.Allocations) != 2 {
+		t.Errorf("scaleOutPendingPods() after timeout allocations = %d, want 2", len(fake.Allocations))
+	}
+}
+
+func TestStrategyController_scaleOutPendingPods_incoming(t *testing.T) {
+	fake := setupTestGRM(t)
+	now := time.Now()
+	insufficient := "0/1 nodes are available: 1 Insufficient cpu."
+	setupFakeClientSet([]runtime.Object{newTestNode("node-1", true), newTestCapacityDeployment(3),
+		newTestPendingPod("p1", "1", insufficient, now.Add(-time.Minute)),
+		newTestPendingPod("p2", "1", insufficient, now.Add(-time.Minute)),
+	}, newTestCustomedHPA("customedhpa01", nil))
+	s := newTestResourcesController(newTestScaleOutConf(0))
+
+	// 提高 minReplicas 时申请的节点尚未加入集群，足够容纳 Pending 的 pod，不重复申请
+	fake.AddAllocation("customedhpa01", "grm-node-a")
+	s.scaleOutPendingPods(now)
+	if len(fake.Allocations) != 1 {
+		t.Errorf("scaleOutPendingPods() with incoming nodes allocations = %+v", fake.Allocations)
+	}
+}
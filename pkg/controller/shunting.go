@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+// ShuntingStrategy 提供给 conductor 的分流策略：各任务类型请求分到本地 worker 与外部（云上）资源的比例
+type ShuntingStrategy struct {
+	// 分流策略版本，内容不变时版本不变，conductor 可据此缓存
+	Version     string    `json:"version"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	// 下一次策略时间段切换的时间，在此之前分流策略通常不会变化
+	ValidUntil *time.Time `json:"validUntil,omitempty"`
+	// 当前所处的策略时间段
+	Window string `json:"window"`
+	// 本地就绪副本数
+	LocalReplicas int32           `json:"localReplicas"`
+	TaskTypes     []TaskTypeShare `json:"taskTypes"`
+}
+
+// TaskTypeShare 某个任务类型的分流比例
+type TaskTypeShare struct {
+	TaskType string `json:"taskType"`
+	// 分到本地 worker 的比例，[0, 1]
+	LocalShare float64 `json:"localShare"`
+	// 分到外部资源的比例，[0, 1]
+	ExternalShare float64 `json:"externalShare"`
+	// 请求量（每分钟）
+	Demand float64 `json:"demand"`
+	// 分配给该任务类型的本地处理能力（每分钟请求数）
+	LocalCapacity float64 `json:"localCapacity"`
+}
+
+// computeTaskTypeShares 按配置顺序为各任务类型分配本地副本：
+// 可用副本数 = 就绪副本数 × 本地利用率阈值，任务类型需要的副本数 = 请求量 / 单副本处理能力，
+// 本地副本不足时，未分配到的请求量分流到外部资源
+func computeTaskTypeShares(confs []ShuntingConf, readyReplicas int32, demand map[string]float64) []TaskTypeShare {
+	shares := make([]TaskTypeShare, 0, len(confs))
+	usedReplicas := 0.0
+	for _, conf := range confs {
+		available := math.Max(float64(readyReplicas)*conf.LocalThreshold-usedReplicas, 0)
+		share := TaskTypeShare{TaskType: conf.TaskType, Demand: demand[conf.TaskType], LocalShare: 1}
+		if share.Demand > 0 {
+			needReplicas := share.Demand / conf.ReplicaCapacity
+			localReplicas := math.Min(needReplicas, available)
+			usedReplicas += localReplicas
+			share.LocalShare = roundShare(localReplicas / needReplicas)
+			share.LocalCapacity = localReplicas * conf.ReplicaCapacity
+		} else if readyReplicas == 0 {
+			// 没有本地副本时全部分流到外部
+			share.LocalShare = 0
+		}
+		share.ExternalShare = roundShare(1 - share.LocalShare)
+		shares = append(shares, share)
+	}
+	return shares
+}
+
+// roundShare 比例保留 4 位小数，避免浮点误差导致版本抖动
+func roundShare(share float64) float64 {
+	return math.Round(share*10000) / 10000
+}
+
+// ShuntingStrategy 根据本地就绪副本数、当前策略时间段及分流配置计算分流策略
+func (s *StrategyController) ShuntingStrategy() (*ShuntingStrategy, error) {
+	info := s.Strategies()
+	if info == nil {
+		return nil, errors.New("strategies not loaded")
+	}
+	chpa, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
+		Get(context.Background(), info.TargetHPA, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "get customHPA[%s] err", info.TargetHPA)
+	}
+	w, err := getWorkloadReplicas(context.Background(), chpa.Spec.ScaleTargetRef)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ShuntingStrategy{
+		GeneratedAt:   time.Now(),
+		LocalReplicas: w.ReadyReplicas,
+	}
+	transitions := s.NextTransitions()
+	var window *Strategy
+	if len(transitions) > 0 {
+		result.ValidUntil = &transitions[0].Time
+		result.Window = transitions[len(transitions)-1].Window
+		window = findStrategy(info, result.Window)
+	}
+	demand := s.currentDemand(info.Shunting, window, result.GeneratedAt)
+	result.TaskTypes = computeTaskTypeShares(info.Shunting, w.ReadyReplicas, demand)
+	result.Version = shuntingVersion(result)
+	return result, nil
+}
+
+// currentDemand 获取各任务类型的当前请求量：优先使用上报的负载数据，没有上报数据时使用策略时间段的预期负载
+func (s *StrategyController) currentDemand(confs []ShuntingConf, window *Strategy, now time.Time) map[string]float64 {
+	demand := map[string]float64{}
+	for _, conf := range confs {
+		if s.loadStore != nil {
+			if rate, ok := s.loadStore.RequestRate(conf.TaskType, s.loadRateWindow, now); ok {
+				demand[conf.TaskType] = rate
+				continue
+			}
+		}
+		if window != nil {
+			if load, ok := window.ExpectedLoad[conf.TaskType]; ok {
+				demand[conf.TaskType] = load
+			}
+		}
+	}
+	return demand
+}
+
+// shuntingVersion 根据分流结果计算版本，不包含生成时间
+func shuntingVersion(result *ShuntingStrategy) string {
+	bytes, err := json.Marshal(struct {
+		Window        string
+		LocalReplicas int32
+		TaskTypes     []TaskTypeShare
+	}{result.Window, result.LocalReplicas, result.TaskTypes})
+	if err != nil {
+		logger.Panicf("Marshal shunting strategy err: %v", err)
+	}
+	return utils.DataHashMd5(bytes)[:16]
+}
+
+// findStrategy 查找指定时间段的策略
+func findStrategy(info *StrategiesInfo, window string) *Strategy {
+	for i := range info.Strategies {
+		if info.Strategies[i].ValidTime == window {
+			return &info.Strategies[i]
+		}
+	}
+	return nil
+}
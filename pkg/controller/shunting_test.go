@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_computeTaskTypeShares(t *testing.T) {
+	confs := []ShuntingConf{
+		{TaskType: "live", ReplicaCapacity: 10, LocalThreshold: 0.8},
+		{TaskType: "vod", ReplicaCapacity: 5, LocalThreshold: 0.8},
+	}
+	tests := []struct {
+		name          string
+		readyReplicas int32
+		demand        map[string]float64
+		want          []float64
+	}{
+		{"enough capacity", 10, map[string]float64{"live": 20, "vod": 10}, []float64{1, 1}},
+		// 可用副本 8：live 需要 5 个，vod 需要 6 个只剩 3 个
+		{"second type shunted", 10, map[string]float64{"live": 50, "vod": 30}, []float64{1, 0.5}},
+		// live 需要 10 个，只有 8 个可用，vod 全部分流
+		{"all shunted", 10, map[string]float64{"live": 100, "vod": 30}, []float64{0.8, 0}},
+		{"no demand", 10, nil, []float64{1, 1}},
+		{"no replicas", 0, nil, []float64{0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares := computeTaskTypeShares(confs, tt.readyReplicas, tt.demand)
+			got := []float64{}
+			for _, share := range shares {
+				got = append(got, share.LocalShare)
+				if share.LocalShare+share.ExternalShare != 1 {
+					t.Errorf("shares of %s not sum to 1: %+v", share.TaskType, share)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("computeTaskTypeShares() local shares = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+// AnnotationOriginalSpec 首次被本服务接管前，目标HPA原始 spec 的快照（json）
+const AnnotationOriginalSpec = "aass.nanto.io/original-spec"
+
+// snapshotOriginalSpec 目标HPA还没有原始 spec 快照时，将当前 spec 记录到注解中，需在修改 spec 之前调用
+func snapshotOriginalSpec(chpa *v1alpha1.CustomedHorizontalPodAutoscaler) error {
+	if _, ok := chpa.Annotations[AnnotationOriginalSpec]; ok {
+		return nil
+	}
+	bytes, err := json.Marshal(chpa.Spec)
+	if err != nil {
+		return errors.Wrapf(err, "marshal spec of customHPA[%s] err", chpa.Name)
+	}
+	if chpa.Annotations == nil {
+		chpa.Annotations = map[string]string{}
+	}
+	chpa.Annotations[AnnotationOriginalSpec] = string(bytes)
+	logger.Infof("Snapshot original spec of customHPA[%s]: %s", chpa.Name, bytes)
+	return nil
+}
+
+// RestoreOriginalSpec 将目标HPA恢复为首次接管前的原始 spec，并删除快照注解。
+// pause 为 true 时同时暂停定时策略，避免下个时间段覆盖恢复结果并重新记录快照
+func RestoreOriginalSpec(history *HistoryStore, target string, pause bool) error {
+	chpaCli := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault)
+	var (
+		update   *v1alpha1.CustomedHorizontalPodAutoscaler
+		prevSpec *v1alpha1.CustomedHorizontalPodAutoscalerSpec
+	)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ctx := context.Background()
+		chpa, err := chpaCli.Get(ctx, target, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "get customHPA[%s] err", target)
+		}
+		data, ok := chpa.Annotations[AnnotationOriginalSpec]
+		if !ok {
+			return errors.Errorf("customHPA[%s] has no original spec snapshot", target)
+		}
+		originalSpec := v1alpha1.CustomedHorizontalPodAutoscalerSpec{}
+		if err = json.Unmarshal([]byte(data), &originalSpec); err != nil {
+			return errors.Wrapf(err, "unmarshal original spec of customHPA[%s] err", target)
+		}
+
+		prevSpec = chpa.Spec.DeepCopy()
+		originalSpec.ScaleTargetRef = chpa.Spec.ScaleTargetRef
+		originalSpec.DeepCopyInto(&chpa.Spec)
+		delete(chpa.Annotations, AnnotationOriginalSpec)
+		if pause {
+			chpa.Annotations[AnnotationPaused] = "true"
+		}
+		update, err = chpaCli.Update(ctx, chpa, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "restore original spec of customHPA[%s] err", target)
+	}
+	logger.Infof("Restore original spec of customHPA[%s] success, strategy schedule paused: %t", target, pause)
+	recordEvent(update, corev1.EventTypeNormal, EventReasonOriginalSpecRestored,
+		"Restore original spec, minReplicas: %d, maxReplicas: %d, scheduled strategy paused: %t",
+		utils.Int32Value(update.Spec.MinReplicas), utils.Int32Value(update.Spec.MaxReplicas), pause)
+
+	record := &ApplyRecord{
+		Time:         time.Now(),
+		RollbackFrom: "original spec",
+		Spec:         update.Spec,
+		PreviousSpec: *prevSpec,
+	}
+	if err = history.Append(target, record); err != nil {
+		return errors.Wrap(err, "append restore record err")
+	}
+	return nil
+}
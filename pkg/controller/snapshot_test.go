@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+)
+
+func getTestCustomedHPA(t *testing.T, name string) *v1alpha1.CustomedHorizontalPodAutoscaler {
+	chpa, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
+		Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get customHPA[%s] err: %v", name, err)
+	}
+	return chpa
+}
+
+func TestSnapshotAndRestoreOriginalSpec(t *testing.T) {
+	setupFakeClientSet(nil, newTestCustomedHPA("chpa", nil))
+	s := &StrategyController{history: NewHistoryStore(0)}
+
+	// 两次更新后，快照仍为首次接管前的 spec
+	s.genCronFunc("chpa", Strategy{ValidTime: "0:00-09:30", Spec: newTestSpec(3, 9)})()
+	s.genCronFunc("chpa", Strategy{ValidTime: "09:30-24:00", Spec: newTestSpec(5, 10)})()
+	chpa := getTestCustomedHPA(t, "chpa")
+	if *chpa.Spec.MinReplicas != 5 {
+		t.Fatalf("unexpected spec after apply: %+v", chpa.Spec)
+	}
+	if _, ok := chpa.Annotations[AnnotationOriginalSpec]; !ok {
+		t.Fatalf("original spec snapshot not found, annotations: %v", chpa.Annotations)
+	}
+
+	if err := RestoreOriginalSpec(s.history, "chpa", true); err != nil {
+		t.Fatalf("RestoreOriginalSpec() err: %+v", err)
+	}
+	chpa = getTestCustomedHPA(t, "chpa")
+	if *chpa.Spec.MinReplicas != 1 || *chpa.Spec.MaxReplicas != 2 || chpa.Spec.ScaleTargetRef.Name != "worker" {
+		t.Errorf("RestoreOriginalSpec() spec = %+v, want original spec", chpa.Spec)
+	}
+	if _, ok := chpa.Annotations[AnnotationOriginalSpec]; ok {
+		t.Errorf("original spec snapshot should be removed after restore")
+	}
+	if chpa.Annotations[AnnotationPaused] != "true" {
+		t.Errorf("RestoreOriginalSpec() should pause target, annotations: %v", chpa.Annotations)
+	}
+	if err := RestoreOriginalSpec(s.history, "chpa", true); err == nil {
+		t.Errorf("RestoreOriginalSpec() without snapshot should fail")
+	}
+}
+
+func TestStrategyController_restoreRemovedTargets(t *testing.T) {
+	setupFakeClientSet(nil, newTestCustomedHPA("old", nil), newTestCustomedHPA("new", nil))
+	history := NewHistoryStore(0)
+	s := &StrategyController{history: history, restoreOnRemoval: true}
+	s.genCronFunc("old", Strategy{ValidTime: "0:00-24:00", Spec: newTestSpec(3, 9)})()
+	s.restoreRemovedTargets("old")
+
+	// 重启后（内存中没有原目标）切换目标HPA，仍恢复原目标的原始 spec
+	s = &StrategyController{history: history, restoreOnRemoval: true}
+	s.restoreRemovedTargets("new")
+	chpa := getTestCustomedHPA(t, "old")
+	if *chpa.Spec.MinReplicas != 1 || *chpa.Spec.MaxReplicas != 2 {
+		t.Errorf("removed target spec = %+v, want original spec", chpa.Spec)
+	}
+	if _, ok := chpa.Annotations[AnnotationPaused]; ok {
+		t.Errorf("removed target should not be paused, annotations: %v", chpa.Annotations)
+	}
+	managed, err := history.ManagedTargets()
+	if err != nil || len(managed) != 1 || managed[0] != "new" {
+		t.Errorf("ManagedTargets() = %v, err: %v, want [new]", managed, err)
+	}
+}
@@ -0,0 +1,236 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	"nanto.io/application-auto-scaling-service/pkg/utils/cronutil"
+)
+
+const reloadTimeout = time.Minute
+
+var (
+	// ErrTargetNotFound 目标HPA不存在或不受本服务管理
+	ErrTargetNotFound = errors.New("target not found")
+	// ErrWindowNotFound 策略时间段不存在
+	ErrWindowNotFound = errors.New("strategy window not found")
+	// ErrRevisionNotFound 策略更新历史中不存在指定序号
+	ErrRevisionNotFound = errors.New("revision not found")
+)
+
+// TargetInfo 受管理的目标HPA信息
+type TargetInfo struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// 是否暂停定时策略，及暂停原因
+	Paused      bool   `json:"paused"`
+	PauseReason string `json:"pauseReason,omitempty"`
+	// 按定时任务当前所处的策略时间段
+	ActiveWindow string `json:"activeWindow"`
+	// 最近一次更新成功的策略时间段
+	AppliedWindow string `json:"appliedWindow"`
+	// 因系统负载较高未降低 minReplicas 的时间段
+	GatedWindow string `json:"gatedWindow,omitempty"`
+	// 最近一次提高 minReplicas 前的集群容量检查结果
+	Capacity *CapacityReport `json:"capacity,omitempty"`
+	// 命名空间 ResourceQuota 允许的目标负载最大副本数，没有配额限制时为空
+	QuotaCeiling *QuotaCeiling `json:"quotaCeiling,omitempty"`
+	// 因 Pending pod 申请、尚未全部加入集群的节点
+	NodeRequests []NodeRequest `json:"nodeRequests,omitempty"`
+	// 最近一次释放节点流程
+	NodeRelease *NodeRelease                                   `json:"nodeRelease,omitempty"`
+	Spec        v1alpha1.CustomedHorizontalPodAutoscalerSpec   `json:"spec"`
+	Status      v1alpha1.CustomedHorizontalPodAutoscalerStatus `json:"status"`
+}
+
+// Transition 即将发生的策略时间段切换
+type Transition struct {
+	Target string    `json:"target"`
+	Window string    `json:"window"`
+	Time   time.Time `json:"time"`
+}
+
+func (s *StrategyController) setLoadedStrategies(info *StrategiesInfo, entries map[cron.EntryID]Strategy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.strategiesInfo = info
+	s.cronEntries = entries
+}
+
+func (s *StrategyController) setAppliedWindow(window string, requestedMinReplicas int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.appliedWindow = window
+	s.requestedMinReplicas = requestedMinReplicas
+}
+
+// Strategies 获取当前加载的策略
+func (s *StrategyController) Strategies() *StrategiesInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.strategiesInfo
+}
+
+// NextTransitions 获取即将发生的策略时间段切换，按时间升序
+func (s *StrategyController) NextTransitions() []Transition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	transitions := []Transition{}
+	if s.strategiesInfo == nil {
+		return transitions
+	}
+	for _, entry := range cronutil.GetCron().Entries() {
+		if strategy, ok := s.cronEntries[entry.ID]; ok {
+			transitions = append(transitions, Transition{
+				Target: s.strategiesInfo.TargetHPA,
+				Window: strategy.ValidTime,
+				Time:   entry.Next,
+			})
+		}
+	}
+	sort.Slice(transitions, func(i, j int) bool {
+		return transitions[i].Time.Before(transitions[j].Time)
+	})
+	return transitions
+}
+
+// activeWindow 定时任务当前所处的策略时间段，即最近一次触发（下次触发最晚）的任务
+func (s *StrategyController) activeWindow() string {
+	transitions := s.NextTransitions()
+	if len(transitions) == 0 {
+		return ""
+	}
+	return transitions[len(transitions)-1].Window
+}
+
+// Targets 获取受管理的目标HPA信息
+func (s *StrategyController) Targets() ([]TargetInfo, error) {
+	info := s.Strategies()
+	if info == nil {
+		return []TargetInfo{}, nil
+	}
+	target, err := s.Target(info.TargetHPA)
+	if err != nil {
+		return nil, err
+	}
+	return []TargetInfo{*target}, nil
+}
+
+// Target 获取指定目标HPA的信息
+func (s *StrategyController) Target(name string) (*TargetInfo, error) {
+	if err := s.checkTarget(name); err != nil {
+		return nil, err
+	}
+	chpa, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
+		Get(context.Background(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, errors.Wrapf(ErrTargetNotFound, "customHPA[%s]", name)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "get customHPA[%s] err", name)
+	}
+	paused, reason := getPauseState(chpa, time.Now())
+
+	s.mu.Lock()
+	appliedWindow, gatedWindow, capacity, quota := s.appliedWindow, s.gatedWindow, s.capacityReport, s.quotaCeiling
+	s.mu.Unlock()
+	return &TargetInfo{
+		Name:          chpa.Name,
+		Namespace:     chpa.Namespace,
+		Paused:        paused,
+		PauseReason:   reason,
+		ActiveWindow:  s.activeWindow(),
+		AppliedWindow: appliedWindow,
+		GatedWindow:   gatedWindow,
+		Capacity:      capacity,
+		QuotaCeiling:  quota,
+		NodeRequests:  s.getNodeRequests(),
+		NodeRelease:   s.latestNodeRelease(),
+		Spec:          chpa.Spec,
+		Status:        chpa.Status,
+	}, nil
+}
+
+// ApplyWindow 立即将目标HPA更新为指定时间段的策略，忽略暂停注解
+func (s *StrategyController) ApplyWindow(target, window string) error {
+	if err := s.checkTarget(target); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	sourceRevision := s.localDataKey
+	strategy := findStrategy(s.strategiesInfo, window)
+	s.mu.Unlock()
+	if strategy == nil {
+		return errors.Wrapf(ErrWindowNotFound, "window[%s]", window)
+	}
+	logger.Infof("Force apply strategy window[%s] to customHPA[%s]", window, target)
+	return s.applyStrategy(target, *strategy, sourceRevision, true)
+}
+
+// SetPaused 暂停或恢复目标HPA的定时策略，恢复后立即补执行当前时间段策略
+func (s *StrategyController) SetPaused(target string, paused bool) error {
+	if err := s.checkTarget(target); err != nil {
+		return err
+	}
+	if err := SetTargetPaused(target, paused); err != nil {
+		return err
+	}
+	if paused {
+		logger.Infof("CustomHPA[%s] paused by request", target)
+		s.setPaused(true)
+		return nil
+	}
+	logger.Infof("CustomHPA[%s] resumed by request", target)
+	s.resumeIfUnpaused()
+	return nil
+}
+
+// Reload 重新加载策略文件并重新编排定时任务
+func (s *StrategyController) Reload() error {
+	errCh := make(chan error, 1)
+	select {
+	case s.reloadCh <- errCh:
+	case <-time.After(reloadTimeout):
+		return errors.New("controller is not running")
+	}
+	return <-errCh
+}
+
+// History 获取目标HPA的策略更新历史
+func (s *StrategyController) History(target string) ([]ApplyRecord, error) {
+	if err := s.checkTarget(target); err != nil {
+		return nil, err
+	}
+	return s.history.List(target)
+}
+
+// Rollback 回滚目标HPA的策略并暂停定时策略，revision 为 0 时恢复为最近一次更新前的 spec
+func (s *StrategyController) Rollback(target string, revision int) (*ApplyRecord, error) {
+	if err := s.checkTarget(target); err != nil {
+		return nil, err
+	}
+	record, err := RollbackStrategy(s.history, target, revision)
+	if err != nil {
+		return nil, err
+	}
+	s.setPaused(true)
+	return record, nil
+}
+
+// checkTarget 校验目标HPA是否受本服务管理
+func (s *StrategyController) checkTarget(target string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.strategiesInfo == nil || s.strategiesInfo.TargetHPA != target {
+		return errors.Wrapf(ErrTargetNotFound, "customHPA[%s] is not managed", target)
+	}
+	return nil
+}
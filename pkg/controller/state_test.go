@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"nanto.io/application-auto-scaling-service/pkg/utils/cronutil"
+)
+
+// setupTestController 使用 conf/local-strategies.yaml 启动定时任务
+func setupTestController(t *testing.T) *StrategyController {
+	setupFakeClientSet(nil, newTestCustomedHPA("customedhpa01", nil))
+	cronutil.InitCron()
+	t.Cleanup(func() { cronutil.GetCron().Stop() })
+	s := &StrategyController{LocalPath: "../../conf/local-strategies.yaml", history: NewHistoryStore(0)}
+	if err := s.execLocalStrategies(); err != nil {
+		t.Fatalf("execLocalStrategies() err: %+v", err)
+	}
+	return s
+}
+
+func TestStrategyControllerState(t *testing.T) {
+	s := setupTestController(t)
+
+	transitions := s.NextTransitions()
+	if len(transitions) != 3 {
+		t.Fatalf("NextTransitions() got = %+v, want 3 transitions", transitions)
+	}
+	for i := 1; i < len(transitions); i++ {
+		if transitions[i].Time.Before(transitions[i-1].Time) {
+			t.Errorf("NextTransitions() not sorted: %+v", transitions)
+		}
+	}
+
+	targets, err := s.Targets()
+	if err != nil || len(targets) != 1 {
+		t.Fatalf("Targets() got = %+v, err: %v", targets, err)
+	}
+	if targets[0].ActiveWindow == "" || targets[0].ActiveWindow != targets[0].AppliedWindow {
+		t.Errorf("Targets() activeWindow = %s, appliedWindow = %s", targets[0].ActiveWindow, targets[0].AppliedWindow)
+	}
+
+	if err = s.ApplyWindow("customedhpa01", "15:40-15:50"); err != nil {
+		t.Fatalf("ApplyWindow() err: %+v", err)
+	}
+	target, _ := s.Target("customedhpa01")
+	if target.AppliedWindow != "15:40-15:50" || *target.Spec.MaxReplicas != 7 {
+		t.Errorf("ApplyWindow() target = %+v", target)
+	}
+	if err = s.ApplyWindow("customedhpa01", "1:00-2:00"); !errors.Is(err, ErrWindowNotFound) {
+		t.Errorf("ApplyWindow() unknown window err = %v", err)
+	}
+	if _, err = s.Target("other"); !errors.Is(err, ErrTargetNotFound) {
+		t.Errorf("Target() unknown target err = %v", err)
+	}
+
+	if err = s.SetPaused("customedhpa01", true); err != nil {
+		t.Fatalf("SetPaused() err: %+v", err)
+	}
+	if target, _ = s.Target("customedhpa01"); !target.Paused {
+		t.Errorf("SetPaused() target should be paused")
+	}
+}
+
+func TestStrategyController_reloadStrategies_invalid(t *testing.T) {
+	s := setupTestController(t)
+	entries := len(cronutil.GetCron().Entries())
+
+	// 策略文件无效时保留之前的定时任务和策略，文件未再修改时不重复加载
+	invalidPath := filepath.Join(t.TempDir(), "local-strategies.yaml")
+	if err := ioutil.WriteFile(invalidPath, []byte("targetHPA: customedhpa01\nstrategies: [{validTime: 1}]"),
+		0600); err != nil {
+		t.Fatalf("write file err: %v", err)
+	}
+	s.LocalPath = invalidPath
+	if err := s.reloadStrategies(); err == nil {
+		t.Fatalf("reloadStrategies() with invalid file err = nil")
+	}
+	if got := len(cronutil.GetCron().Entries()); got != entries {
+		t.Errorf("cron entries after invalid reload = %d, want %d", got, entries)
+	}
+	if info := s.Strategies(); info == nil || info.TargetHPA != "customedhpa01" {
+		t.Errorf("Strategies() after invalid reload = %+v", info)
+	}
+	if s.isStrategiesFileModified() {
+		t.Errorf("isStrategiesFileModified() for the same invalid file = true, want false")
+	}
+}
@@ -6,16 +6,23 @@ import (
 	"fmt"
 	"io/ioutil"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/robfig/cron/v3"
 	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
 
+	"nanto.io/application-auto-scaling-service/pkg/busystate"
 	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/grm"
 	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
 	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	"nanto.io/application-auto-scaling-service/pkg/loadstore"
+	"nanto.io/application-auto-scaling-service/pkg/sysload"
 	"nanto.io/application-auto-scaling-service/pkg/utils"
 	"nanto.io/application-auto-scaling-service/pkg/utils/cronutil"
 	"nanto.io/application-auto-scaling-service/pkg/utils/logutil"
@@ -36,20 +43,91 @@ type StrategyController struct {
 	StrategySource string
 	// 策略本地文件路径
 	LocalPath string
-	// 本地策略yaml文件 md5值
-	localDataKey string
+
+	// 策略更新历史
+	history *HistoryStore
+	// 目标HPA从策略中移除时，是否恢复为首次接管前的原始 spec
+	restoreOnRemoval bool
+	// 策略更新后，校验目标负载副本数收敛的超时时间和轮询间隔
+	verifyTimeout  time.Duration
+	verifyInterval time.Duration
+
+	// 管理接口触发的重新加载请求
+	reloadCh chan chan error
+
+	// conductor/worker 上报的负载数据，及计算当前请求量的时间窗口
+	loadStore      *loadstore.Store
+	loadRateWindow time.Duration
+	// 请求量预测相关配置
+	predictConf *config.PredictConf
+	// 采集系统负载，用于门控策略时间段切换
+	collector sysload.Collector
+	// GRM 节点申请/释放相关配置
+	grmConf *config.GRMConf
+
+	mu sync.Mutex
+	// 当前生效的本地策略yaml文件 md5值，及最近一次加载失败的文件 md5值（文件未再修改时不重复加载）
+	localDataKey   string
+	invalidDataKey string
+	// 当前生效策略的目标HPA
+	targetHPA string
+	// 当前加载的策略，及定时任务对应的策略时间段
+	strategiesInfo *StrategiesInfo
+	cronEntries    map[cron.EntryID]Strategy
+	// 最近一次更新成功的策略时间段，及该时间段要求的 minReplicas（策略配置或预测建议值，
+	// 门控、容量限制前），预测建议与其不一致时才重新执行策略
+	appliedWindow        string
+	requestedMinReplicas int32
+	// 最近一次的请求量预测结果
+	prediction *Prediction
+	// 因系统负载较高未降低 minReplicas 的时间段
+	gatedWindow string
+	// 降低 minReplicas 后释放多余 GRM 节点的时间，为零值时无待释放节点
+	releaseAt time.Time
+	// 最近一次提高 minReplicas 前的集群容量检查结果
+	capacityReport *CapacityReport
+	// 命名空间配额允许的目标负载最大副本数
+	quotaCeiling *QuotaCeiling
+	// 为提高 minReplicas 申请、尚未交付的节点
+	acquisitions []*nodeAcquisition
+	// 因 Pending pod 申请、尚未全部加入集群的节点，及最近一次申请时间
+	nodeRequests []*NodeRequest
+	lastScaleOut time.Time
+	// 释放节点流程（最近的若干次，最后一个可能进行中），releaseMu 保证同一时间只有一处推进流程
+	nodeReleases []*NodeRelease
+	releaseMu    sync.Mutex
+	// 目标命名空间的 pod 缓存，为空时直接请求 api server；informer 启动后在 mu 内设置
+	podLister corelisters.PodLister
+	// 最近一次观察到目标HPA是否处于暂停状态，暂停解除后需要补执行当前策略
+	paused bool
+	// 取消上一次未完成的副本数收敛校验
+	verifyCancel context.CancelFunc
 }
 
-func NewStrategyController(conf *config.StrategyConf) *StrategyController {
+func NewStrategyController(conf *config.StrategyConf, loadStore *loadstore.Store, loadRateWindow time.Duration,
+	predictConf *config.PredictConf, grmConf *config.GRMConf) (*StrategyController, error) {
+	if err := checkPredictConf(predictConf); err != nil {
+		return nil, err
+	}
 	c := &StrategyController{
-		StrategySource: conf.Source,
-		LocalPath:      conf.LocalPath,
+		StrategySource:   conf.Source,
+		LocalPath:        conf.LocalPath,
+		history:          NewHistoryStore(conf.HistoryMaxRecords),
+		restoreOnRemoval: conf.RestoreOnRemoval,
+		verifyTimeout:    time.Duration(conf.VerifyTimeoutSecond) * time.Second,
+		verifyInterval:   defaultVerifyInterval,
+		reloadCh:         make(chan chan error),
+		loadStore:        loadStore,
+		loadRateWindow:   loadRateWindow,
+		predictConf:      predictConf,
+		collector:        sysload.NewMetricsCollector(),
+		grmConf:          grmConf,
 	}
 	// conf中未指定“LocalPath”时，为挂载 configmap 配置场景
 	if c.StrategySource == strategiesSourceLocal && c.LocalPath == "" {
 		c.LocalPath = configmapLocalStrategiesPath
 	}
-	return c
+	return c, nil
 }
 
 // todo 目前只有local策略
@@ -62,30 +140,78 @@ func (s *StrategyController) Start(ctx context.Context, cancel context.CancelFun
 	// 执行当前配置的策略
 	if err := s.execLocalStrategies(); err != nil {
 		logger.Errorf("Exec local strategies err: %+v", err)
+		s.recordStrategiesInvalid(err)
 		cancel()
 		return
 	}
 
+	// 开启预测时，定期刷新请求量预测结果
+	var predictCh <-chan time.Time
+	if s.predictEnabled() {
+		s.refreshPrediction(time.Now())
+		predictTicker := time.NewTicker(newPredictorConfig(s.predictConf).Step)
+		defer predictTicker.Stop()
+		predictCh = predictTicker.C
+	}
+
+	// 配置了 GRM 时，目标负载 pod 因资源不足无法调度时申请节点；未配置时不启动 pod informer
+	var scaleOutCh <-chan struct{}
+	if grm.GetClient() != nil {
+		scaleOutCh = s.startPodInformer(ctx)
+	}
+
+	// 解除上次运行中断的释放流程遗留的节点封锁，并定期跟踪申请中的节点、推进释放流程
+	uncordonStaleNodes(ctx)
+	releaseInterval := defaultReleasePollInterval
+	if s.grmConf != nil && s.grmConf.PollIntervalSecond > 0 {
+		releaseInterval = time.Duration(s.grmConf.PollIntervalSecond) * time.Second
+	}
+	releaseTicker := time.NewTicker(releaseInterval)
+	defer releaseTicker.Stop()
+
+	// 配置了任务状态来源时，定期更新目标负载各 pod 的 pod-deletion-cost 注解
+	var deletionCostCh <-chan time.Time
+	if tracker := busystate.GetTracker(); tracker != nil {
+		deletionCostTicker := time.NewTicker(tracker.Interval())
+		defer deletionCostTicker.Stop()
+		deletionCostCh = deletionCostTicker.C
+	}
+
 	// 监听策略配置文件的修改
 	ticker := time.NewTicker(time.Minute)
 	defer ticker.Stop()
 	for {
 		select {
+		case now := <-predictCh:
+			s.refreshPrediction(now)
+		case <-scaleOutCh:
+			s.scaleOutPendingPods(time.Now())
+		case now := <-releaseTicker.C:
+			s.trackAcquisitions(now)
+			s.advanceNodeRelease(now)
+		case now := <-deletionCostCh:
+			s.refreshTargetDeletionCosts(now)
 		case <-ticker.C:
+			// 暂停解除后补执行当前策略
+			s.resumeIfUnpaused()
+			// 系统负载下降后补执行被限制的时间段策略
+			s.retryGatedWindow()
+			// 延迟释放降低 minReplicas 后多余的 GRM 节点
+			s.releaseSurplusNodes(time.Now())
+			// 跟踪申请的节点，并处理仍 Pending 的 pod
+			s.scaleOutPendingPods(time.Now())
+
 			if !s.isStrategiesFileModified() {
 				logger.Info("local strategies is not modified")
 				continue
 			}
 			logger.Info("local strategies is modified, refresh cron tasks")
-			// 注销之前的定时修改任务
-			cronutil.GetCron().Stop()
-			cronutil.RemoveAllCronEntries()
-			// 执行当前配置的策略
-			if err := s.execLocalStrategies(); err != nil {
-				logger.Errorf("Exec local strategies err: %+v", err)
-				cancel()
-				return
-			}
+			// 新策略无效时保留之前的定时任务，修正策略文件后重新加载
+			_ = s.reloadStrategies()
+		case errCh := <-s.reloadCh:
+			// 通过管理接口触发的重新加载
+			logger.Info("Reload strategies by request, refresh cron tasks")
+			errCh <- s.reloadStrategies()
 		case <-ctx.Done():
 			logger.Info("=== Strategies controller exit ===")
 			return
@@ -93,6 +219,16 @@ func (s *StrategyController) Start(ctx context.Context, cancel context.CancelFun
 	}
 }
 
+// reloadStrategies 重新加载并执行当前配置的策略；新策略无效时保留之前的定时任务，并记录其 md5 避免重复加载
+func (s *StrategyController) reloadStrategies() error {
+	if err := s.execLocalStrategies(); err != nil {
+		logger.Errorf("Exec local strategies err, keep previous cron tasks: %+v", err)
+		s.recordStrategiesInvalid(err)
+		return err
+	}
+	return nil
+}
+
 // Start 启动controller，监听strategies.yaml策略文件修改；修改cce的配置
 func (s *StrategyController) isStrategiesFileModified() bool {
 	hashMd5, err := utils.FileHashMd5(s.LocalPath)
@@ -100,48 +236,118 @@ func (s *StrategyController) isStrategiesFileModified() bool {
 		logger.Panicf("Get file[%s] md5 err: %+v", s.LocalPath, err)
 		return false
 	}
-	return s.localDataKey != hashMd5
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.localDataKey != hashMd5 && s.invalidDataKey != hashMd5
 }
 
-// execLocalStrategies 执行本地策略：编排定时任务，更新当前时间段的策略
-func (s *StrategyController) execLocalStrategies() error {
-	var (
-		strategiesInfo *StrategiesInfo
-		jobExecNow     cron.Job
-		cronSpec       string
-		err            error
-	)
+// target 当前生效策略的目标HPA
+func (s *StrategyController) target() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.targetHPA
+}
 
+// sourceRevision 当前生效策略的来源版本（本地策略文件 md5）
+func (s *StrategyController) sourceRevision() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.localDataKey
+}
+
+// execLocalStrategies 执行本地策略：编排定时任务，更新当前时间段的策略。策略校验通过后才替换之前的定时任务
+func (s *StrategyController) execLocalStrategies() error {
 	// 从本地文件获取策略
-	if strategiesInfo, err = s.getLocalStrategies(s.LocalPath); err != nil {
+	strategiesInfo, dataKey, err := s.getLocalStrategies(s.LocalPath)
+	if err != nil {
+		s.setInvalidDataKey(dataKey)
 		return err
 	}
 
 	// 校验目标 CCE HPA 是否存在
 	if err = checkRefCustomedHPA(strategiesInfo.TargetHPA); err != nil {
+		s.setInvalidDataKey(dataKey)
 		return err
 	}
-
-	// 编排、启动 cron任务
-	for _, s := range strategiesInfo.Strategies {
-		if cronSpec, err = genStartTimeSpec(s.ValidTime); err != nil {
+	cronSpecs := make([]string, 0, len(strategiesInfo.Strategies))
+	for _, strategy := range strategiesInfo.Strategies {
+		cronSpec, err := genStartTimeSpec(strategy.ValidTime)
+		if err != nil {
+			s.setInvalidDataKey(dataKey)
 			return err
 		}
-		if _, err = cronutil.GetCron().AddFunc(cronSpec, genCronFunc(strategiesInfo.TargetHPA, s.Spec)); err != nil {
+		cronSpecs = append(cronSpecs, cronSpec)
+	}
+
+	// 注销之前的定时任务
+	cronutil.GetCron().Stop()
+	cronutil.RemoveAllCronEntries()
+
+	// 目标HPA变更时，原目标不再被管理，按配置恢复其原始 spec
+	s.restoreRemovedTargets(strategiesInfo.TargetHPA)
+	s.mu.Lock()
+	s.targetHPA = strategiesInfo.TargetHPA
+	s.localDataKey = dataKey
+	s.invalidDataKey = ""
+	s.mu.Unlock()
+	s.setPaused(false)
+
+	// 编排、启动 cron任务
+	entries := map[cron.EntryID]Strategy{}
+	for i, strategy := range strategiesInfo.Strategies {
+		entryID, err := cronutil.GetCron().AddFunc(cronSpecs[i], s.genCronFunc(strategiesInfo.TargetHPA, strategy))
+		if err != nil {
 			return errors.Wrap(err, "add cron func err")
 		}
-		logger.Infof("Add cron task success, cron spec[%s]", cronSpec)
+		entries[entryID] = strategy
+		logger.Infof("Add cron task success, cron spec[%s]", cronSpecs[i])
 	}
+	s.setLoadedStrategies(strategiesInfo, entries)
+	s.validateQuota(strategiesInfo)
 	cronutil.GetCron().Start()
 
 	// 更新当前策略
-	if jobExecNow, err = cronutil.FindJobNeedExecNow(); err != nil {
+	jobExecNow, err := cronutil.FindJobNeedExecNow()
+	if err != nil {
 		return err
 	}
 	jobExecNow.Run()
 	return nil
 }
 
+func (s *StrategyController) setInvalidDataKey(dataKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.invalidDataKey = dataKey
+}
+
+// restoreRemovedTargets 按配置恢复已从策略中移除的目标HPA的原始 spec，并持久化当前受管理的目标HPA，
+// 重启期间移除的目标也能被恢复
+func (s *StrategyController) restoreRemovedTargets(target string) {
+	managed, err := s.history.ManagedTargets()
+	if err != nil {
+		logger.Errorf("Get managed targets err, only check the target in memory: %+v", err)
+		managed = []string{}
+	}
+	if prev := s.target(); prev != "" && !utils.IsInStrSlice(managed, prev) {
+		managed = append(managed, prev)
+	}
+	if len(managed) == 1 && managed[0] == target {
+		return
+	}
+	for _, removed := range managed {
+		if removed == target || !s.restoreOnRemoval {
+			continue
+		}
+		if err = RestoreOriginalSpec(s.history, removed, false); err != nil {
+			logger.Errorf("Restore original spec of removed target[%s] err: %+v", removed, err)
+		}
+	}
+	if err = s.history.SetManagedTargets([]string{target}); err != nil {
+		logger.Errorf("Save managed targets err: %+v", err)
+	}
+}
+
 // getAllCustomedHPAName 获取集群中所有 customed hpa 的 name
 func getAllCustomedHPAName() ([]string, error) {
 	chpas, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
@@ -168,33 +374,99 @@ func checkRefCustomedHPA(chpaName string) error {
 	return nil
 }
 
-func genCronFunc(targetHPA string, newSpec v1alpha1.CustomedHorizontalPodAutoscalerSpec) cron.FuncJob {
+func (s *StrategyController) genCronFunc(targetHPA string, strategy Strategy) cron.FuncJob {
+	sourceRevision := s.sourceRevision()
 	return func() {
-		ctx := context.Background()
-		curHpa, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
-			Get(ctx, targetHPA, metav1.GetOptions{})
-		if err != nil {
-			logger.Errorf("Get current customHPA err: %v", err)
-			return
+		if err := s.applyStrategy(targetHPA, strategy, sourceRevision, false); err != nil {
+			logger.Errorf("Apply strategy window[%s] err: %+v", strategy.ValidTime, err)
 		}
+	}
+}
 
-		newSpec.ScaleTargetRef = curHpa.Spec.ScaleTargetRef
-		newSpec.DeepCopyInto(&curHpa.Spec)
+// applyStrategy 更新目标HPA为指定时间段的策略；force 为 true 时忽略暂停注解
+func (s *StrategyController) applyStrategy(targetHPA string, strategy Strategy, sourceRevision string, force bool) error {
+	ctx := context.Background()
+	curHpa, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
+		Get(ctx, targetHPA, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrap(err, "get current customHPA err")
+	}
+	recordEvent(curHpa, corev1.EventTypeNormal, EventReasonWindowTransition,
+		"Strategy window[%s] starts", strategy.ValidTime)
 
-		update, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
-			Update(ctx, curHpa, metav1.UpdateOptions{})
-		if err != nil {
-			logger.Errorf("Update CustomHPA err: %v", err)
-			return
-		}
+	// 目标HPA带有暂停注解时跳过本次更新，暂停解除后由 resumeIfUnpaused 补执行
+	if paused, reason := getPauseState(curHpa, time.Now()); paused && !force {
+		logger.Infof("CustomHPA[%s] is paused, skip update: %s", targetHPA, reason)
+		recordEvent(curHpa, corev1.EventTypeNormal, EventReasonStrategyUpdateSkipped,
+			"Skip strategy window[%s] update, paused: %s", strategy.ValidTime, reason)
+		s.setPaused(true)
+		return nil
+	} else if !paused {
+		s.setPaused(false)
+	}
 
-		// 仅记录日志用
-		bytes, err := json.Marshal(update.Spec)
-		if err != nil {
-			logger.Fatalf("Marshal hpa spec[%+v] err: %v", update.Spec, err)
-		}
-		logger.Infof("Update HPA success, current HPA info: %s", bytes)
+	// 首次接管前记录原始 spec，与本次更新一起提交
+	if err = snapshotOriginalSpec(curHpa); err != nil {
+		recordEvent(curHpa, corev1.EventTypeWarning, EventReasonStrategyUpdateFailed,
+			"Apply strategy window[%s] failed: %v", strategy.ValidTime, err)
+		return err
+	}
+	prevSpec := curHpa.Spec.DeepCopy()
+	newSpec := strategy.Spec.DeepCopy()
+	newSpec.ScaleTargetRef = curHpa.Spec.ScaleTargetRef
+	s.adjustMinReplicas(curHpa, strategy.ValidTime, newSpec)
+	requestedMin := utils.Int32Value(newSpec.MinReplicas)
+	s.gateScaleDown(curHpa, strategy.ValidTime, newSpec)
+	s.checkCapacity(curHpa, strategy.ValidTime, newSpec)
+	s.annotateQuota(curHpa, strategy.ValidTime, newSpec)
+	s.protectBusyPods(curHpa, newSpec)
+	newSpec.DeepCopyInto(&curHpa.Spec)
+
+	update, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().CustomedHorizontalPodAutoscalers(NamespaceDefault).
+		Update(ctx, curHpa, metav1.UpdateOptions{})
+	if err != nil {
+		recordEvent(curHpa, corev1.EventTypeWarning, EventReasonStrategyUpdateFailed,
+			"Apply strategy window[%s] failed: %v", strategy.ValidTime, err)
+		return errors.Wrap(err, "update customHPA err")
+	}
+	recordEvent(update, corev1.EventTypeNormal, EventReasonStrategyApplied,
+		"Apply strategy window[%s] success, minReplicas: %d, maxReplicas: %d, status: %s",
+		strategy.ValidTime, utils.Int32Value(update.Spec.MinReplicas), utils.Int32Value(update.Spec.MaxReplicas),
+		formatHPAStatus(&update.Status))
+	s.setAppliedWindow(strategy.ValidTime, requestedMin)
+
+	// 仅记录日志用
+	bytes, err := json.Marshal(update.Spec)
+	if err != nil {
+		logger.Fatalf("Marshal hpa spec[%+v] err: %v", update.Spec, err)
+	}
+	logger.Infof("Update HPA success, current HPA info: %s, status: %s", bytes, formatHPAStatus(&update.Status))
+
+	// 记录更新历史
+	record := &ApplyRecord{
+		Time:           time.Now(),
+		Window:         strategy.ValidTime,
+		SourceRevision: sourceRevision,
+		Spec:           update.Spec,
+		PreviousSpec:   *prevSpec,
+	}
+	if err = s.history.Append(targetHPA, record); err != nil {
+		logger.Errorf("Append apply history of customHPA[%s] err: %+v", targetHPA, err)
+	}
+
+	// 校验目标负载副本数是否收敛
+	s.startVerify(update, strategy.ValidTime)
+	return nil
+}
+
+// formatHPAStatus 格式化 CustomedHPA 的 status，用于日志和 event
+func formatHPAStatus(status *v1alpha1.CustomedHorizontalPodAutoscalerStatus) string {
+	lastScaleTime := "<none>"
+	if status.LastScaleTime != nil {
+		lastScaleTime = status.LastScaleTime.Format(time.RFC3339)
 	}
+	return fmt.Sprintf("currentReplicas=%d desiredReplicas=%d lastScaleTime=%s",
+		status.CurrentReplicas, status.DesiredReplicas, lastScaleTime)
 }
 
 // genStartTimeSpec 生成策略生效起始时间的 cron 表达式
@@ -215,7 +487,8 @@ func genStartTimeSpec(validTime string) (string, error) {
 	return cronSpec, nil
 }
 
-func (s *StrategyController) getLocalStrategies(path string) (*StrategiesInfo, error) {
+// getLocalStrategies 读取本地策略文件，返回策略及文件内容的 md5
+func (s *StrategyController) getLocalStrategies(path string) (*StrategiesInfo, string, error) {
 	var (
 		bytes []byte
 		err   error
@@ -223,17 +496,17 @@ func (s *StrategyController) getLocalStrategies(path string) (*StrategiesInfo, e
 
 	// 读取本地配置，记录md5
 	if bytes, err = ioutil.ReadFile(path); err != nil {
-		return nil, errors.Wrapf(err, "read local strategies file[%s] err", path)
+		return nil, "", errors.Wrapf(err, "read local strategies file[%s] err", path)
 	}
-	s.localDataKey = utils.DataHashMd5(bytes)
+	dataKey := utils.DataHashMd5(bytes)
 
 	// 反序列化、校验并补全参数
 	info := &StrategiesInfo{}
 	if err = yaml.Unmarshal(bytes, &info); err != nil {
-		return nil, errors.Wrapf(err, "yaml unmarshal err, file content: %s", bytes)
+		return nil, dataKey, errors.Wrapf(err, "yaml unmarshal err, file content: %s", bytes)
 	}
 	if err = checkAndCompleteInfo(info); err != nil {
-		return nil, errors.Wrap(err, "check strategies info err")
+		return nil, dataKey, errors.Wrap(err, "check strategies info err")
 	}
 
 	// 仅记录日志用
@@ -243,5 +516,5 @@ func (s *StrategyController) getLocalStrategies(path string) (*StrategiesInfo, e
 	}
 	logger.Infof("Read strategies from local file, detail: %s", bytes)
 
-	return info, nil
+	return info, dataKey, nil
 }
@@ -8,7 +8,7 @@ import (
 )
 
 func Test_getLocalStrategies(t *testing.T) {
-	s, err := getLocalStrategies("../../conf/strategies.yaml")
+	s, _, err := (&StrategyController{}).getLocalStrategies("../../conf/local-strategies.yaml")
 	if err != nil {
 		t.Errorf("Test getLocalStrategies err: %+v", err)
 		return
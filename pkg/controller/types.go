@@ -8,8 +8,8 @@ import (
 
 const (
 	// 策略 执行动作（actions） 相关
-	operationTypeScaleUp   = "ScaleUp"
-	operationTypeScaleDown = "ScaleDown"
+	OperationTypeScaleUp   = "ScaleUp"
+	OperationTypeScaleDown = "ScaleDown"
 	operationUnitTask      = "Task"
 
 	// 策略 触发条件（metricTrigger） 相关
@@ -26,18 +26,105 @@ var (
 	defaultHitThreshold     int32 = 1
 	defaultPeriodSeconds    int32 = 60
 	defaultRuleDisableFalse       = false
+
+	defaultShuntingLocalThreshold = 1.0
+
+	defaultScaleOutPendingSeconds     int32 = 30
+	defaultScaleOutMinIntervalSeconds int32 = 120
+	defaultScaleOutJoinTimeoutSeconds int32 = 900
 )
 
 type StrategiesInfo struct {
 	// 目标HPA
-	TargetHPA  string     `yaml:"targetHPA"`
-	Strategies []Strategy `yaml:"strategies"`
+	TargetHPA string `yaml:"targetHPA"`
+	// 分流策略配置，按顺序优先分配本地容量
+	Shunting []ShuntingConf `yaml:"shunting"`
+	// 根据系统负载限制策略时间段切换，为空时不限制
+	Gate *GateConf `yaml:"gate"`
+	// 通过 GRM 申请/释放节点的配置，为空时不申请
+	Resources *ResourceConf `yaml:"resources"`
+	// 提高 minReplicas 前的集群容量检查配置，为空时使用默认策略
+	Capacity   *CapacityConf `yaml:"capacity"`
+	Strategies []Strategy    `yaml:"strategies"`
 }
 
 type Strategy struct {
 	// 生效时间段，eg："0:00-09:30"
-	ValidTime string                                       `yaml:"validTime"`
-	Spec      v1alpha1.CustomedHorizontalPodAutoscalerSpec `yaml:"spec"`
+	ValidTime string `yaml:"validTime"`
+	// 该时间段内各任务类型的预估请求量（每分钟），用于计算分流比例
+	ExpectedLoad map[string]float64                           `yaml:"expectedLoad"`
+	Spec         v1alpha1.CustomedHorizontalPodAutoscalerSpec `yaml:"spec"`
+}
+
+// ShuntingConf 某个业务/任务类型的分流配置
+type ShuntingConf struct {
+	// 任务类型
+	TaskType string `yaml:"taskType"`
+	// 单个本地副本的处理能力（每分钟请求数）
+	ReplicaCapacity float64 `yaml:"replicaCapacity"`
+	// 本地容量利用率阈值，取值 (0, 1]，超出部分分流到外部资源，默认 1
+	LocalThreshold float64 `yaml:"localThreshold"`
+}
+
+// GateConf 系统负载门控：负载较高时，切换时间段不降低 minReplicas（保留当前值），负载下降后再降低
+type GateConf struct {
+	// 目标负载 pod 的 CPU 使用量与 request 的比值高于该值时不降低 minReplicas，为 0 时不检查
+	MaxPodCPURatio float64 `yaml:"maxPodCPURatio"`
+	// 集群可调度节点的 CPU 使用量与 allocatable 的比值高于该值时不降低 minReplicas，为 0 时不检查
+	MaxNodeCPURatio float64 `yaml:"maxNodeCPURatio"`
+	// Prometheus 查询条件，任一查询结果高于上限时不降低 minReplicas，需配置 Prometheus 地址
+	Queries []QueryGate `yaml:"queries"`
+}
+
+// QueryGate 基于 PromQL 的门控条件
+type QueryGate struct {
+	// 条件名称，用于日志和 event
+	Name string `yaml:"name"`
+	// PromQL，结果为向量时取各序列之和
+	Query string `yaml:"query"`
+	// 查询结果上限
+	Max float64 `yaml:"max"`
+}
+
+// ResourceConf 节点资源配置：提高 minReplicas 超出集群容量时向 GRM 申请节点，降低后释放多余的节点
+type ResourceConf struct {
+	// 申请的节点规格
+	Flavor string `yaml:"flavor"`
+	// 单个节点可运行的目标负载副本数，用于计算需要申请的节点数
+	ReplicasPerNode int32 `yaml:"replicasPerNode"`
+	// 最多持有的 GRM 节点数，为 0 时不限制
+	MaxNodes int32 `yaml:"maxNodes"`
+	// 可申请的节点规格模板，按优先顺序排列；为空时按 flavor 和 replicasPerNode 计算
+	NodeTemplates []NodeTemplate `yaml:"nodeTemplates"`
+	// 目标负载 pod 因资源不足无法调度时申请节点，为空时不申请
+	ScaleOut *ScaleOutConf `yaml:"scaleOut"`
+}
+
+// NodeTemplate GRM 节点规格模板，描述该规格节点的可分配资源
+type NodeTemplate struct {
+	Flavor string `yaml:"flavor"`
+	// 可分配的 cpu、内存，eg："7600m"、"28Gi"
+	CPU    string `yaml:"cpu"`
+	Memory string `yaml:"memory"`
+	// 可运行的 pod 数，为 0 时不限制
+	Pods int32 `yaml:"pods"`
+}
+
+// ScaleOutConf Pending pod 触发的节点扩容配置
+type ScaleOutConf struct {
+	// pod 因资源不足 Pending 超过该时长（秒）后才申请节点，默认 30
+	PendingSeconds int32 `yaml:"pendingSeconds"`
+	// 两次申请的最小间隔（秒），默认 120
+	MinIntervalSeconds int32 `yaml:"minIntervalSeconds"`
+	// 申请的节点加入集群的超时时间（秒），超时后不再跟踪，pod 仍 Pending 时可重新申请，默认 900
+	JoinTimeoutSeconds int32 `yaml:"joinTimeoutSeconds"`
+}
+
+// CapacityConf 集群容量检查配置
+type CapacityConf struct {
+	// minReplicas 超出集群可容纳的副本数时的处理策略，enum："warn"/"clamp"/"request"，
+	// 为空时配置了 resources 则为 "request"，否则为 "warn"
+	Policy string `yaml:"policy"`
 }
 
 // todo 后面将yaml解析 和 k8s api server 请求结构体解耦
@@ -57,6 +144,32 @@ func checkStrategiesInfoFields(strategiesInfo *StrategiesInfo) error {
 	if strategiesInfo.TargetHPA == "" {
 		return errors.New("strategies target hpa must be set")
 	}
+	for i := 0; i < len(strategiesInfo.Shunting); i++ {
+		if err := checkShuntingFields(&strategiesInfo.Shunting[i]); err != nil {
+			return err
+		}
+	}
+	if strategiesInfo.Gate != nil {
+		if err := checkGateFields(strategiesInfo.Gate); err != nil {
+			return err
+		}
+	}
+	if strategiesInfo.Resources != nil {
+		if err := checkResourceFields(strategiesInfo.Resources); err != nil {
+			return err
+		}
+	}
+	if c := strategiesInfo.Capacity; c != nil {
+		switch c.Policy {
+		case "", CapacityPolicyWarn, CapacityPolicyClamp:
+		case CapacityPolicyRequest:
+			if strategiesInfo.Resources == nil {
+				return errors.New("capacity policy request requires resources")
+			}
+		default:
+			return errors.Errorf("invalid capacity policy[%s]", c.Policy)
+		}
+	}
 	for i := 0; i < len(strategiesInfo.Strategies); i++ {
 		if err := checkStrategyFields(&strategiesInfo.Strategies[i]); err != nil {
 			return err
@@ -77,6 +190,65 @@ func checkStrategyFields(strategy *Strategy) error {
 	return nil
 }
 
+// checkGateFields 校验系统负载门控配置
+func checkGateFields(conf *GateConf) error {
+	if conf.MaxPodCPURatio < 0 || conf.MaxNodeCPURatio < 0 {
+		return errors.Errorf("invalid gate cpu ratio: %+v", *conf)
+	}
+	for _, q := range conf.Queries {
+		if q.Name == "" || q.Query == "" {
+			return errors.Errorf("gate query name and query must be set: %+v", q)
+		}
+	}
+	return nil
+}
+
+// checkResourceFields 校验节点资源配置及节点规格模板，并补全扩容配置的默认值
+func checkResourceFields(conf *ResourceConf) error {
+	if conf.Flavor == "" || conf.ReplicasPerNode <= 0 || conf.MaxNodes < 0 {
+		return errors.Errorf("invalid resources: %+v", *conf)
+	}
+	for _, t := range conf.NodeTemplates {
+		if _, err := t.allocatable(); err != nil || t.Flavor == "" || t.Pods < 0 {
+			return errors.Errorf("invalid node template: %+v, err: %v", t, err)
+		}
+	}
+	if c := conf.ScaleOut; c != nil {
+		if c.PendingSeconds < 0 || c.MinIntervalSeconds < 0 || c.JoinTimeoutSeconds < 0 {
+			return errors.Errorf("invalid scale out conf: %+v", *c)
+		}
+		if c.PendingSeconds == 0 {
+			c.PendingSeconds = defaultScaleOutPendingSeconds
+		}
+		if c.MinIntervalSeconds == 0 {
+			c.MinIntervalSeconds = defaultScaleOutMinIntervalSeconds
+		}
+		if c.JoinTimeoutSeconds == 0 {
+			c.JoinTimeoutSeconds = defaultScaleOutJoinTimeoutSeconds
+		}
+	}
+	return nil
+}
+
+// checkShuntingFields 校验分流配置，并补全默认阈值
+func checkShuntingFields(conf *ShuntingConf) error {
+	if conf.TaskType == "" {
+		return errors.New("shunting task type must be set")
+	}
+	if conf.ReplicaCapacity <= 0 {
+		return errors.Errorf("invalid shunting replica capacity[%v] of task type[%s]", conf.ReplicaCapacity,
+			conf.TaskType)
+	}
+	if conf.LocalThreshold == 0 {
+		conf.LocalThreshold = defaultShuntingLocalThreshold
+	}
+	if conf.LocalThreshold < 0 || conf.LocalThreshold > 1 {
+		return errors.Errorf("invalid shunting local threshold[%v] of task type[%s]", conf.LocalThreshold,
+			conf.TaskType)
+	}
+	return nil
+}
+
 func checkRuleFields(rule *v1alpha1.Rule) error {
 	// actions
 	// metricTrigger
@@ -115,9 +287,9 @@ func completeRules(strategy *Strategy) {
 func completeRuleActions(rule *v1alpha1.Rule) {
 	optType := ""
 	if rule.MetricTrigger.MetricOperation == MetricOptScaleUp { // metricOperation: ">"
-		optType = operationTypeScaleUp
+		optType = OperationTypeScaleUp
 	} else if rule.MetricTrigger.MetricOperation == MetricOptScaleDown { // metricOperation: "<"
-		optType = operationTypeScaleDown
+		optType = OperationTypeScaleDown
 	}
 
 	for i := 0; i < len(rule.Actions); i++ {
@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	"nanto.io/application-auto-scaling-service/pkg/metrics"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+const (
+	// 校验目标负载副本数的轮询间隔
+	defaultVerifyInterval = 10 * time.Second
+
+	kindDeployment  = "Deployment"
+	kindStatefulSet = "StatefulSet"
+
+	// 副本数收敛校验结果
+	verifyResultConverged = "converged"
+	verifyResultTimeout   = "timeout"
+	verifyResultStuck     = "scale-stuck"
+	verifyResultError     = "error"
+)
+
+var errUnsupportedKind = errors.New("unsupported scale target kind")
+
+// workloadReplicas 目标负载的副本数信息
+type workloadReplicas struct {
+	Replicas      int32
+	ReadyReplicas int32
+	Selector      *metav1.LabelSelector
+	// pod 模板，用于计算集群可容纳的副本数
+	Template corev1.PodTemplateSpec
+}
+
+// getWorkloadReplicas 获取 ScaleTargetRef 指向负载的副本数信息，目前支持 Deployment 和 StatefulSet
+func getWorkloadReplicas(ctx context.Context, ref v1alpha1.ScaleTargetRef) (*workloadReplicas, error) {
+	appsCli := k8sclient.GetKubeClientSet().AppsV1()
+	switch ref.Kind {
+	case kindDeployment:
+		d, err := appsCli.Deployments(NamespaceDefault).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "get deployment[%s] err", ref.Name)
+		}
+		return &workloadReplicas{Replicas: d.Status.Replicas, ReadyReplicas: d.Status.ReadyReplicas,
+			Selector: d.Spec.Selector, Template: d.Spec.Template}, nil
+	case kindStatefulSet:
+		sts, err := appsCli.StatefulSets(NamespaceDefault).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "get statefulset[%s] err", ref.Name)
+		}
+		return &workloadReplicas{Replicas: sts.Status.Replicas, ReadyReplicas: sts.Status.ReadyReplicas,
+			Selector: sts.Spec.Selector, Template: sts.Spec.Template}, nil
+	default:
+		return nil, errors.Wrapf(errUnsupportedKind, "kind[%s]", ref.Kind)
+	}
+}
+
+// isConverged 负载的副本数和就绪副本数都落在 [minReplicas, maxReplicas] 内时认为已收敛
+func isConverged(w *workloadReplicas, minReplicas, maxReplicas int32) bool {
+	return w.Replicas >= minReplicas && w.Replicas <= maxReplicas &&
+		w.ReadyReplicas >= minReplicas && w.ReadyReplicas <= maxReplicas
+}
+
+// countPendingPods 统计 Pending 状态的 pod 数，以及其中无法调度（如资源不足）的 pod 数
+func countPendingPods(pods []corev1.Pod) (pending, unschedulable int) {
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodPending {
+			continue
+		}
+		pending++
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type == corev1.PodScheduled && cond.Status == corev1.ConditionFalse &&
+				cond.Reason == corev1.PodReasonUnschedulable {
+				unschedulable++
+				break
+			}
+		}
+	}
+	return pending, unschedulable
+}
+
+// startVerify 策略更新后异步校验目标负载副本数是否收敛，新的校验会取消上一次未完成的校验
+func (s *StrategyController) startVerify(chpa *v1alpha1.CustomedHorizontalPodAutoscaler, window string) {
+	if s.verifyTimeout <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.verifyTimeout)
+	s.mu.Lock()
+	if s.verifyCancel != nil {
+		s.verifyCancel()
+	}
+	s.verifyCancel = cancel
+	s.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		s.verifyScale(ctx, chpa.DeepCopy(), window)
+	}()
+}
+
+// verifyScale 轮询目标负载副本数，直到收敛或超时；超时时根据 Pending pod 区分 scale-stuck
+func (s *StrategyController) verifyScale(ctx context.Context, chpa *v1alpha1.CustomedHorizontalPodAutoscaler, window string) {
+	var (
+		w   *workloadReplicas
+		err error
+	)
+	start := time.Now()
+	ref := chpa.Spec.ScaleTargetRef
+	minReplicas, maxReplicas := utils.Int32Value(chpa.Spec.MinReplicas), utils.Int32Value(chpa.Spec.MaxReplicas)
+
+	ticker := time.NewTicker(s.verifyInterval)
+	defer ticker.Stop()
+	for {
+		if w, err = getWorkloadReplicas(ctx, ref); err != nil {
+			if errors.Is(err, errUnsupportedKind) {
+				logger.Warnf("Skip scale verification of customHPA[%s]: %v", chpa.Name, err)
+				s.observeVerify(chpa.Name, verifyResultError, start)
+				return
+			}
+			logger.Warnf("Get scale target of customHPA[%s] err: %+v", chpa.Name, err)
+		} else if isConverged(w, minReplicas, maxReplicas) {
+			logger.Infof("Scale target %s/%s of customHPA[%s] converged in %s, replicas: %d, readyReplicas: %d",
+				ref.Kind, ref.Name, chpa.Name, time.Since(start), w.Replicas, w.ReadyReplicas)
+			recordEvent(chpa, corev1.EventTypeNormal, EventReasonScaleVerified,
+				"Scale target %s/%s converged after applying window[%s], readyReplicas: %d, expected [%d, %d]",
+				ref.Kind, ref.Name, window, w.ReadyReplicas, minReplicas, maxReplicas)
+			s.observeVerify(chpa.Name, verifyResultConverged, start)
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.Canceled) {
+				logger.Infof("Scale verification of customHPA[%s] window[%s] is superseded", chpa.Name, window)
+				return
+			}
+			s.reportVerifyTimeout(chpa, window, w, start)
+			return
+		}
+	}
+}
+
+// reportVerifyTimeout 副本数未在期限内收敛，存在 Pending pod 时上报 scale-stuck
+func (s *StrategyController) reportVerifyTimeout(chpa *v1alpha1.CustomedHorizontalPodAutoscaler, window string,
+	w *workloadReplicas, start time.Time) {
+	ref := chpa.Spec.ScaleTargetRef
+	minReplicas, maxReplicas := utils.Int32Value(chpa.Spec.MinReplicas), utils.Int32Value(chpa.Spec.MaxReplicas)
+	if w == nil {
+		logger.Errorf("Scale verification of customHPA[%s] timeout, scale target %s/%s unavailable",
+			chpa.Name, ref.Kind, ref.Name)
+		recordEvent(chpa, corev1.EventTypeWarning, EventReasonScaleVerifyTimeout,
+			"Scale target %s/%s unavailable after applying window[%s]", ref.Kind, ref.Name, window)
+		s.observeVerify(chpa.Name, verifyResultTimeout, start)
+		return
+	}
+
+	pending, unschedulable := 0, 0
+	if selector, err := metav1.LabelSelectorAsSelector(w.Selector); err != nil {
+		logger.Warnf("Invalid selector of scale target %s/%s: %v", ref.Kind, ref.Name, err)
+	} else if pods, err := k8sclient.GetKubeClientSet().CoreV1().Pods(NamespaceDefault).List(context.Background(),
+		metav1.ListOptions{LabelSelector: selector.String()}); err != nil {
+		logger.Warnf("List pods of scale target %s/%s err: %v", ref.Kind, ref.Name, err)
+	} else {
+		pending, unschedulable = countPendingPods(pods.Items)
+	}
+
+	if pending > 0 {
+		logger.Errorf("Scale target %s/%s of customHPA[%s] stuck, readyReplicas: %d, pending pods: %d, "+
+			"unschedulable: %d", ref.Kind, ref.Name, chpa.Name, w.ReadyReplicas, pending, unschedulable)
+		recordEvent(chpa, corev1.EventTypeWarning, EventReasonScaleStuck,
+			"Scale target %s/%s stuck after applying window[%s], readyReplicas: %d, expected [%d, %d], "+
+				"%d pods pending (%d unschedulable)",
+			ref.Kind, ref.Name, window, w.ReadyReplicas, minReplicas, maxReplicas, pending, unschedulable)
+		s.observeVerify(chpa.Name, verifyResultStuck, start)
+		return
+	}
+	logger.Errorf("Scale target %s/%s of customHPA[%s] not converged in %s, replicas: %d, readyReplicas: %d",
+		ref.Kind, ref.Name, chpa.Name, s.verifyTimeout, w.Replicas, w.ReadyReplicas)
+	recordEvent(chpa, corev1.EventTypeWarning, EventReasonScaleVerifyTimeout,
+		"Scale target %s/%s not converged in %s after applying window[%s], replicas: %d, readyReplicas: %d, "+
+			"expected [%d, %d]", ref.Kind, ref.Name, s.verifyTimeout, window, w.Replicas, w.ReadyReplicas,
+		minReplicas, maxReplicas)
+	s.observeVerify(chpa.Name, verifyResultTimeout, start)
+}
+
+func (s *StrategyController) observeVerify(target, result string, start time.Time) {
+	metrics.ScaleVerifyTotal.WithLabelValues(target, result).Inc()
+	metrics.ScaleVerifyDurationSeconds.WithLabelValues(target, result).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+func newTestDeployment(replicas, readyReplicas int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: NamespaceDefault},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "worker"}},
+		},
+		Status: appsv1.DeploymentStatus{Replicas: replicas, ReadyReplicas: readyReplicas},
+	}
+}
+
+func newTestPod(name string, phase corev1.PodPhase, unschedulable bool) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: NamespaceDefault, Labels: map[string]string{"app": "worker"}},
+		Status:     corev1.PodStatus{Phase: phase},
+	}
+	if unschedulable {
+		pod.Status.Conditions = []corev1.PodCondition{{
+			Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: corev1.PodReasonUnschedulable,
+		}}
+	}
+	return pod
+}
+
+func Test_countPendingPods(t *testing.T) {
+	pods := []corev1.Pod{
+		*newTestPod("p1", corev1.PodRunning, false),
+		*newTestPod("p2", corev1.PodPending, false),
+		*newTestPod("p3", corev1.PodPending, true),
+	}
+	pending, unschedulable := countPendingPods(pods)
+	if pending != 2 || unschedulable != 1 {
+		t.Errorf("countPendingPods() got = %d, %d, want 2, 1", pending, unschedulable)
+	}
+}
+
+func TestVerifyScale(t *testing.T) {
+	chpa := newTestCustomedHPA("chpa", nil)
+	chpa.Spec.MinReplicas = utils.Int32Ptr(3)
+	chpa.Spec.MaxReplicas = utils.Int32Ptr(9)
+
+	tests := []struct {
+		name     string
+		kubeObjs []runtime.Object
+		want     string
+	}{
+		{"converged", []runtime.Object{newTestDeployment(3, 3)}, EventReasonScaleVerified},
+		{"stuck", []runtime.Object{
+			newTestDeployment(3, 1),
+			newTestPod("p1", corev1.PodRunning, false),
+			newTestPod("p2", corev1.PodPending, true),
+		}, EventReasonScaleStuck},
+		{"timeout", []runtime.Object{newTestDeployment(3, 1)}, EventReasonScaleVerifyTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := setupFakeClientSet(tt.kubeObjs, chpa)
+			s := &StrategyController{verifyTimeout: 50 * time.Millisecond, verifyInterval: 10 * time.Millisecond}
+			ctx, cancel := context.WithTimeout(context.Background(), s.verifyTimeout)
+			defer cancel()
+			s.verifyScale(ctx, chpa, "0:00-09:30")
+
+			if events := drainEvents(recorder); !hasEvent(events, tt.want) {
+				t.Errorf("verifyScale() events = %v, want reason %s", events, tt.want)
+			}
+		})
+	}
+}
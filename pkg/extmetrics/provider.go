@@ -0,0 +1,131 @@
+package extmetrics
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics/v1beta1"
+
+	"nanto.io/application-auto-scaling-service/pkg/loadstore"
+	"nanto.io/application-auto-scaling-service/pkg/promsource"
+	"nanto.io/application-auto-scaling-service/pkg/utils/logutil"
+)
+
+var logger = logutil.GetLogger()
+
+const (
+	// MetricRequestRate 各任务类型最近一段时间的平均请求量（每分钟）
+	MetricRequestRate = "task_request_rate"
+	// MetricQueueLength 各任务类型最新上报的排队任务数
+	MetricQueueLength = "task_queue_length"
+	// LabelTaskType 任务类型标签，HPA 通过 selector 选择任务类型，eg：task_type=transcode
+	LabelTaskType = "task_type"
+)
+
+// ErrMetricNotFound 指标不存在
+var ErrMetricNotFound = errors.New("external metric not found")
+
+// Provider 提供 external.metrics.k8s.io 指标：conductor/worker 上报的负载数据，以及配置的 Prometheus 查询。
+// 指标与 namespace 无关，任意 namespace 下的 HPA 查询结果相同
+type Provider struct {
+	store *loadstore.Store
+	// 计算请求量的时间窗口，超过该时长未上报的排队任务数视为过期
+	rateWindow time.Duration
+	prom       *promsource.Client
+	// 指标名 -> PromQL
+	promQueries map[string]string
+}
+
+// NewProvider 创建 external metrics provider，prom 为 nil 时不提供 Prometheus 查询指标
+func NewProvider(store *loadstore.Store, rateWindow time.Duration, prom *promsource.Client,
+	promQueries map[string]string) *Provider {
+	p := &Provider{store: store, rateWindow: rateWindow, prom: prom, promQueries: map[string]string{}}
+	if prom != nil {
+		for name, query := range promQueries {
+			if name == MetricRequestRate || name == MetricQueueLength {
+				logger.Warnf("External metric[%s] from prometheus conflicts with built-in metric, ignored", name)
+				continue
+			}
+			p.promQueries[name] = query
+		}
+	}
+	return p
+}
+
+// ListMetrics 返回所有指标名，按名称排序
+func (p *Provider) ListMetrics() []string {
+	names := []string{MetricQueueLength, MetricRequestRate}
+	for name := range p.promQueries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetMetric 查询指标在 selector 匹配的各序列上的值
+func (p *Provider) GetMetric(ctx context.Context, name string, selector labels.Selector) (
+	[]v1beta1.ExternalMetricValue, error) {
+	now := time.Now()
+	switch name {
+	case MetricRequestRate, MetricQueueLength:
+		values := []v1beta1.ExternalMetricValue{}
+		for _, taskType := range p.store.TaskTypes() {
+			metricLabels := map[string]string{LabelTaskType: taskType}
+			if !selector.Matches(labels.Set(metricLabels)) {
+				continue
+			}
+			if value, ok := p.taskTypeMetric(name, taskType, now); ok {
+				value.MetricLabels = metricLabels
+				values = append(values, value)
+			}
+		}
+		return values, nil
+	}
+
+	query, ok := p.promQueries[name]
+	if !ok {
+		return nil, errors.Wrapf(ErrMetricNotFound, "metric[%s]", name)
+	}
+	if !selector.Matches(labels.Set{}) {
+		return []v1beta1.ExternalMetricValue{}, nil
+	}
+	value, err := p.prom.Query(ctx, query)
+	if errors.Is(err, promsource.ErrEmptyResult) {
+		return []v1beta1.ExternalMetricValue{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []v1beta1.ExternalMetricValue{newMetricValue(name, value, now)}, nil
+}
+
+// taskTypeMetric 任务类型的负载指标，排队任务数过期时返回 false
+func (p *Provider) taskTypeMetric(name, taskType string, now time.Time) (v1beta1.ExternalMetricValue, bool) {
+	if name == MetricRequestRate {
+		// 时间窗口内无上报数据时请求量为 0
+		rate, _ := p.store.RequestRate(taskType, p.rateWindow, now)
+		value := newMetricValue(name, rate, now)
+		window := int64(p.rateWindow.Seconds())
+		value.WindowSeconds = &window
+		return value, true
+	}
+	latest, ok := p.store.Latest(taskType)
+	if !ok || now.Sub(latest.Time) > p.rateWindow {
+		return v1beta1.ExternalMetricValue{}, false
+	}
+	return newMetricValue(name, latest.QueueLength, latest.Time), true
+}
+
+func newMetricValue(name string, value float64, t time.Time) v1beta1.ExternalMetricValue {
+	return v1beta1.ExternalMetricValue{
+		MetricName: name,
+		Timestamp:  metav1.NewTime(t),
+		Value:      *resource.NewMilliQuantity(int64(math.Round(value*1000)), resource.DecimalSI),
+	}
+}
@@ -0,0 +1,51 @@
+package extmetrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"nanto.io/application-auto-scaling-service/pkg/loadstore"
+)
+
+func TestProvider_GetMetric(t *testing.T) {
+	store := loadstore.NewStore(time.Hour, 100, 0)
+	now := time.Now()
+	store.Add("transcode", loadstore.Sample{Time: now.Add(-2 * time.Minute), Requests: 300, QueueLength: 8})
+	store.Add("transcode", loadstore.Sample{Time: now.Add(-time.Minute), Requests: 200, QueueLength: 12})
+	// 排队任务数已过期
+	store.Add("snapshot", loadstore.Sample{Time: now.Add(-30 * time.Minute), Requests: 50, QueueLength: 3})
+	p := NewProvider(store, 5*time.Minute, nil, map[string]string{"pending": "sum(pending)"})
+	ctx := context.Background()
+
+	if got := p.ListMetrics(); len(got) != 2 {
+		t.Errorf("ListMetrics() without prometheus = %v, want 2 metrics", got)
+	}
+
+	values, err := p.GetMetric(ctx, MetricRequestRate, labels.Everything())
+	if err != nil || len(values) != 2 {
+		t.Fatalf("GetMetric(%s) = %+v, err: %v", MetricRequestRate, values, err)
+	}
+	// 按任务类型排序：snapshot 无近期数据，请求量为 0；transcode 5 分钟内 500 个请求
+	if values[0].MetricLabels[LabelTaskType] != "snapshot" || values[0].Value.MilliValue() != 0 ||
+		values[1].Value.MilliValue() != 100000 || *values[1].WindowSeconds != 300 {
+		t.Errorf("GetMetric(%s) = %+v", MetricRequestRate, values)
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{LabelTaskType: "transcode"})
+	values, err = p.GetMetric(ctx, MetricQueueLength, selector)
+	if err != nil || len(values) != 1 || values[0].Value.Value() != 12 {
+		t.Errorf("GetMetric(%s, transcode) = %+v, err: %v", MetricQueueLength, values, err)
+	}
+	selector = labels.SelectorFromSet(labels.Set{LabelTaskType: "snapshot"})
+	if values, _ = p.GetMetric(ctx, MetricQueueLength, selector); len(values) != 0 {
+		t.Errorf("GetMetric(%s, snapshot) stale = %+v, want empty", MetricQueueLength, values)
+	}
+
+	if _, err = p.GetMetric(ctx, "pending", labels.Everything()); !errors.Is(err, ErrMetricNotFound) {
+		t.Errorf("GetMetric(pending) without prometheus err = %v, want ErrMetricNotFound", err)
+	}
+}
@@ -0,0 +1,265 @@
+package grm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/metrics"
+	"nanto.io/application-auto-scaling-service/pkg/utils/logutil"
+)
+
+// GRM（第三方资源管理系统）HTTP API：
+//
+//	POST /v1/clusters/{clusterId}/allocations                申请节点，body: AcquireRequest
+//	GET  /v1/clusters/{clusterId}/allocations?owner={owner}  查询申请记录
+//	GET  /v1/clusters/{clusterId}/allocations/{id}           查询单个申请记录
+//	POST /v1/clusters/{clusterId}/allocations/{id}/release   释放节点，body: ReleaseRequest
+//
+// 写操作携带 X-Idempotency-Key 请求头，重试时使用相同的 key，GRM 对相同 key 的请求只处理一次
+const (
+	idempotencyKeyHeader = "X-Idempotency-Key"
+	// 环境变量中的 GRM token，配置文件中未设置 token 时使用
+	tokenEnv = "grm_token"
+)
+
+// 申请记录的状态
+const (
+	StatusPending   = "Pending"
+	StatusFulfilled = "Fulfilled"
+	StatusFailed    = "Failed"
+	StatusReleased  = "Released"
+)
+
+var logger = logutil.GetLogger()
+
+var (
+	// ErrNotFound 申请记录不存在
+	ErrNotFound = errors.New("grm allocation not found")
+
+	client *Client
+)
+
+// AcquireRequest 申请节点
+type AcquireRequest struct {
+	// 申请方，eg：目标 CustomedHPA 名称
+	Owner  string `json:"owner"`
+	Flavor string `json:"flavor"`
+	Count  int32  `json:"count"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ReleaseRequest 释放申请记录中的节点，Nodes 为空时释放全部节点
+type ReleaseRequest struct {
+	Nodes []string `json:"nodes,omitempty"`
+}
+
+// Allocation GRM 中的一次节点申请记录
+type Allocation struct {
+	ID     string `json:"id"`
+	Owner  string `json:"owner"`
+	Flavor string `json:"flavor"`
+	Count  int32  `json:"count"`
+	Status string `json:"status"`
+	// 已交付（未释放）的节点名称
+	Nodes     []string  `json:"nodes"`
+	Message   string    `json:"message,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type allocationList struct {
+	Allocations []Allocation `json:"allocations"`
+}
+
+// Client GRM HTTP API 客户端，网络错误、429 和 5xx 响应按指数退避重试
+type Client struct {
+	endpoint      string
+	clusterID     string
+	token         string
+	httpClient    *http.Client
+	maxRetries    int
+	retryInterval time.Duration
+}
+
+// Init 根据配置初始化全局 GRM 客户端，未配置 endpoint 时不初始化；轮询间隔、申请超时时间须大于 0
+func Init(conf *config.GRMConf, clusterID string) error {
+	if conf.Endpoint == "" {
+		return nil
+	}
+	if conf.PollIntervalSecond <= 0 {
+		return errors.Errorf("invalid grm poll interval[%d]", conf.PollIntervalSecond)
+	}
+	if conf.AcquireTimeoutSecond <= 0 {
+		return errors.Errorf("invalid grm acquire timeout[%d]", conf.AcquireTimeoutSecond)
+	}
+	c, err := NewClient(conf, clusterID)
+	if err != nil {
+		return err
+	}
+	client = c
+	return nil
+}
+
+// GetClient 获取全局 GRM 客户端，未配置时返回 nil
+func GetClient() *Client {
+	return client
+}
+
+// SetClient 直接指定全局 GRM 客户端，用于单测
+func SetClient(c *Client) {
+	client = c
+}
+
+// NewClient 创建 GRM 客户端
+func NewClient(conf *config.GRMConf, clusterID string) (*Client, error) {
+	if _, err := url.Parse(conf.Endpoint); err != nil || conf.Endpoint == "" {
+		return nil, errors.Errorf("invalid grm endpoint[%s]", conf.Endpoint)
+	}
+	token := conf.Token
+	if token == "" {
+		token = os.Getenv(tokenEnv)
+	}
+	return &Client{
+		endpoint:      strings.TrimSuffix(conf.Endpoint, "/"),
+		clusterID:     clusterID,
+		token:         token,
+		httpClient:    &http.Client{Timeout: time.Duration(conf.TimeoutSecond) * time.Second},
+		maxRetries:    conf.MaxRetries,
+		retryInterval: time.Duration(conf.RetryIntervalMillisecond) * time.Millisecond,
+	}, nil
+}
+
+// Acquire 申请节点，idempotencyKey 相同的请求只会申请一次
+func (c *Client) Acquire(ctx context.Context, req *AcquireRequest, idempotencyKey string) (*Allocation, error) {
+	allocation := &Allocation{}
+	err := c.do(ctx, "acquire", http.MethodPost, c.allocationsPath(), req, idempotencyKey, allocation)
+	return allocation, err
+}
+
+// Get 查询申请记录
+func (c *Client) Get(ctx context.Context, id string) (*Allocation, error) {
+	allocation := &Allocation{}
+	err := c.do(ctx, "get", http.MethodGet, c.allocationsPath()+"/"+url.PathEscape(id), nil, "", allocation)
+	return allocation, err
+}
+
+// List 查询申请方的所有申请记录
+func (c *Client) List(ctx context.Context, owner string) ([]Allocation, error) {
+	list := &allocationList{}
+	path := c.allocationsPath() + "?" + url.Values{"owner": {owner}}.Encode()
+	if err := c.do(ctx, "list", http.MethodGet, path, nil, "", list); err != nil {
+		return nil, err
+	}
+	return list.Allocations, nil
+}
+
+// Release 释放申请记录中的节点，idempotencyKey 相同的请求只会释放一次
+func (c *Client) Release(ctx context.Context, id string, req *ReleaseRequest, idempotencyKey string) (
+	*Allocation, error) {
+	allocation := &Allocation{}
+	path := c.allocationsPath() + "/" + url.PathEscape(id) + "/release"
+	err := c.do(ctx, "release", http.MethodPost, path, req, idempotencyKey, allocation)
+	return allocation, err
+}
+
+func (c *Client) allocationsPath() string {
+	return "/v1/clusters/" + url.PathEscape(c.clusterID) + "/allocations"
+}
+
+// do 发送请求并解析响应，可重试的错误按指数退避重试，直到超出重试次数或 ctx 结束
+func (c *Client) do(ctx context.Context, operation, method, path string, body interface{}, idempotencyKey string,
+	result interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return errors.Wrap(err, "marshal grm request err")
+		}
+	}
+
+	interval := c.retryInterval
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retryable bool
+		retryable, err = c.doOnce(ctx, method, path, payload, idempotencyKey, result)
+		if err == nil {
+			metrics.GRMRequestsTotal.WithLabelValues(operation, "success").Inc()
+			return nil
+		}
+		if !retryable || attempt >= c.maxRetries {
+			break
+		}
+		logger.Warnf("Grm %s request %s attempt %d failed, retry in %s: %v", method, path, attempt+1, interval, err)
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			err = errors.Wrap(ctx.Err(), err.Error())
+			metrics.GRMRequestsTotal.WithLabelValues(operation, "error").Inc()
+			return err
+		}
+		interval *= 2
+	}
+	metrics.GRMRequestsTotal.WithLabelValues(operation, "error").Inc()
+	return err
+}
+
+// doOnce 发送一次请求，返回错误是否可重试
+func (c *Client) doOnce(ctx context.Context, method, path string, payload []byte, idempotencyKey string,
+	result interface{}) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, bytes.NewReader(payload))
+	if err != nil {
+		return false, errors.Wrap(err, "new grm request err")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if idempotencyKey != "" {
+		req.Header.Set(idempotencyKeyHeader, idempotencyKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, errors.Wrapf(err, "grm %s %s err", method, path)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return true, errors.Wrap(err, "read grm response err")
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, errors.Wrapf(ErrNotFound, "grm %s %s", method, path)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return true, errors.Errorf("grm %s %s response status[%d], body: %s", method, path, resp.StatusCode, respBody)
+	case resp.StatusCode >= http.StatusBadRequest:
+		return false, errors.Errorf("grm %s %s response status[%d], body: %s", method, path, resp.StatusCode,
+			respBody)
+	}
+	if err = json.Unmarshal(respBody, result); err != nil {
+		return false, errors.Wrapf(err, "unmarshal grm response err, body: %s", respBody)
+	}
+	return false, nil
+}
+
+// IdempotencyKey 根据操作的各项参数生成幂等 key，参数相同的操作 key 相同
+func IdempotencyKey(parts ...interface{}) string {
+	strs := make([]string, 0, len(parts))
+	for _, part := range parts {
+		strs = append(strs, fmt.Sprint(part))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(strs, "/")))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,106 @@
+package grm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+)
+
+func newTestClient(t *testing.T, endpoint string) *Client {
+	c, err := NewClient(&config.GRMConf{Endpoint: endpoint, Token: "secret", TimeoutSecond: 1, MaxRetries: 2,
+		RetryIntervalMillisecond: 1}, "cluster-1")
+	if err != nil {
+		t.Fatalf("NewClient() err: %+v", err)
+	}
+	return c
+}
+
+func TestClient_AcquireAndRelease(t *testing.T) {
+	fake := NewFakeServer()
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	c := newTestClient(t, srv.URL)
+	ctx := context.Background()
+	acquirePath := "POST /v1/clusters/cluster-1/allocations"
+
+	// 503 后重试成功，重试使用相同的幂等 key
+	fake.FailNext = 2
+	req := &AcquireRequest{Owner: "customedhpa01", Flavor: "c6.2xlarge", Count: 2}
+	allocation, err := c.Acquire(ctx, req, "key-1")
+	if err != nil || allocation.Status != StatusFulfilled || len(allocation.Nodes) != 2 {
+		t.Fatalf("Acquire() = %+v, err: %+v", allocation, err)
+	}
+	if fake.Calls[acquirePath] != 3 {
+		t.Errorf("Acquire() calls = %d, want 3", fake.Calls[acquirePath])
+	}
+
+	// 相同幂等 key 不重复申请
+	again, err := c.Acquire(ctx, req, "key-1")
+	if err != nil || again.ID != allocation.ID || len(fake.Allocations) != 1 {
+		t.Errorf("Acquire() with same key = %+v, allocations %d, err: %v", again, len(fake.Allocations), err)
+	}
+
+	// 超出重试次数
+	fake.FailNext = 3
+	if _, err = c.Acquire(ctx, req, "key-2"); err == nil {
+		t.Errorf("Acquire() should fail after retries")
+	}
+
+	released, err := c.Release(ctx, allocation.ID, &ReleaseRequest{Nodes: allocation.Nodes[:1]}, "key-3")
+	if err != nil || len(released.Nodes) != 1 || released.Status != StatusFulfilled {
+		t.Errorf("Release() = %+v, err: %v", released, err)
+	}
+	list, err := c.List(ctx, "customedhpa01")
+	if err != nil || len(list) != 1 || len(list[0].Nodes) != 1 {
+		t.Errorf("List() = %+v, err: %v", list, err)
+	}
+	if _, err = c.Get(ctx, "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(unknown) err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestClient_Timeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		time.Sleep(1500 * time.Millisecond)
+	}))
+	defer srv.Close()
+	c := newTestClient(t, srv.URL)
+	c.maxRetries = 0
+
+	start := time.Now()
+	if _, err := c.List(context.Background(), "customedhpa01"); err == nil {
+		t.Errorf("List() should time out")
+	}
+	if elapsed := time.Since(start); elapsed > 1400*time.Millisecond {
+		t.Errorf("List() took %s, want about 1s", elapsed)
+	}
+
+	c.token = ""
+	if _, err := c.List(context.Background(), "customedhpa01"); err == nil {
+		t.Errorf("List() without token should fail")
+	}
+}
+
+func TestInit_invalid(t *testing.T) {
+	defer SetClient(nil)
+	if err := Init(&config.GRMConf{Endpoint: "http://grm", AcquireTimeoutSecond: 600}, "cluster-1"); err == nil {
+		t.Errorf("Init() with zero poll interval err = nil, want error")
+	}
+	if err := Init(&config.GRMConf{Endpoint: "http://grm", PollIntervalSecond: 10}, "cluster-1"); err == nil {
+		t.Errorf("Init() with zero acquire timeout err = nil, want error")
+	}
+	if err := Init(&config.GRMConf{Endpoint: "http://grm", PollIntervalSecond: 10, AcquireTimeoutSecond: 600},
+		"cluster-1"); err != nil || GetClient() == nil {
+		t.Errorf("Init() err: %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package grm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeServer 模拟 GRM HTTP API 的 http.Handler，用于单测（配合 httptest.NewServer）：
+// 申请立即交付（Pending 为 true 时首次查询前保持 Pending），相同幂等 key 的写请求返回第一次的结果
+type FakeServer struct {
+	mu sync.Mutex
+	// 接下来的 N 个请求返回 503
+	FailNext int
+	// 申请后首次查询前保持 Pending 状态
+	Pending bool
+	// 各接口收到的请求次数（含失败），key 为 "METHOD path"
+	Calls       map[string]int
+	Allocations []*Allocation
+	idempotency map[string]*Allocation
+	seq         int
+}
+
+// NewFakeServer 创建 fake GRM
+func NewFakeServer() *FakeServer {
+	return &FakeServer{Calls: map[string]int{}, idempotency: map[string]*Allocation{}}
+}
+
+// AddAllocation 添加已交付的申请记录
+func (f *FakeServer) AddAllocation(owner string, nodes ...string) *Allocation {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.addAllocation(owner, "", int32(len(nodes)), StatusFulfilled, nodes)
+}
+
+func (f *FakeServer) addAllocation(owner, flavor string, count int32, status string, nodes []string) *Allocation {
+	f.seq++
+	allocation := &Allocation{ID: fmt.Sprintf("alloc-%d", f.seq), Owner: owner, Flavor: flavor, Count: count,
+		Status: status, Nodes: nodes, CreatedAt: time.Now().Add(time.Duration(f.seq) * time.Second)}
+	f.Allocations = append(f.Allocations, allocation)
+	return allocation
+}
+
+// ServeHTTP 处理 GRM API 请求
+func (f *FakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Calls[r.Method+" "+r.URL.Path]++
+	if f.FailNext > 0 {
+		f.FailNext--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	key := r.Header.Get(idempotencyKeyHeader)
+	if r.Method == http.MethodPost {
+		if allocation, ok := f.idempotency[key]; ok {
+			writeFakeJSON(w, allocation)
+			return
+		}
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	// v1/clusters/{clusterId}/allocations[/{id}[/release]]
+	if len(parts) < 4 || parts[3] != "allocations" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch {
+	case len(parts) == 4 && r.Method == http.MethodGet:
+		list := &allocationList{Allocations: []Allocation{}}
+		for _, allocation := range f.Allocations {
+			if allocation.Owner == r.URL.Query().Get("owner") {
+				list.Allocations = append(list.Allocations, *allocation)
+			}
+		}
+		writeFakeJSON(w, list)
+	case len(parts) == 4 && r.Method == http.MethodPost:
+		req := &AcquireRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil || req.Count <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		status := StatusFulfilled
+		if f.Pending {
+			status = StatusPending
+		}
+		nodes := make([]string, 0, req.Count)
+		for i := int32(0); i < req.Count; i++ {
+			nodes = append(nodes, fmt.Sprintf("grm-node-%d-%d", f.seq+1, i))
+		}
+		allocation := f.addAllocation(req.Owner, req.Flavor, req.Count, status, nodes)
+		f.idempotency[key] = allocation
+		writeFakeJSON(w, allocation)
+	case len(parts) >= 5:
+		allocation := f.find(parts[4])
+		if allocation == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if len(parts) == 6 && parts[5] == "release" && r.Method == http.MethodPost {
+			req := &ReleaseRequest{}
+			_ = json.NewDecoder(r.Body).Decode(req)
+			allocation.Nodes = removeNodes(allocation.Nodes, req.Nodes)
+			if len(allocation.Nodes) == 0 {
+				allocation.Status = StatusReleased
+			}
+			f.idempotency[key] = allocation
+		} else if allocation.Status == StatusPending {
+			allocation.Status = StatusFulfilled
+		}
+		writeFakeJSON(w, allocation)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *FakeServer) find(id string) *Allocation {
+	for _, allocation := range f.Allocations {
+		if allocation.ID == id {
+			return allocation
+		}
+	}
+	return nil
+}
+
+// removeNodes 释放指定节点，nodes 为空时全部释放
+func removeNodes(held, nodes []string) []string {
+	if len(nodes) == 0 {
+		return nil
+	}
+	released := map[string]bool{}
+	for _, node := range nodes {
+		released[node] = true
+	}
+	remain := []string{}
+	for _, node := range held {
+		if !released[node] {
+			remain = append(remain, node)
+		}
+	}
+	return remain
+}
+
+func writeFakeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
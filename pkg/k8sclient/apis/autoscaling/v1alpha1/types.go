@@ -25,8 +25,8 @@ type CustomedHorizontalPodAutoscaler struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec CustomedHorizontalPodAutoscalerSpec `json:"spec"`
-	//Status CustomedHorizontalPodAutoscalerStatus `json:"status,omitempty"`
+	Spec   CustomedHorizontalPodAutoscalerSpec   `json:"spec"`
+	Status CustomedHorizontalPodAutoscalerStatus `json:"status,omitempty"`
 }
 
 type CustomedHorizontalPodAutoscalerSpec struct {
@@ -61,6 +61,18 @@ type MetricTrigger struct {
 	Statistic       string   `json:"statistic" yaml:"statistic"`
 }
 
+// CustomedHorizontalPodAutoscalerStatus is the status of a CustomedHorizontalPodAutoscaler, maintained by CCE
+type CustomedHorizontalPodAutoscalerStatus struct {
+	// ObservedGeneration is the most recent generation observed by the autoscaler
+	ObservedGeneration *int64 `json:"observedGeneration,omitempty"`
+	// LastScaleTime is the last time the autoscaler scaled the number of replicas
+	LastScaleTime *metav1.Time `json:"lastScaleTime,omitempty"`
+	// CurrentReplicas is the current number of replicas of the scale target
+	CurrentReplicas int32 `json:"currentReplicas"`
+	// DesiredReplicas is the desired number of replicas calculated by the autoscaler
+	DesiredReplicas int32 `json:"desiredReplicas"`
+}
+
 type ScaleTargetRef struct {
 	ApiVersion string `json:"apiVersion"`
 	Kind       string `json:"kind"`
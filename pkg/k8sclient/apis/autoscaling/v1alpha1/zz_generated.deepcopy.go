@@ -51,6 +51,7 @@ func (in *CustomedHorizontalPodAutoscaler) DeepCopyInto(out *CustomedHorizontalP
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -139,6 +140,31 @@ func (in *CustomedHorizontalPodAutoscalerSpec) DeepCopy() *CustomedHorizontalPod
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CustomedHorizontalPodAutoscalerStatus) DeepCopyInto(out *CustomedHorizontalPodAutoscalerStatus) {
+	*out = *in
+	if in.ObservedGeneration != nil {
+		in, out := &in.ObservedGeneration, &out.ObservedGeneration
+		*out = new(int64)
+		**out = **in
+	}
+	if in.LastScaleTime != nil {
+		in, out := &in.LastScaleTime, &out.LastScaleTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CustomedHorizontalPodAutoscalerStatus.
+func (in *CustomedHorizontalPodAutoscalerStatus) DeepCopy() *CustomedHorizontalPodAutoscalerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CustomedHorizontalPodAutoscalerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetricTrigger) DeepCopyInto(out *MetricTrigger) {
 	*out = *in
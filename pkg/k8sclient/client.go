@@ -4,6 +4,8 @@ import (
 	"github.com/pkg/errors"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
+	metricsclientset "k8s.io/metrics/pkg/client/clientset/versioned"
 
 	apiextensionsclientset "nanto.io/application-auto-scaling-service/pkg/k8sclient/clientset/versioned"
 	"nanto.io/application-auto-scaling-service/pkg/utils/logutil"
@@ -16,13 +18,17 @@ var clientSet *K8sClientSet
 // K8sClientSet 包含 标准kube clientset 和 自定义资源的 clientset
 type K8sClientSet struct {
 	// kubeClientset is a standard kubernetes clientset
-	kubeClientset *kubernetes.Clientset
+	kubeClientset kubernetes.Interface
 	// crdClientset is a clientset for our own API group
 	crdClientset apiextensionsclientset.Interface
+	// eventRecorder 记录 k8s event
+	eventRecorder record.EventRecorder
+	// metricsClientset 访问 metrics.k8s.io（metrics-server）的 clientset
+	metricsClientset metricsclientset.Interface
 }
 
 // GetKubeClientSet 获取 标准kube clientset
-func GetKubeClientSet() *kubernetes.Clientset {
+func GetKubeClientSet() kubernetes.Interface {
 	if clientSet == nil {
 		logger.Panic("K8sClientSet invalid")
 	}
@@ -37,6 +43,22 @@ func GetCrdClientSet() apiextensionsclientset.Interface {
 	return clientSet.crdClientset
 }
 
+// GetEventRecorder 获取 k8s event recorder
+func GetEventRecorder() record.EventRecorder {
+	if clientSet == nil {
+		logger.Panic("K8sClientSet invalid")
+	}
+	return clientSet.eventRecorder
+}
+
+// GetMetricsClientSet 获取 metrics.k8s.io 的 clientset
+func GetMetricsClientSet() metricsclientset.Interface {
+	if clientSet == nil {
+		logger.Panic("K8sClientSet invalid")
+	}
+	return clientSet.metricsClientset
+}
+
 // InitK8sClientSet 初始化 k8s client set
 func InitK8sClientSet(kubeconfig string) error {
 	cfg, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
@@ -51,9 +73,30 @@ func InitK8sClientSet(kubeconfig string) error {
 	if err != nil {
 		return errors.Wrap(err, "Error building example clientset")
 	}
+	metricsClient, err := metricsclientset.NewForConfig(cfg)
+	if err != nil {
+		return errors.Wrap(err, "Error building metrics clientset")
+	}
+	clientSet = &K8sClientSet{
+		kubeClientset:    kubeClient,
+		crdClientset:     crdClient,
+		eventRecorder:    newEventRecorder(kubeClient),
+		metricsClientset: metricsClient,
+	}
+	return nil
+}
+
+// SetK8sClientSet 直接指定 client set，用于单测中注入 fake clientset
+func SetK8sClientSet(kubeClient kubernetes.Interface, crdClient apiextensionsclientset.Interface,
+	recorder record.EventRecorder) {
 	clientSet = &K8sClientSet{
 		kubeClientset: kubeClient,
 		crdClientset:  crdClient,
+		eventRecorder: recorder,
 	}
-	return nil
+}
+
+// SetMetricsClientSet 直接指定 metrics clientset，用于单测中注入 fake clientset，需在 SetK8sClientSet 之后调用
+func SetMetricsClientSet(metricsClient metricsclientset.Interface) {
+	clientSet.metricsClientset = metricsClient
 }
@@ -40,6 +40,7 @@ type CustomedHorizontalPodAutoscalersGetter interface {
 type CustomedHorizontalPodAutoscalerInterface interface {
 	Create(ctx context.Context, customedHorizontalPodAutoscaler *v1alpha1.CustomedHorizontalPodAutoscaler, opts v1.CreateOptions) (*v1alpha1.CustomedHorizontalPodAutoscaler, error)
 	Update(ctx context.Context, customedHorizontalPodAutoscaler *v1alpha1.CustomedHorizontalPodAutoscaler, opts v1.UpdateOptions) (*v1alpha1.CustomedHorizontalPodAutoscaler, error)
+	UpdateStatus(ctx context.Context, customedHorizontalPodAutoscaler *v1alpha1.CustomedHorizontalPodAutoscaler, opts v1.UpdateOptions) (*v1alpha1.CustomedHorizontalPodAutoscaler, error)
 	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
 	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
 	Get(ctx context.Context, name string, opts v1.GetOptions) (*v1alpha1.CustomedHorizontalPodAutoscaler, error)
@@ -135,6 +136,22 @@ func (c *customedHorizontalPodAutoscalers) Update(ctx context.Context, customedH
 	return
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *customedHorizontalPodAutoscalers) UpdateStatus(ctx context.Context, customedHorizontalPodAutoscaler *v1alpha1.CustomedHorizontalPodAutoscaler, opts v1.UpdateOptions) (result *v1alpha1.CustomedHorizontalPodAutoscaler, err error) {
+	result = &v1alpha1.CustomedHorizontalPodAutoscaler{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("customedhorizontalpodautoscalers").
+		Name(customedHorizontalPodAutoscaler.Name).
+		SubResource("status").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Body(customedHorizontalPodAutoscaler).
+		Do(ctx).
+		Into(result)
+	return
+}
+
 // Delete takes name of the customedHorizontalPodAutoscaler and deletes it. Returns an error if one occurs.
 func (c *customedHorizontalPodAutoscalers) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
 	return c.client.Delete().
@@ -102,6 +102,18 @@ func (c *FakeCustomedHorizontalPodAutoscalers) Update(ctx context.Context, custo
 	return obj.(*v1alpha1.CustomedHorizontalPodAutoscaler), err
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeCustomedHorizontalPodAutoscalers) UpdateStatus(ctx context.Context, customedHorizontalPodAutoscaler *v1alpha1.CustomedHorizontalPodAutoscaler, opts v1.UpdateOptions) (*v1alpha1.CustomedHorizontalPodAutoscaler, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(customedhorizontalpodautoscalersResource, "status", c.ns, customedHorizontalPodAutoscaler), &v1alpha1.CustomedHorizontalPodAutoscaler{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.CustomedHorizontalPodAutoscaler), err
+}
+
 // Delete takes name of the customedHorizontalPodAutoscaler and deletes it. Returns an error if one occurs.
 func (c *FakeCustomedHorizontalPodAutoscalers) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
 	_, err := c.Fake.
@@ -0,0 +1,29 @@
+package k8sclient
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	kubescheme "k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	crdscheme "nanto.io/application-auto-scaling-service/pkg/k8sclient/clientset/versioned/scheme"
+)
+
+// eventSourceComponent 上报 event 时的来源组件名
+const eventSourceComponent = "application-auto-scaling-service"
+
+// newEventRecorder 创建 event recorder，event 同时写入 api server 和日志
+func newEventRecorder(kubeClient kubernetes.Interface) record.EventRecorder {
+	// recorder 需要通过 scheme 解析自定义资源（CustomedHPA）的 GVK
+	scheme := runtime.NewScheme()
+	utilruntime.Must(kubescheme.AddToScheme(scheme))
+	utilruntime.Must(crdscheme.AddToScheme(scheme))
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartLogging(logger.Debugf)
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme, corev1.EventSource{Component: eventSourceComponent})
+}
@@ -0,0 +1,113 @@
+package loadstore
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// fileSample 负载数据文件中的一条记录
+type fileSample struct {
+	TaskType    string    `json:"taskType"`
+	Time        time.Time `json:"time"`
+	Requests    float64   `json:"requests"`
+	QueueLength float64   `json:"queueLength"`
+}
+
+// ReadFile 读取负载数据文件，按任务类型分组并按时间升序返回。支持两种格式：
+//   - .json：[{"taskType": "transcode", "time": "2021-10-01T08:00:00+08:00", "requests": 120, "queueLength": 5}]
+//   - .csv：表头为 time,taskType,requests[,queueLength]，time 为 RFC3339 格式
+func ReadFile(path string) (map[string][]Sample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open load file[%s] err", path)
+	}
+	defer f.Close()
+
+	var records []fileSample
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		if err = json.NewDecoder(f).Decode(&records); err != nil {
+			return nil, errors.Wrapf(err, "decode load file[%s] err", path)
+		}
+	case ".csv":
+		if records, err = readCSV(f); err != nil {
+			return nil, errors.Wrapf(err, "read load file[%s] err", path)
+		}
+	default:
+		return nil, errors.Errorf("unsupported load file[%s], expect .json or .csv", path)
+	}
+
+	result := map[string][]Sample{}
+	for _, record := range records {
+		if record.TaskType == "" {
+			return nil, errors.Errorf("task type of load record at %s is empty", record.Time)
+		}
+		result[record.TaskType] = append(result[record.TaskType], Sample{Time: record.Time,
+			Requests: record.Requests, QueueLength: record.QueueLength})
+	}
+	for _, samples := range result {
+		sort.SliceStable(samples, func(i, j int) bool {
+			return samples[i].Time.Before(samples[j].Time)
+		})
+	}
+	return result, nil
+}
+
+func readCSV(r io.Reader) ([]fileSample, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "read csv header err")
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{"time", "taskType", "requests"} {
+		if _, ok := columns[name]; !ok {
+			return nil, errors.Errorf("csv column[%s] not found", name)
+		}
+	}
+
+	records := []fileSample{}
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "read csv line[%d] err", line)
+		}
+		record := fileSample{TaskType: field(row, columns["taskType"])}
+		if record.Time, err = time.Parse(time.RFC3339, field(row, columns["time"])); err != nil {
+			return nil, errors.Wrapf(err, "invalid time at csv line[%d]", line)
+		}
+		if record.Requests, err = strconv.ParseFloat(field(row, columns["requests"]), 64); err != nil {
+			return nil, errors.Wrapf(err, "invalid requests at csv line[%d]", line)
+		}
+		if i, ok := columns["queueLength"]; ok && field(row, i) != "" {
+			if record.QueueLength, err = strconv.ParseFloat(field(row, i), 64); err != nil {
+				return nil, errors.Wrapf(err, "invalid queueLength at csv line[%d]", line)
+			}
+		}
+		records = append(records, record)
+	}
+}
+
+func field(row []string, i int) string {
+	if i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
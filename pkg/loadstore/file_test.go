@@ -0,0 +1,47 @@
+package loadstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loadstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"load.json": `[{"taskType":"transcode","time":"2021-10-01T08:10:00+08:00","requests":20},
+			{"taskType":"transcode","time":"2021-10-01T08:00:00+08:00","requests":10,"queueLength":2},
+			{"taskType":"live","time":"2021-10-01T08:00:00+08:00","requests":5}]`,
+		"load.csv": "time,taskType,requests,queueLength\n" +
+			"2021-10-01T08:10:00+08:00,transcode,20,\n" +
+			"2021-10-01T08:00:00+08:00,transcode,10,2\n" +
+			"2021-10-01T08:00:00+08:00,live,5,0\n",
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile() err: %v", err)
+			}
+			transcode := got["transcode"]
+			if len(got) != 2 || len(transcode) != 2 || transcode[0].Requests != 10 || transcode[0].QueueLength != 2 ||
+				transcode[1].Requests != 20 {
+				t.Errorf("ReadFile() got = %+v", got)
+			}
+		})
+	}
+
+	if _, err = ReadFile(filepath.Join(dir, "load.txt")); err == nil {
+		t.Errorf("ReadFile() with unsupported extension expect err")
+	}
+}
@@ -0,0 +1,215 @@
+package loadstore
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrTooManyTaskTypes 任务类型数达到上限，不再接收新任务类型的数据
+var ErrTooManyTaskTypes = errors.New("too many task types")
+
+// minRingSize 环形缓冲首次分配的容量，之后按需翻倍直到 capacity
+const minRingSize = 64
+
+// Sample 某个任务类型在某一时刻上报的负载数据
+type Sample struct {
+	Time time.Time `json:"time"`
+	// 上报周期内的请求数
+	Requests float64 `json:"requests"`
+	// 上报时刻排队中的任务数
+	QueueLength float64 `json:"queueLength"`
+}
+
+// Store 按任务类型保存负载时序数据，每个任务类型一个按需扩容、容量有上限的环形缓冲，超出保留时长的数据被丢弃
+type Store struct {
+	retention time.Duration
+	capacity  int
+	// 最多保存的任务类型数，不大于 0 时不限制
+	maxTaskTypes int
+	// 当前时间，测试中可替换
+	now func() time.Time
+
+	mu     sync.RWMutex
+	series map[string]*ring
+}
+
+func NewStore(retention time.Duration, capacity, maxTaskTypes int) *Store {
+	return &Store{
+		retention:    retention,
+		capacity:     capacity,
+		maxTaskTypes: maxTaskTypes,
+		now:          time.Now,
+		series:       map[string]*ring{},
+	}
+}
+
+// CheckTaskTypes 检查加入这些任务类型后是否超出任务类型数上限，超出时返回 ErrTooManyTaskTypes
+func (s *Store) CheckTaskTypes(taskTypes []string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.checkTaskTypes(taskTypes)
+}
+
+func (s *Store) checkTaskTypes(taskTypes []string) error {
+	if s.maxTaskTypes <= 0 {
+		return nil
+	}
+	added := map[string]struct{}{}
+	for _, taskType := range taskTypes {
+		if _, ok := s.series[taskType]; !ok {
+			added[taskType] = struct{}{}
+		}
+	}
+	if len(s.series)+len(added) > s.maxTaskTypes {
+		return errors.Wrapf(ErrTooManyTaskTypes, "%d task types exist, limit %d", len(s.series), s.maxTaskTypes)
+	}
+	return nil
+}
+
+// Add 追加一个负载数据，时间早于该任务类型最新数据时按时间顺序插入；
+// 按当前时间丢弃超出保留时长的数据，避免一个时间异常的数据清空整个序列。新任务类型超出上限时返回 ErrTooManyTaskTypes
+func (s *Store) Add(taskType string, sample Sample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.series[taskType]
+	if !ok {
+		if err := s.checkTaskTypes([]string{taskType}); err != nil {
+			return err
+		}
+		r = newRing(s.capacity)
+		s.series[taskType] = r
+	}
+	r.add(sample)
+	r.expire(s.now().Add(-s.retention))
+	return nil
+}
+
+// Range 获取 [from, to) 时间范围内的负载数据，按时间升序
+func (s *Store) Range(taskType string, from, to time.Time) []Sample {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	samples := []Sample{}
+	r, ok := s.series[taskType]
+	if !ok {
+		return samples
+	}
+	r.each(func(sample Sample) {
+		if !sample.Time.Before(from) && sample.Time.Before(to) {
+			samples = append(samples, sample)
+		}
+	})
+	return samples
+}
+
+// Latest 获取任务类型最新的负载数据
+func (s *Store) Latest(taskType string) (Sample, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.series[taskType]
+	if !ok || r.size == 0 {
+		return Sample{}, false
+	}
+	return r.at(r.size - 1), true
+}
+
+// TaskTypes 获取所有有数据的任务类型
+func (s *Store) TaskTypes() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	taskTypes := make([]string, 0, len(s.series))
+	for taskType := range s.series {
+		taskTypes = append(taskTypes, taskType)
+	}
+	sort.Strings(taskTypes)
+	return taskTypes
+}
+
+// RequestRate 统计 now 之前 window 时长内的平均请求量（每分钟），无数据时返回 false
+func (s *Store) RequestRate(taskType string, window time.Duration, now time.Time) (float64, bool) {
+	samples := s.Range(taskType, now.Add(-window), now.Add(time.Nanosecond))
+	if len(samples) == 0 || window <= 0 {
+		return 0, false
+	}
+	total := 0.0
+	for _, sample := range samples {
+		total += sample.Requests
+	}
+	return total / window.Minutes(), true
+}
+
+// ring 按时间升序保存数据的环形缓冲，按需扩容，达到 capacity 后覆盖最早的数据
+type ring struct {
+	samples  []Sample
+	capacity int
+	start    int
+	size     int
+}
+
+func newRing(capacity int) *ring {
+	return &ring{capacity: capacity}
+}
+
+// grow 容量翻倍（不超过 capacity），并将数据按时间顺序移到开头
+func (r *ring) grow() {
+	n := 2 * len(r.samples)
+	if n < minRingSize {
+		n = minRingSize
+	}
+	if n > r.capacity {
+		n = r.capacity
+	}
+	samples := make([]Sample, n)
+	for i := 0; i < r.size; i++ {
+		samples[i] = r.at(i)
+	}
+	r.samples, r.start = samples, 0
+}
+
+func (r *ring) at(i int) Sample {
+	return r.samples[(r.start+i)%len(r.samples)]
+}
+
+func (r *ring) set(i int, sample Sample) {
+	r.samples[(r.start+i)%len(r.samples)] = sample
+}
+
+func (r *ring) add(sample Sample) {
+	if r.capacity <= 0 {
+		return
+	}
+	if r.size == len(r.samples) && len(r.samples) < r.capacity {
+		r.grow()
+	}
+	if r.size == len(r.samples) {
+		// 已写满，丢弃最早的数据；新数据比最早的数据还早时直接丢弃
+		if sample.Time.Before(r.at(0).Time) {
+			return
+		}
+		r.start = (r.start + 1) % len(r.samples)
+		r.size--
+	}
+	// 插入排序，上报通常按时间顺序到达，只需比较末尾
+	i := r.size
+	for ; i > 0 && r.at(i-1).Time.After(sample.Time); i-- {
+		r.set(i, r.at(i-1))
+	}
+	r.set(i, sample)
+	r.size++
+}
+
+// expire 丢弃早于 deadline 的数据
+func (r *ring) expire(deadline time.Time) {
+	for r.size > 0 && r.at(0).Time.Before(deadline) {
+		r.start = (r.start + 1) % len(r.samples)
+		r.size--
+	}
+}
+
+func (r *ring) each(fn func(sample Sample)) {
+	for i := 0; i < r.size; i++ {
+		fn(r.at(i))
+	}
+}
@@ -0,0 +1,95 @@
+package loadstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestStore(t *testing.T) {
+	base := time.Date(2021, 10, 20, 12, 0, 0, 0, time.UTC)
+	store := NewStore(10*time.Minute, 5, 0)
+	now := base.Add(5 * time.Minute)
+	store.now = func() time.Time { return now }
+	for i := 0; i < 6; i++ {
+		store.Add("transcode", Sample{Time: base.Add(time.Duration(i) * time.Minute), Requests: float64(i)})
+	}
+	// 乱序到达的数据按时间插入
+	store.Add("transcode", Sample{Time: base.Add(150 * time.Second), Requests: 100, QueueLength: 3})
+
+	samples := store.Range("transcode", base, base.Add(time.Hour))
+	if len(samples) != 5 {
+		t.Fatalf("Range() got %d samples, want 5 (capacity)", len(samples))
+	}
+	for i := 1; i < len(samples); i++ {
+		if samples[i].Time.Before(samples[i-1].Time) {
+			t.Errorf("Range() samples not sorted: %+v", samples)
+		}
+	}
+	if samples[1].Requests != 100 {
+		t.Errorf("Range() second sample = %+v, want the out-of-order one", samples[1])
+	}
+	if latest, ok := store.Latest("transcode"); !ok || latest.Requests != 5 {
+		t.Errorf("Latest() got = %+v, %v", latest, ok)
+	}
+
+	// [now-2m, now] 内有 3、4、5 三个数据
+	if rate, ok := store.RequestRate("transcode", 2*time.Minute, base.Add(5*time.Minute)); !ok || rate != 6 {
+		t.Errorf("RequestRate() got = %v, %v, want 6", rate, ok)
+	}
+	if _, ok := store.RequestRate("other", time.Minute, base); ok {
+		t.Errorf("RequestRate() of unknown task type should have no data")
+	}
+
+	// 时间超前的数据不会导致已有数据被丢弃
+	store.Add("transcode", Sample{Time: base.Add(time.Hour), Requests: 1})
+	if samples = store.Range("transcode", base, base.Add(2*time.Hour)); len(samples) != 5 {
+		t.Errorf("Range() after future sample got = %+v, want 5 samples", samples)
+	}
+
+	// 按当前时间丢弃超出保留时长的数据
+	now = base.Add(20 * time.Minute)
+	store.Add("transcode", Sample{Time: now, Requests: 1})
+	if samples = store.Range("transcode", base, base.Add(2*time.Hour)); len(samples) != 2 {
+		t.Errorf("Range() after expire got = %+v, want 2 samples", samples)
+	}
+	if taskTypes := store.TaskTypes(); len(taskTypes) != 1 || taskTypes[0] != "transcode" {
+		t.Errorf("TaskTypes() got = %v", taskTypes)
+	}
+}
+
+func TestStore_limits(t *testing.T) {
+	base := time.Now()
+	store := NewStore(time.Hour, 100, 2)
+	for i := 0; i < 80; i++ {
+		if err := store.Add("transcode", Sample{Time: base.Add(time.Duration(i-80) * time.Second)}); err != nil {
+			t.Fatalf("Add() err: %+v", err)
+		}
+	}
+	// 按需扩容，不超过容量
+	if r := store.series["transcode"]; r.size != 80 || len(r.samples) != 100 {
+		t.Errorf("ring size = %d, len = %d, want 80, 100", r.size, len(r.samples))
+	}
+	if samples := store.Range("transcode", base.Add(-time.Hour), base); len(samples) != 80 ||
+		!samples[0].Time.Equal(base.Add(-80*time.Second)) {
+		t.Errorf("Range() after grow got %d samples", len(samples))
+	}
+
+	// 任务类型数达到上限后拒绝新任务类型，已有任务类型不受影响
+	if err := store.Add("render", Sample{Time: base}); err != nil {
+		t.Fatalf("Add() err: %+v", err)
+	}
+	if r := store.series["render"]; len(r.samples) != minRingSize {
+		t.Errorf("new ring len = %d, want %d", len(r.samples), minRingSize)
+	}
+	if err := store.CheckTaskTypes([]string{"transcode", "other"}); !errors.Is(err, ErrTooManyTaskTypes) {
+		t.Errorf("CheckTaskTypes() err = %v, want ErrTooManyTaskTypes", err)
+	}
+	if err := store.Add("other", Sample{Time: base}); !errors.Is(err, ErrTooManyTaskTypes) {
+		t.Errorf("Add() of new task type err = %v, want ErrTooManyTaskTypes", err)
+	}
+	if err := store.Add("transcode", Sample{Time: base}); err != nil {
+		t.Errorf("Add() of existing task type err: %+v", err)
+	}
+}
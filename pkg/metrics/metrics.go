@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "aass"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	// ScaleVerifyTotal 策略更新后，目标负载副本数收敛校验的结果计数
+	ScaleVerifyTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scale_verify_total",
+		Help:      "Number of post-apply scale verifications, partitioned by target and result.",
+	}, []string{"target", "result"})
+
+	// ScaleVerifyDurationSeconds 策略更新后，目标负载副本数收敛耗时
+	ScaleVerifyDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scale_verify_duration_seconds",
+		Help:      "Time taken by the scale target to converge after a strategy apply.",
+		Buckets:   prometheus.ExponentialBuckets(5, 2, 8),
+	}, []string{"target", "result"})
+
+	// LoadSamplesTotal 接收到的负载数据个数
+	LoadSamplesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "load_samples_total",
+		Help:      "Number of request-volume samples pushed by the conductor or workers.",
+	}, []string{"task_type"})
+
+	// PredictedMinReplicas 根据请求量预测建议的各策略时间段 minReplicas
+	PredictedMinReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "predicted_min_replicas",
+		Help:      "Recommended minReplicas of upcoming strategy windows based on forecast request volume.",
+	}, []string{"target", "window"})
+
+	// ScaleDownGatedTotal 因系统负载较高未降低 minReplicas 的次数
+	ScaleDownGatedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scale_down_gated_total",
+		Help:      "Number of window transitions that kept minReplicas because of high system load.",
+	}, []string{"target"})
+
+	// GRMRequestsTotal 请求 GRM 的次数（含重试），按操作和结果区分
+	GRMRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grm_requests_total",
+		Help:      "Number of resource manager API calls, partitioned by operation and result.",
+	}, []string{"operation", "result"})
+
+	// GRMNodes 通过 GRM 申请且尚未释放的节点数
+	GRMNodes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "grm_nodes",
+		Help:      "Number of nodes acquired from the resource manager and not yet released.",
+	}, []string{"target"})
+
+	// CapacityGapReplicas 最近一次提高 minReplicas 时，要求的副本数超出集群可容纳副本数的部分
+	CapacityGapReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "capacity_gap_replicas",
+		Help:      "Replicas required by the last minReplicas raise that the cluster cannot schedule.",
+	}, []string{"target"})
+
+	// QuotaCeilingReplicas 命名空间 ResourceQuota 允许的目标负载最大副本数
+	QuotaCeilingReplicas = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "quota_ceiling_replicas",
+		Help:      "Maximum replicas of the scale target allowed by the namespace resource quotas.",
+	}, []string{"target"})
+
+	// PendingPods 目标负载中因资源不足无法调度的 pod 数
+	PendingPods = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pending_pods",
+		Help:      "Number of scale target pods pending for lack of resources.",
+	}, []string{"target"})
+
+	// NodeScaleOutTotal Pending pod 触发的节点申请次数
+	NodeScaleOutTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "node_scale_out_total",
+		Help:      "Number of node requests triggered by pending pods, partitioned by target and result.",
+	}, []string{"target", "result"})
+
+	// NodeReleasesTotal 释放节点流程的结束次数，result 为 succeeded 或 rolled_back
+	NodeReleasesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "node_releases_total",
+		Help:      "Number of finished node release workflows, partitioned by target and result.",
+	}, []string{"target", "result"})
+
+	// BusyPods 目标负载中正在执行任务的 pod 数
+	BusyPods = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "busy_pods",
+		Help:      "Number of scale target pods running tasks, protected by a higher pod deletion cost.",
+	}, []string{"target"})
+
+	// InstanceSyncTotal 同步实例信息给 Vega 的次数，result 为 uploaded、skipped（内容未变化）或 error
+	InstanceSyncTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "instance_sync_total",
+		Help:      "Number of instance syncs to Vega, partitioned by result (uploaded, skipped, error).",
+	}, []string{"result"})
+)
+
+func init() {
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		ScaleVerifyTotal,
+		ScaleVerifyDurationSeconds,
+		LoadSamplesTotal,
+		PredictedMinReplicas,
+		ScaleDownGatedTotal,
+		GRMRequestsTotal,
+		GRMNodes,
+		CapacityGapReplicas,
+		QuotaCeilingReplicas,
+		PendingPods,
+		NodeScaleOutTotal,
+		NodeReleasesTotal,
+		BusyPods,
+		InstanceSyncTotal,
+	)
+}
+
+// Handler 返回 prometheus 格式的 metrics http handler
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
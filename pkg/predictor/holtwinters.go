@@ -0,0 +1,66 @@
+package predictor
+
+import (
+	"github.com/pkg/errors"
+)
+
+// HoltWinters 加法季节性 Holt-Winters 三次指数平滑
+type HoltWinters struct {
+	// 水平、趋势、季节分量的平滑系数，取值 (0, 1)
+	Alpha float64
+	Beta  float64
+	Gamma float64
+	// 季节周期包含的数据点个数
+	SeasonLength int
+}
+
+// Forecast 根据历史序列预测之后 horizon 个数据点，历史序列至少包含两个完整的季节周期
+func (h *HoltWinters) Forecast(series []float64, horizon int) ([]float64, error) {
+	m := h.SeasonLength
+	if m <= 0 {
+		return nil, errors.Errorf("invalid season length[%d]", m)
+	}
+	if len(series) < 2*m {
+		return nil, errors.Errorf("series length[%d] is less than two seasons[%d]", len(series), 2*m)
+	}
+
+	// 用前两个季节周期初始化水平、趋势和季节分量
+	first, second := mean(series[:m]), mean(series[m:2*m])
+	level := first
+	trend := (second - first) / float64(m)
+	seasonals := make([]float64, m)
+	for i := 0; i < m; i++ {
+		seasonals[i] = series[i] - first
+	}
+
+	for i, val := range series {
+		lastLevel := level
+		seasonal := seasonals[i%m]
+		level = h.Alpha*(val-seasonal) + (1-h.Alpha)*(level+trend)
+		trend = h.Beta*(level-lastLevel) + (1-h.Beta)*trend
+		seasonals[i%m] = h.Gamma*(val-level) + (1-h.Gamma)*seasonal
+	}
+
+	n := len(series)
+	result := make([]float64, horizon)
+	for i := 0; i < horizon; i++ {
+		val := level + float64(i+1)*trend + seasonals[(n+i)%m]
+		// 请求量不会为负数
+		if val < 0 {
+			val = 0
+		}
+		result[i] = val
+	}
+	return result, nil
+}
+
+func mean(vals []float64) float64 {
+	if len(vals) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, val := range vals {
+		sum += val
+	}
+	return sum / float64(len(vals))
+}
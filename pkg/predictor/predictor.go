@@ -0,0 +1,137 @@
+package predictor
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"nanto.io/application-auto-scaling-service/pkg/loadstore"
+)
+
+const (
+	SeasonDaily  = "daily"
+	SeasonWeekly = "weekly"
+
+	day  = 24 * time.Hour
+	week = 7 * day
+)
+
+// ErrInsufficientHistory 历史数据不足两个季节周期（天），无法预测
+var ErrInsufficientHistory = errors.New("insufficient load history")
+
+// Config 预测参数
+type Config struct {
+	// 历史数据重采样、预测结果的时间间隔
+	Step time.Duration
+	// 预测的时长
+	Horizon time.Duration
+	// Holt-Winters 平滑系数
+	Alpha float64
+	Beta  float64
+	Gamma float64
+}
+
+// Validate 校验预测参数：时间间隔不超过一天（季节周期至少一个间隔），平滑系数在 [0, 1] 内
+func (c *Config) Validate() error {
+	if c.Step <= 0 || c.Step > day {
+		return errors.Errorf("invalid predict step[%s], must be in (0, %s]", c.Step, day)
+	}
+	if c.Horizon <= 0 {
+		return errors.Errorf("invalid predict horizon[%s]", c.Horizon)
+	}
+	for name, val := range map[string]float64{"alpha": c.Alpha, "beta": c.Beta, "gamma": c.Gamma} {
+		if val < 0 || val > 1 {
+			return errors.Errorf("invalid predict %s[%v], must be in [0, 1]", name, val)
+		}
+	}
+	return nil
+}
+
+// Point 某一时间间隔内的请求量（每分钟）
+type Point struct {
+	Time     time.Time `json:"time"`
+	Requests float64   `json:"requests"`
+}
+
+// Forecast 某个任务类型的请求量预测结果
+type Forecast struct {
+	TaskType    string    `json:"taskType"`
+	GeneratedAt time.Time `json:"generatedAt"`
+	// 使用的季节周期，历史数据超过两周时按周，否则按天
+	Season string  `json:"season"`
+	Points []Point `json:"points"`
+}
+
+// Predict 根据按时间升序的历史负载数据预测 now 之后 conf.Horizon 时长的请求量
+func Predict(taskType string, samples []loadstore.Sample, now time.Time, conf *Config) (*Forecast, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, errors.Wrapf(ErrInsufficientHistory, "task type[%s] has no samples", taskType)
+	}
+
+	// 历史序列截止到当前所在时间间隔的起点，最后一个未完整的间隔不参与计算
+	end := now.Truncate(conf.Step)
+	span := end.Sub(samples[0].Time)
+	season := SeasonDaily
+	seasonLength := int(day / conf.Step)
+	if span >= 2*week {
+		season = SeasonWeekly
+		seasonLength = int(week / conf.Step)
+	} else if span < 2*day {
+		return nil, errors.Wrapf(ErrInsufficientHistory, "task type[%s] has only %s of samples", taskType, span)
+	}
+
+	buckets := int(span / conf.Step)
+	start := end.Add(-time.Duration(buckets) * conf.Step)
+	series := Resample(samples, start, conf.Step, buckets)
+
+	horizon := int((conf.Horizon + conf.Step - 1) / conf.Step)
+	hw := &HoltWinters{Alpha: conf.Alpha, Beta: conf.Beta, Gamma: conf.Gamma, SeasonLength: seasonLength}
+	values, err := hw.Forecast(series, horizon)
+	if err != nil {
+		return nil, errors.Wrapf(err, "forecast task type[%s] err", taskType)
+	}
+
+	result := &Forecast{TaskType: taskType, GeneratedAt: now, Season: season, Points: make([]Point, horizon)}
+	for i, val := range values {
+		result.Points[i] = Point{Time: end.Add(time.Duration(i) * conf.Step), Requests: val}
+	}
+	return result, nil
+}
+
+// Resample 将负载数据按时间间隔汇总为每分钟请求量序列，没有数据的间隔沿用前一个间隔的值
+func Resample(samples []loadstore.Sample, start time.Time, step time.Duration, n int) []float64 {
+	series := make([]float64, n)
+	filled := make([]bool, n)
+	for _, sample := range samples {
+		if sample.Time.Before(start) {
+			continue
+		}
+		i := int(sample.Time.Sub(start) / step)
+		if i >= n {
+			break
+		}
+		series[i] += sample.Requests
+		filled[i] = true
+	}
+
+	// 开头没有数据的间隔使用第一个有数据的间隔的值
+	last := 0.0
+	for i := range series {
+		if filled[i] {
+			last = series[i] / step.Minutes()
+			break
+		}
+	}
+	for i := range series {
+		if filled[i] {
+			series[i] /= step.Minutes()
+			last = series[i]
+			continue
+		}
+		series[i] = last
+	}
+	return series
+}
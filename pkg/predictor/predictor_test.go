@@ -0,0 +1,111 @@
+package predictor
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"nanto.io/application-auto-scaling-service/pkg/loadstore"
+)
+
+func TestHoltWinters_Forecast(t *testing.T) {
+	// 周期为 4 的稳定季节序列，预测结果应延续该季节模式
+	pattern := []float64{10, 20, 30, 20}
+	series := []float64{}
+	for i := 0; i < 6; i++ {
+		series = append(series, pattern...)
+	}
+	hw := &HoltWinters{Alpha: 0.5, Beta: 0.1, Gamma: 0.3, SeasonLength: 4}
+	got, err := hw.Forecast(series, 4)
+	if err != nil {
+		t.Fatalf("Forecast() err: %v", err)
+	}
+	for i, want := range pattern {
+		if math.Abs(got[i]-want) > 0.5 {
+			t.Errorf("Forecast()[%d] = %v, want %v", i, got[i], want)
+		}
+	}
+
+	if _, err = hw.Forecast(series[:7], 4); err == nil {
+		t.Errorf("Forecast() with less than two seasons expect err")
+	}
+}
+
+func TestResample(t *testing.T) {
+	start := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	samples := []loadstore.Sample{
+		{Time: start.Add(15 * time.Minute), Requests: 100},
+		{Time: start.Add(18 * time.Minute), Requests: 100},
+		{Time: start.Add(35 * time.Minute), Requests: 50},
+	}
+	got := Resample(samples, start, 10*time.Minute, 5)
+	want := []float64{20, 20, 20, 5, 5}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Resample() = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestPredict(t *testing.T) {
+	conf := &Config{Step: time.Hour, Horizon: 3 * time.Hour, Alpha: 0.5, Beta: 0.01, Gamma: 0.3}
+	start := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	// 每天 8 点到 20 点为高峰
+	samples := []loadstore.Sample{}
+	for h := 0; h < 3*24; h++ {
+		requests := 600.0
+		if hour := h % 24; hour >= 8 && hour < 20 {
+			requests = 6000
+		}
+		samples = append(samples, loadstore.Sample{Time: start.Add(time.Duration(h) * time.Hour), Requests: requests})
+	}
+
+	now := start.Add(3*24*time.Hour + 7*time.Hour + 30*time.Minute)
+	got, err := Predict("transcode", samples, now, conf)
+	if err != nil {
+		t.Fatalf("Predict() err: %v", err)
+	}
+	if got.Season != SeasonDaily || len(got.Points) != 3 {
+		t.Fatalf("Predict() got season %s, %d points", got.Season, len(got.Points))
+	}
+	// 7 点为低谷，8 点起为高峰
+	if !got.Points[0].Time.Equal(start.Add(3*24*time.Hour+7*time.Hour)) || got.Points[0].Requests > 30 ||
+		got.Points[1].Requests < 80 {
+		t.Errorf("Predict() got = %+v", got.Points)
+	}
+
+	if _, err = Predict("transcode", samples[:24], start.Add(24*time.Hour), conf); !errors.Is(err, ErrInsufficientHistory) {
+		t.Errorf("Predict() with one day of samples got err %v, want ErrInsufficientHistory", err)
+	}
+}
+
+func TestPredict_weekly(t *testing.T) {
+	conf := &Config{Step: time.Hour, Horizon: 3 * time.Hour, Alpha: 0.5, Beta: 0.01, Gamma: 0.3}
+	// 2021-10-01 为周五，工作日 8 点到 20 点为高峰，周末全天低谷
+	start := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	samples := []loadstore.Sample{}
+	for h := 0; h < 15*24; h++ {
+		ts := start.Add(time.Duration(h) * time.Hour)
+		requests := 600.0
+		if weekday := ts.Weekday(); weekday != time.Saturday && weekday != time.Sunday &&
+			ts.Hour() >= 8 && ts.Hour() < 20 {
+			requests = 6000
+		}
+		samples = append(samples, loadstore.Sample{Time: ts, Requests: requests})
+	}
+
+	// 周六 7:30，按天预测 8 点为高峰，按周预测仍为低谷
+	now := start.Add(15*24*time.Hour + 7*time.Hour + 30*time.Minute)
+	got, err := Predict("transcode", samples, now, conf)
+	if err != nil {
+		t.Fatalf("Predict() err: %v", err)
+	}
+	if got.Season != SeasonWeekly {
+		t.Fatalf("Predict() got season %s, want %s", got.Season, SeasonWeekly)
+	}
+	if got.Points[1].Requests > 30 {
+		t.Errorf("Predict() got = %+v, want low load on saturday", got.Points)
+	}
+}
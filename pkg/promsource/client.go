@@ -0,0 +1,319 @@
+package promsource
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/utils/logutil"
+)
+
+const (
+	queryPath      = "/api/v1/query"
+	queryRangePath = "/api/v1/query_range"
+)
+
+var logger = logutil.GetLogger()
+
+var (
+	// ErrEmptyResult 查询结果为空
+	ErrEmptyResult = errors.New("empty query result")
+
+	// Prometheus 单次范围查询最多返回的数据点个数为 11000，超出时分段查询；测试中可调小
+	maxPointsPerRangeQuery = 10000
+
+	client *Client
+)
+
+// Point 时间序列中的一个数据点
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Client Prometheus HTTP API 客户端，即时查询的结果按查询语句缓存
+type Client struct {
+	endpoint   string
+	httpClient *http.Client
+	cacheTTL   time.Duration
+
+	bearerToken string
+	username    string
+	password    string
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	value     float64
+	expiredAt time.Time
+}
+
+// apiResponse Prometheus HTTP API 响应
+type apiResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+type sample struct {
+	Metric map[string]string `json:"metric"`
+	Value  []interface{}     `json:"value"`
+	Values [][]interface{}   `json:"values"`
+}
+
+// Init 根据配置初始化全局 Prometheus 客户端，未配置 endpoint 时不初始化
+func Init(conf *config.PrometheusConf) error {
+	if conf.Endpoint == "" {
+		return nil
+	}
+	c, err := NewClient(conf)
+	if err != nil {
+		return err
+	}
+	client = c
+	return nil
+}
+
+// GetClient 获取全局 Prometheus 客户端，未配置时返回 nil
+func GetClient() *Client {
+	return client
+}
+
+// SetClient 直接指定全局 Prometheus 客户端，用于单测
+func SetClient(c *Client) {
+	client = c
+}
+
+// NewClient 创建 Prometheus 客户端
+func NewClient(conf *config.PrometheusConf) (*Client, error) {
+	if _, err := url.Parse(conf.Endpoint); err != nil || conf.Endpoint == "" {
+		return nil, errors.Errorf("invalid prometheus endpoint[%s]", conf.Endpoint)
+	}
+	c := &Client{
+		endpoint: strings.TrimSuffix(conf.Endpoint, "/"),
+		httpClient: &http.Client{
+			Timeout: time.Duration(conf.TimeoutSecond) * time.Second,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: conf.InsecureSkipVerify},
+			},
+		},
+		cacheTTL:    time.Duration(conf.CacheTTLSecond) * time.Second,
+		bearerToken: conf.BearerToken,
+		username:    conf.Username,
+		password:    conf.Password,
+		cache:       map[string]cacheEntry{},
+	}
+	if conf.BearerTokenFile != "" {
+		token, err := ioutil.ReadFile(conf.BearerTokenFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read bearer token file[%s] err", conf.BearerTokenFile)
+		}
+		c.bearerToken = strings.TrimSpace(string(token))
+	}
+	return c, nil
+}
+
+// Query 即时查询，结果为 vector 时返回各序列之和，结果在缓存有效期内直接返回
+func (c *Client) Query(ctx context.Context, query string) (float64, error) {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.cache[query]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expiredAt) {
+		return entry.value, nil
+	}
+
+	params := url.Values{"query": {query}, "time": {formatTime(now)}}
+	resp, err := c.do(ctx, queryPath, params)
+	if err != nil {
+		return 0, err
+	}
+	value, err := parseInstant(resp)
+	if err != nil {
+		return 0, errors.Wrapf(err, "query[%s]", query)
+	}
+
+	if c.cacheTTL > 0 {
+		c.mu.Lock()
+		c.cache[query] = cacheEntry{value: value, expiredAt: now.Add(c.cacheTTL)}
+		c.mu.Unlock()
+	}
+	return value, nil
+}
+
+// QueryRange 范围查询，结果包含多个序列时按时间点求和，按时间升序返回；数据点过多时分段查询
+func (c *Client) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) ([]Point,
+	error) {
+	if step <= 0 || end.Before(start) {
+		return nil, errors.Errorf("invalid range query [%s, %s] step[%s]", start, end, step)
+	}
+	points := []Point{}
+	chunk := step * time.Duration(maxPointsPerRangeQuery)
+	for from := start; !from.After(end); from = from.Add(chunk) {
+		to := from.Add(chunk - step)
+		if to.After(end) {
+			to = end
+		}
+		params := url.Values{
+			"query": {query},
+			"start": {formatTime(from)},
+			"end":   {formatTime(to)},
+			"step":  {strconv.FormatFloat(step.Seconds(), 'f', -1, 64)},
+		}
+		resp, err := c.do(ctx, queryRangePath, params)
+		if err != nil {
+			return nil, err
+		}
+		chunkPoints, err := parseMatrix(resp)
+		if err != nil {
+			return nil, errors.Wrapf(err, "query range[%s]", query)
+		}
+		points = append(points, chunkPoints...)
+	}
+	return points, nil
+}
+
+func (c *Client) do(ctx context.Context, path string, params url.Values) (*apiResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+path,
+		strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "new prometheus request err")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if c.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	} else if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	httpResp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "request prometheus[%s] err", path)
+	}
+	defer httpResp.Body.Close()
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "read prometheus response err")
+	}
+	resp := &apiResponse{}
+	if err = json.Unmarshal(body, resp); err != nil {
+		return nil, errors.Wrapf(err, "prometheus response status[%d], body: %s", httpResp.StatusCode, body)
+	}
+	if resp.Status != "success" {
+		return nil, errors.Errorf("prometheus response status[%d] %s: %s", httpResp.StatusCode, resp.ErrorType,
+			resp.Error)
+	}
+	return resp, nil
+}
+
+// parseInstant 解析即时查询结果，支持 scalar 和 vector
+func parseInstant(resp *apiResponse) (float64, error) {
+	switch resp.Data.ResultType {
+	case "scalar":
+		var pair []interface{}
+		if err := json.Unmarshal(resp.Data.Result, &pair); err != nil {
+			return 0, errors.Wrap(err, "unmarshal scalar result err")
+		}
+		point, err := parsePair(pair)
+		return point.Value, err
+	case "vector":
+		var samples []sample
+		if err := json.Unmarshal(resp.Data.Result, &samples); err != nil {
+			return 0, errors.Wrap(err, "unmarshal vector result err")
+		}
+		if len(samples) == 0 {
+			return 0, ErrEmptyResult
+		}
+		sum, found := 0.0, false
+		for _, s := range samples {
+			point, err := parsePair(s.Value)
+			if err != nil {
+				return 0, err
+			}
+			if !math.IsNaN(point.Value) {
+				sum, found = sum+point.Value, true
+			}
+		}
+		if !found {
+			return 0, ErrEmptyResult
+		}
+		return sum, nil
+	default:
+		return 0, errors.Errorf("unsupported result type[%s]", resp.Data.ResultType)
+	}
+}
+
+// parseMatrix 解析范围查询结果，多个序列按时间点求和
+func parseMatrix(resp *apiResponse) ([]Point, error) {
+	if resp.Data.ResultType != "matrix" {
+		return nil, errors.Errorf("unsupported result type[%s]", resp.Data.ResultType)
+	}
+	var samples []sample
+	if err := json.Unmarshal(resp.Data.Result, &samples); err != nil {
+		return nil, errors.Wrap(err, "unmarshal matrix result err")
+	}
+	sums := map[int64]float64{}
+	for _, s := range samples {
+		for _, pair := range s.Values {
+			point, err := parsePair(pair)
+			if err != nil {
+				return nil, err
+			}
+			if !math.IsNaN(point.Value) {
+				sums[point.Time.UnixNano()] += point.Value
+			}
+		}
+	}
+	points := make([]Point, 0, len(sums))
+	for ts, value := range sums {
+		points = append(points, Point{Time: time.Unix(0, ts), Value: value})
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Time.Before(points[j].Time)
+	})
+	return points, nil
+}
+
+// parsePair 解析 [<unix 时间戳>, "<值>"] 格式的数据点
+func parsePair(pair []interface{}) (Point, error) {
+	if len(pair) != 2 {
+		return Point{}, errors.Errorf("invalid sample value %v", pair)
+	}
+	ts, ok := pair[0].(float64)
+	if !ok {
+		return Point{}, errors.Errorf("invalid sample timestamp %v", pair[0])
+	}
+	str, ok := pair[1].(string)
+	if !ok {
+		return Point{}, errors.Errorf("invalid sample value %v", pair[1])
+	}
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return Point{}, errors.Wrapf(err, "invalid sample value %s", str)
+	}
+	sec := int64(ts)
+	return Point{Time: time.Unix(sec, int64((ts-float64(sec))*1e9)), Value: value}, nil
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', 3, 64)
+}
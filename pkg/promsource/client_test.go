@@ -0,0 +1,150 @@
+package promsource
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/loadstore"
+)
+
+// newStubServer 模拟 Prometheus HTTP API，按查询语句返回固定结果，并记录请求次数
+func newStubServer(t *testing.T, token string, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		if token != "" && r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"status":"error","errorType":"unauthorized","error":"bad token"}`)
+			return
+		}
+		query := r.FormValue("query")
+		switch {
+		case r.URL.Path == queryPath && query == "vector":
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"pod":"a"},"value":[1600000000,"1.5"]},
+				{"metric":{"pod":"b"},"value":[1600000000,"2.5"]}]}}`)
+		case r.URL.Path == queryPath && query == "scalar":
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"scalar","result":[1600000000,"7"]}}`)
+		case r.URL.Path == queryPath && query == "empty":
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+		case r.URL.Path == queryPath && query == "slow":
+			time.Sleep(2 * time.Second)
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"scalar","result":[1600000000,"1"]}}`)
+		case r.URL.Path == queryRangePath:
+			start, _ := strconv.ParseFloat(r.FormValue("start"), 64)
+			end, _ := strconv.ParseFloat(r.FormValue("end"), 64)
+			step, _ := strconv.ParseFloat(r.FormValue("step"), 64)
+			values := ""
+			for ts := start; ts <= end; ts += step {
+				if values != "" {
+					values += ","
+				}
+				values += fmt.Sprintf(`[%.3f,"10"]`, ts)
+			}
+			fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[
+				{"metric":{"pod":"a"},"values":[%s]},{"metric":{"pod":"b"},"values":[%s]}]}}`, values, values)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"parse error"}`)
+		}
+	}))
+}
+
+func TestClient_Query(t *testing.T) {
+	var calls int32
+	srv := newStubServer(t, "secret", &calls)
+	defer srv.Close()
+	c, err := NewClient(&config.PrometheusConf{Endpoint: srv.URL, TimeoutSecond: 1, CacheTTLSecond: 30,
+		BearerToken: "secret"})
+	if err != nil {
+		t.Fatalf("NewClient() err: %+v", err)
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		query   string
+		want    float64
+		wantErr bool
+	}{
+		{query: "vector", want: 4},
+		{query: "scalar", want: 7},
+		{query: "empty", wantErr: true},
+		{query: "invalid(", wantErr: true},
+		{query: "slow", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := c.Query(ctx, tt.query)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Query(%s) = %v, err: %v, want %v, wantErr %v", tt.query, got, err, tt.want, tt.wantErr)
+		}
+	}
+	if _, err = c.Query(ctx, "empty"); !errors.Is(err, ErrEmptyResult) {
+		t.Errorf("Query(empty) err = %v, want ErrEmptyResult", err)
+	}
+
+	// 缓存有效期内不重复请求
+	before := atomic.LoadInt32(&calls)
+	if got, _ := c.Query(ctx, "vector"); got != 4 || atomic.LoadInt32(&calls) != before {
+		t.Errorf("Query(vector) with cache = %v, calls %d -> %d", got, before, atomic.LoadInt32(&calls))
+	}
+
+	// 认证失败
+	unauth, _ := NewClient(&config.PrometheusConf{Endpoint: srv.URL, TimeoutSecond: 1})
+	if _, err = unauth.Query(ctx, "scalar"); err == nil {
+		t.Errorf("Query() without token should fail")
+	}
+}
+
+func TestClient_QueryRange(t *testing.T) {
+	var calls int32
+	srv := newStubServer(t, "", &calls)
+	defer srv.Close()
+	c, _ := NewClient(&config.PrometheusConf{Endpoint: srv.URL, TimeoutSecond: 1})
+
+	start := time.Unix(1600000000, 0)
+	points, err := c.QueryRange(context.Background(), "rate", start, start.Add(4*time.Minute), time.Minute)
+	if err != nil {
+		t.Fatalf("QueryRange() err: %+v", err)
+	}
+	if len(points) != 5 || !points[0].Time.Equal(start) || points[4].Value != 20 {
+		t.Errorf("QueryRange() = %+v, want 5 points with value 20", points)
+	}
+
+	// 数据点超出单次查询上限时分段查询，调小上限避免 -race 下生成大量数据点超时
+	defer func(max int) { maxPointsPerRangeQuery = max }(maxPointsPerRangeQuery)
+	maxPointsPerRangeQuery = 100
+	atomic.StoreInt32(&calls, 0)
+	end := start.Add(time.Duration(maxPointsPerRangeQuery+10) * time.Second)
+	points, err = c.QueryRange(context.Background(), "rate", start, end, time.Second)
+	if err != nil || len(points) != maxPointsPerRangeQuery+11 || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("QueryRange() chunked got %d points in %d calls, err: %v", len(points), calls, err)
+	}
+}
+
+func TestLoadImporter_importRange(t *testing.T) {
+	var calls int32
+	srv := newStubServer(t, "", &calls)
+	defer srv.Close()
+	c, _ := NewClient(&config.PrometheusConf{Endpoint: srv.URL, TimeoutSecond: 1})
+	store := loadstore.NewStore(time.Hour, 100, 0)
+	importer := NewLoadImporter(c, store, map[string]string{"transcode": "rate"}, 5*time.Minute, time.Hour)
+
+	end := time.Now().Truncate(time.Minute)
+	importer.importRange(context.Background(), "transcode", end.Add(-30*time.Minute), end)
+	samples := store.Range("transcode", end.Add(-time.Hour), end)
+	if len(samples) != 6 {
+		t.Fatalf("importRange() got %d samples, want 6", len(samples))
+	}
+	// 每分钟 20 个请求，5 分钟间隔内 100 个
+	if samples[0].Requests != 100 {
+		t.Errorf("importRange() requests = %v, want 100", samples[0].Requests)
+	}
+}
@@ -0,0 +1,96 @@
+package promsource
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"nanto.io/application-auto-scaling-service/pkg/loadstore"
+	"nanto.io/application-auto-scaling-service/pkg/metrics"
+)
+
+// LoadImporter 定期执行各任务类型的请求量查询（结果为每分钟请求数），写入负载数据，供分流、预测使用；
+// 启动时按回溯时长补齐历史数据
+type LoadImporter struct {
+	client *Client
+	store  *loadstore.Store
+	// 任务类型 -> PromQL
+	queries  map[string]string
+	interval time.Duration
+	backfill time.Duration
+}
+
+func NewLoadImporter(client *Client, store *loadstore.Store, queries map[string]string, interval,
+	backfill time.Duration) *LoadImporter {
+	return &LoadImporter{
+		client:   client,
+		store:    store,
+		queries:  queries,
+		interval: interval,
+		backfill: backfill,
+	}
+}
+
+// Run 补齐历史数据后按间隔导入，直到 ctx 结束
+func (i *LoadImporter) Run(ctx context.Context) {
+	if len(i.queries) == 0 || i.interval <= 0 {
+		return
+	}
+	taskTypes := make([]string, 0, len(i.queries))
+	for taskType := range i.queries {
+		taskTypes = append(taskTypes, taskType)
+	}
+	sort.Strings(taskTypes)
+
+	now := time.Now()
+	if i.backfill > 0 {
+		for _, taskType := range taskTypes {
+			i.importRange(ctx, taskType, now.Add(-i.backfill), now)
+		}
+	}
+
+	ticker := time.NewTicker(i.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now = <-ticker.C:
+			for _, taskType := range taskTypes {
+				i.importInstant(ctx, taskType, now)
+			}
+		case <-ctx.Done():
+			logger.Info("=== Prometheus load importer exit ===")
+			return
+		}
+	}
+}
+
+// importRange 导入 [start, end) 时间范围内的历史请求量
+func (i *LoadImporter) importRange(ctx context.Context, taskType string, start, end time.Time) {
+	points, err := i.client.QueryRange(ctx, i.queries[taskType], start, end.Add(-i.interval), i.interval)
+	if err != nil {
+		logger.Errorf("Backfill load of task type[%s] from prometheus err: %+v", taskType, err)
+		return
+	}
+	for _, point := range points {
+		i.add(taskType, point)
+	}
+	logger.Infof("Backfill %d load samples of task type[%s] from prometheus", len(points), taskType)
+}
+
+func (i *LoadImporter) importInstant(ctx context.Context, taskType string, now time.Time) {
+	value, err := i.client.Query(ctx, i.queries[taskType])
+	if err != nil {
+		logger.Errorf("Query load of task type[%s] from prometheus err: %+v", taskType, err)
+		return
+	}
+	i.add(taskType, Point{Time: now, Value: value})
+}
+
+// add 查询结果为每分钟请求数，换算为一个导入间隔内的请求数
+func (i *LoadImporter) add(taskType string, point Point) {
+	if err := i.store.Add(taskType, loadstore.Sample{Time: point.Time, Requests: point.Value * i.interval.Minutes()}); err != nil {
+		logger.Errorf("Add load sample of task type[%s] err: %+v", taskType, err)
+		return
+	}
+	metrics.LoadSamplesTotal.WithLabelValues(taskType).Inc()
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"nanto.io/application-auto-scaling-service/pkg/controller"
+)
+
+const (
+	adminPathPrefix   = "/api/v1/"
+	targetsPathPrefix = adminPathPrefix + "targets/"
+)
+
+// StrategyManager 管理接口依赖的策略控制器能力，由 controller.StrategyController 实现
+type StrategyManager interface {
+	Strategies() *controller.StrategiesInfo
+	NextTransitions() []controller.Transition
+	Targets() ([]controller.TargetInfo, error)
+	Target(name string) (*controller.TargetInfo, error)
+	ApplyWindow(target, window string) error
+	SetPaused(target string, paused bool) error
+	Reload() error
+	History(target string) ([]controller.ApplyRecord, error)
+	Rollback(target string, revision int) (*controller.ApplyRecord, error)
+	NodeReleases(target string) ([]controller.NodeRelease, error)
+	CancelNodeRelease(target string) (*controller.NodeRelease, error)
+}
+
+// applyRequest 立即执行指定策略时间段的请求
+type applyRequest struct {
+	Window string `json:"window"`
+}
+
+// rollbackRequest 回滚请求，Revision 为 0 时恢复为最近一次更新前的 spec
+type rollbackRequest struct {
+	Revision int `json:"revision"`
+}
+
+type adminHandler struct {
+	manager StrategyManager
+}
+
+// HandleAdmin 注册管理接口：
+//
+//	GET  /api/v1/strategies                   当前加载的策略
+//	GET  /api/v1/transitions                  即将发生的策略时间段切换
+//	POST /api/v1/reload                       重新加载策略
+//	GET  /api/v1/targets                      受管理的目标HPA
+//	GET  /api/v1/targets/{name}               目标HPA详情
+//	GET  /api/v1/targets/{name}/history       策略更新历史
+//	POST /api/v1/targets/{name}/apply         立即执行指定时间段策略，body: {"window": "0:00-09:30"}
+//	POST /api/v1/targets/{name}/pause         暂停定时策略
+//	POST /api/v1/targets/{name}/resume        恢复定时策略
+//	POST /api/v1/targets/{name}/rollback      回滚并暂停定时策略，body: {"revision": 3}
+//	GET  /api/v1/targets/{name}/node-releases 最近的释放节点流程
+//	POST /api/v1/targets/{name}/cancel-node-release 取消进行中的释放节点流程并解除节点封锁
+//
+// POST 接口需要认证，见 requireAuth
+func (s *Server) HandleAdmin(manager StrategyManager) {
+	h := &adminHandler{manager: manager}
+	s.mux.HandleFunc(adminPathPrefix+"strategies", h.getStrategies)
+	s.mux.HandleFunc(adminPathPrefix+"transitions", h.getTransitions)
+	s.mux.HandleFunc(adminPathPrefix+"reload", s.requireAuth(h.reload))
+	s.mux.HandleFunc(adminPathPrefix+"targets", h.listTargets)
+	s.mux.HandleFunc(targetsPathPrefix, s.requireAuth(h.handleTarget))
+}
+
+func (h *adminHandler) getStrategies(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, h.manager.Strategies())
+}
+
+func (h *adminHandler) getTransitions(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, h.manager.NextTransitions())
+}
+
+func (h *adminHandler) reload(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodPost) {
+		return
+	}
+	if err := h.manager.Reload(); err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.manager.Strategies())
+}
+
+func (h *adminHandler) listTargets(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodGet) {
+		return
+	}
+	targets, err := h.manager.Targets()
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, targets)
+}
+
+// handleTarget 处理 /api/v1/targets/{name}[/{action}]
+func (h *adminHandler) handleTarget(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, targetsPathPrefix), "/")
+	name, action := parts[0], ""
+	if len(parts) > 1 {
+		action = parts[1]
+	}
+	if name == "" || len(parts) > 2 {
+		writeError(w, http.StatusNotFound, errors.Errorf("path[%s] not found", r.URL.Path))
+		return
+	}
+
+	switch action {
+	case "":
+		h.getTarget(w, r, name)
+	case "history":
+		h.getHistory(w, r, name)
+	case "apply":
+		h.applyWindow(w, r, name)
+	case "pause":
+		h.setPaused(w, r, name, true)
+	case "resume":
+		h.setPaused(w, r, name, false)
+	case "rollback":
+		h.rollback(w, r, name)
+	case "node-releases":
+		h.getNodeReleases(w, r, name)
+	case "cancel-node-release":
+		h.cancelNodeRelease(w, r, name)
+	default:
+		writeError(w, http.StatusNotFound, errors.Errorf("path[%s] not found", r.URL.Path))
+	}
+}
+
+func (h *adminHandler) getTarget(w http.ResponseWriter, r *http.Request, name string) {
+	if !checkMethod(w, r, http.MethodGet) {
+		return
+	}
+	h.writeTarget(w, name)
+}
+
+// writeTarget 返回目标HPA详情
+func (h *adminHandler) writeTarget(w http.ResponseWriter, name string) {
+	target, err := h.manager.Target(name)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, target)
+}
+
+func (h *adminHandler) getHistory(w http.ResponseWriter, r *http.Request, name string) {
+	if !checkMethod(w, r, http.MethodGet) {
+		return
+	}
+	records, err := h.manager.History(name)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+func (h *adminHandler) applyWindow(w http.ResponseWriter, r *http.Request, name string) {
+	if !checkMethod(w, r, http.MethodPost) {
+		return
+	}
+	req := &applyRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil || req.Window == "" {
+		writeError(w, http.StatusBadRequest, errors.New("request body must be like {\"window\": \"0:00-09:30\"}"))
+		return
+	}
+	if err := h.manager.ApplyWindow(name, req.Window); err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	h.writeTarget(w, name)
+}
+
+func (h *adminHandler) setPaused(w http.ResponseWriter, r *http.Request, name string, paused bool) {
+	if !checkMethod(w, r, http.MethodPost) {
+		return
+	}
+	if err := h.manager.SetPaused(name, paused); err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	h.writeTarget(w, name)
+}
+
+func (h *adminHandler) rollback(w http.ResponseWriter, r *http.Request, name string) {
+	if !checkMethod(w, r, http.MethodPost) {
+		return
+	}
+	req := &rollbackRequest{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil || req.Revision < 0 {
+			writeError(w, http.StatusBadRequest, errors.New("request body must be like {\"revision\": 3}"))
+			return
+		}
+	}
+	record, err := h.manager.Rollback(name, req.Revision)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, record)
+}
+
+func (h *adminHandler) getNodeReleases(w http.ResponseWriter, r *http.Request, name string) {
+	if !checkMethod(w, r, http.MethodGet) {
+		return
+	}
+	releases, err := h.manager.NodeReleases(name)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, releases)
+}
+
+func (h *adminHandler) cancelNodeRelease(w http.ResponseWriter, r *http.Request, name string) {
+	if !checkMethod(w, r, http.MethodPost) {
+		return
+	}
+	release, err := h.manager.CancelNodeRelease(name)
+	if err != nil {
+		writeManagerError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, release)
+}
+
+// checkMethod 校验请求方法，不匹配时返回 405
+func checkMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+	return false
+}
+
+// writeManagerError 根据错误类型返回对应的状态码
+func writeManagerError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	if errors.Is(err, controller.ErrTargetNotFound) || errors.Is(err, controller.ErrWindowNotFound) ||
+		errors.Is(err, controller.ErrRevisionNotFound) {
+		code = http.StatusNotFound
+	} else if errors.Is(err, controller.ErrNoNodeRelease) {
+		code = http.StatusConflict
+	}
+	writeError(w, code, err)
+}
@@ -0,0 +1,222 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/controller"
+)
+
+// fakeManager 记录调用参数的 StrategyManager
+type fakeManager struct {
+	target        string
+	windows       []string
+	appliedWindow string
+	paused        bool
+	reloaded      bool
+	rollbackTo    int
+	releasing     bool
+}
+
+func (m *fakeManager) Strategies() *controller.StrategiesInfo {
+	info := &controller.StrategiesInfo{TargetHPA: m.target}
+	for _, w := range m.windows {
+		info.Strategies = append(info.Strategies, controller.Strategy{ValidTime: w})
+	}
+	return info
+}
+
+func (m *fakeManager) NextTransitions() []controller.Transition {
+	return []controller.Transition{{Target: m.target, Window: m.windows[0]}}
+}
+
+func (m *fakeManager) Targets() ([]controller.TargetInfo, error) {
+	target, _ := m.Target(m.target)
+	return []controller.TargetInfo{*target}, nil
+}
+
+func (m *fakeManager) Target(name string) (*controller.TargetInfo, error) {
+	if name != m.target {
+		return nil, errors.Wrapf(controller.ErrTargetNotFound, "customHPA[%s]", name)
+	}
+	return &controller.TargetInfo{Name: name, Paused: m.paused, AppliedWindow: m.appliedWindow}, nil
+}
+
+func (m *fakeManager) ApplyWindow(target, window string) error {
+	if _, err := m.Target(target); err != nil {
+		return err
+	}
+	for _, w := range m.windows {
+		if w == window {
+			m.appliedWindow = window
+			return nil
+		}
+	}
+	return controller.ErrWindowNotFound
+}
+
+func (m *fakeManager) SetPaused(target string, paused bool) error {
+	if _, err := m.Target(target); err != nil {
+		return err
+	}
+	m.paused = paused
+	return nil
+}
+
+func (m *fakeManager) Reload() error {
+	m.reloaded = true
+	return nil
+}
+
+func (m *fakeManager) History(target string) ([]controller.ApplyRecord, error) {
+	if _, err := m.Target(target); err != nil {
+		return nil, err
+	}
+	return []controller.ApplyRecord{{Revision: 1, Window: m.windows[0]}}, nil
+}
+
+func (m *fakeManager) Rollback(target string, revision int) (*controller.ApplyRecord, error) {
+	if _, err := m.Target(target); err != nil {
+		return nil, err
+	}
+	m.rollbackTo = revision
+	m.paused = true
+	return &controller.ApplyRecord{Revision: 2, RollbackFrom: "revision 1"}, nil
+}
+
+func (m *fakeManager) NodeReleases(target string) ([]controller.NodeRelease, error) {
+	if _, err := m.Target(target); err != nil {
+		return nil, err
+	}
+	return []controller.NodeRelease{{ID: "chpa-1", Phase: controller.NodeReleaseDraining}}, nil
+}
+
+func (m *fakeManager) CancelNodeRelease(target string) (*controller.NodeRelease, error) {
+	if _, err := m.Target(target); err != nil {
+		return nil, err
+	}
+	if !m.releasing {
+		return nil, controller.ErrNoNodeRelease
+	}
+	m.releasing = false
+	return &controller.NodeRelease{ID: "chpa-1", Phase: controller.NodeReleaseRolledBack}, nil
+}
+
+func TestAdminHandlers(t *testing.T) {
+	manager := &fakeManager{target: "chpa", windows: []string{"0:00-09:30", "09:30-24:00"}, releasing: true}
+	s := NewServer(&config.ServerConf{})
+	s.HandleAdmin(manager)
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"healthz", http.MethodGet, "/healthz", "", http.StatusOK, "ok"},
+		{"strategies", http.MethodGet, "/api/v1/strategies", "", http.StatusOK, `"TargetHPA":"chpa"`},
+		{"transitions", http.MethodGet, "/api/v1/transitions", "", http.StatusOK, `"window":"0:00-09:30"`},
+		{"reload", http.MethodPost, "/api/v1/reload", "", http.StatusOK, `"TargetHPA":"chpa"`},
+		{"reload wrong method", http.MethodGet, "/api/v1/reload", "", http.StatusMethodNotAllowed, "not allowed"},
+		{"targets", http.MethodGet, "/api/v1/targets", "", http.StatusOK, `"name":"chpa"`},
+		{"target", http.MethodGet, "/api/v1/targets/chpa", "", http.StatusOK, `"name":"chpa"`},
+		{"target not found", http.MethodGet, "/api/v1/targets/other", "", http.StatusNotFound, "target not found"},
+		{"unknown action", http.MethodGet, "/api/v1/targets/chpa/unknown", "", http.StatusNotFound, "not found"},
+		{"history", http.MethodGet, "/api/v1/targets/chpa/history", "", http.StatusOK, `"revision":1`},
+		{"apply", http.MethodPost, "/api/v1/targets/chpa/apply", `{"window":"09:30-24:00"}`, http.StatusOK,
+			`"appliedWindow":"09:30-24:00"`},
+		{"apply bad request", http.MethodPost, "/api/v1/targets/chpa/apply", `{}`, http.StatusBadRequest, "window"},
+		{"apply unknown window", http.MethodPost, "/api/v1/targets/chpa/apply", `{"window":"1:00-2:00"}`,
+			http.StatusNotFound, "window not found"},
+		{"pause", http.MethodPost, "/api/v1/targets/chpa/pause", "", http.StatusOK, `"paused":true`},
+		{"resume", http.MethodPost, "/api/v1/targets/chpa/resume", "", http.StatusOK, `"paused":false`},
+		{"rollback", http.MethodPost, "/api/v1/targets/chpa/rollback", `{"revision":1}`, http.StatusOK,
+			`"rollbackFrom":"revision 1"`},
+		{"node releases", http.MethodGet, "/api/v1/targets/chpa/node-releases", "", http.StatusOK,
+			`"phase":"Draining"`},
+		{"cancel node release", http.MethodPost, "/api/v1/targets/chpa/cancel-node-release", "", http.StatusOK,
+			`"phase":"RolledBack"`},
+		{"cancel without node release", http.MethodPost, "/api/v1/targets/chpa/cancel-node-release", "",
+			http.StatusConflict, "no node release in progress"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, ts.URL+tt.path, strings.NewReader(tt.body))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request err: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantCode || !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("%s %s got = %d %s, want %d %s", tt.method, tt.path, resp.StatusCode, body,
+					tt.wantCode, tt.wantBody)
+			}
+		})
+	}
+
+	if !manager.reloaded || manager.rollbackTo != 1 {
+		t.Errorf("manager not called as expected: %+v", manager)
+	}
+}
+
+func TestAdminAuth(t *testing.T) {
+	manager := &fakeManager{target: "chpa", windows: []string{"0:00-09:30"}}
+	s := NewServer(&config.ServerConf{AdminToken: "secret"})
+	s.HandleAdmin(manager)
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		wantCode int
+	}{
+		{"read without token", http.MethodGet, "/api/v1/targets/chpa", "", http.StatusOK},
+		{"pause without token", http.MethodPost, "/api/v1/targets/chpa/pause", "", http.StatusUnauthorized},
+		{"reload with wrong token", http.MethodPost, "/api/v1/reload", "other", http.StatusUnauthorized},
+		{"pause with token", http.MethodPost, "/api/v1/targets/chpa/pause", "secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, ts.URL+tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request err: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("%s %s got = %d, want %d", tt.method, tt.path, resp.StatusCode, tt.wantCode)
+			}
+		})
+	}
+	if !manager.paused || manager.reloaded {
+		t.Errorf("manager not called as expected: %+v", manager)
+	}
+
+	// 未配置 token 时只接受本机请求
+	s = NewServer(&config.ServerConf{})
+	s.HandleAdmin(manager)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/reload", nil)
+	req.RemoteAddr = "10.0.0.8:34567"
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("remote request without token configured got = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
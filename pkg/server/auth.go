@@ -0,0 +1,57 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// adminTokenEnv 未配置管理 token 时读取的环境变量
+const adminTokenEnv = "admin_token"
+
+// adminToken 管理 token，配置为空时读取环境变量
+func adminToken(token string) string {
+	if token == "" {
+		token = os.Getenv(adminTokenEnv)
+	}
+	return token
+}
+
+// requireAuth 校验写操作（GET/HEAD 以外的请求）：配置了管理 token 时要求请求头 "Authorization: Bearer <token>"，
+// 未配置时只接受本机（如 kubectl port-forward/exec）发起的请求
+func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next(w, r)
+			return
+		}
+		if s.adminToken == "" {
+			if !isLoopback(r.RemoteAddr) {
+				writeError(w, http.StatusForbidden,
+					errors.New("admin token is not configured, only local requests are allowed"))
+				return
+			}
+			next(w, r)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing bearer token"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
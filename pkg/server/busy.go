@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"nanto.io/application-auto-scaling-service/pkg/busystate"
+)
+
+const busyStatePath = "/api/v1/pods/busy"
+
+// BusyStateReporter 接收 worker 上报的任务状态，由 busystate.Tracker 实现
+type BusyStateReporter interface {
+	Report(pod string, state busystate.State)
+}
+
+// busyPushRequest worker 上报的任务状态
+type busyPushRequest struct {
+	Pods []busyPushState `json:"pods"`
+}
+
+type busyPushState struct {
+	Pod         string `json:"pod"`
+	Busy        bool   `json:"busy"`
+	ActiveTasks int    `json:"activeTasks"`
+	// 正在执行的任务中最早开始的时间
+	TaskStartedAt *time.Time `json:"taskStartedAt,omitempty"`
+}
+
+// HandleBusyState 注册任务状态上报接口：
+//
+//	POST /api/v1/pods/busy    上报 pod 的任务状态，
+//	                          body: {"pods": [{"pod": "worker-0", "busy": true, "activeTasks": 1, "taskStartedAt": "2021-10-01T08:00:00Z"}]}
+//
+// 与管理接口相同需要认证，见 requireAuth
+func (s *Server) HandleBusyState(reporter BusyStateReporter) {
+	s.mux.HandleFunc(busyStatePath, s.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if !checkMethod(w, r, http.MethodPost) {
+			return
+		}
+		req := &busyPushRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			writeError(w, http.StatusBadRequest, errors.Wrap(err, "invalid request body"))
+			return
+		}
+		for _, state := range req.Pods {
+			if state.Pod == "" || state.ActiveTasks < 0 {
+				writeError(w, http.StatusBadRequest, errors.Errorf("invalid pod state: %+v", state))
+				return
+			}
+		}
+		now := time.Now()
+		for _, state := range req.Pods {
+			reporter.Report(state.Pod, busystate.State{Busy: state.Busy, ActiveTasks: state.ActiveTasks,
+				TaskStartedAt: state.TaskStartedAt, ReportedAt: now})
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
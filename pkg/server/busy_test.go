@@ -0,0 +1,61 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"nanto.io/application-auto-scaling-service/pkg/busystate"
+	"nanto.io/application-auto-scaling-service/pkg/config"
+)
+
+type fakeBusyReporter map[string]busystate.State
+
+func (r fakeBusyReporter) Report(pod string, state busystate.State) {
+	r[pod] = state
+}
+
+func TestHandleBusyState(t *testing.T) {
+	reporter := fakeBusyReporter{}
+	s := NewServer(&config.ServerConf{AdminToken: "secret"})
+	s.HandleBusyState(reporter)
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		token    string
+		body     string
+		wantCode int
+	}{
+		{"push", http.MethodPost, "secret", `{"pods": [{"pod": "worker-0", "busy": true, "activeTasks": 1, ` +
+			`"taskStartedAt": "2021-10-01T08:00:00Z"}, {"pod": "worker-1"}]}`, http.StatusNoContent},
+		{"missing token", http.MethodPost, "", `{"pods": [{"pod": "worker-2", "busy": true}]}`,
+			http.StatusUnauthorized},
+		{"missing pod", http.MethodPost, "secret", `{"pods": [{"busy": true}]}`, http.StatusBadRequest},
+		{"invalid body", http.MethodPost, "secret", `{`, http.StatusBadRequest},
+		{"wrong method", http.MethodGet, "", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, ts.URL+busyStatePath, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request err: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("%s %s got = %d, want %d", tt.method, busyStatePath, resp.StatusCode, tt.wantCode)
+			}
+		})
+	}
+	if len(reporter) != 2 || !reporter["worker-0"].Busy || reporter["worker-0"].TaskStartedAt == nil ||
+		reporter["worker-1"].Busy {
+		t.Errorf("reported states = %+v", reporter)
+	}
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/metrics/pkg/apis/external_metrics/v1beta1"
+
+	"nanto.io/application-auto-scaling-service/pkg/extmetrics"
+)
+
+const (
+	apisPath                 = "/apis"
+	externalMetricsGroupPath = apisPath + "/" + externalMetricsGroup
+	externalMetricsPath      = externalMetricsGroupPath + "/" + externalMetricsVersion
+
+	externalMetricsGroup   = "external.metrics.k8s.io"
+	externalMetricsVersion = "v1beta1"
+)
+
+// ExternalMetricsProvider 提供 external.metrics.k8s.io 的指标
+type ExternalMetricsProvider interface {
+	ListMetrics() []string
+	GetMetric(ctx context.Context, name string, selector labels.Selector) ([]v1beta1.ExternalMetricValue, error)
+}
+
+type externalMetricsHandler struct {
+	provider ExternalMetricsProvider
+}
+
+// HandleExternalMetrics 注册 external.metrics.k8s.io 聚合 API，供 HPA 按业务指标扩缩容：
+//
+//	GET /apis                                                             API group 发现
+//	GET /apis/external.metrics.k8s.io                                     API version 发现
+//	GET /apis/external.metrics.k8s.io/v1beta1                             指标列表
+//	GET /apis/external.metrics.k8s.io/v1beta1/namespaces/{ns}/{metric}    查询指标，支持 labelSelector 参数
+func (s *Server) HandleExternalMetrics(provider ExternalMetricsProvider) {
+	h := &externalMetricsHandler{provider: provider}
+	s.mux.HandleFunc(apisPath, h.groupList)
+	s.mux.HandleFunc(externalMetricsGroupPath, h.group)
+	s.mux.HandleFunc(externalMetricsPath, h.resourceList)
+	s.mux.HandleFunc(externalMetricsPath+"/", h.metric)
+}
+
+func (h *externalMetricsHandler) groupList(w http.ResponseWriter, r *http.Request) {
+	if !checkMetricsGet(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, &metav1.APIGroupList{
+		TypeMeta: metav1.TypeMeta{Kind: "APIGroupList", APIVersion: "v1"},
+		Groups:   []metav1.APIGroup{externalMetricsAPIGroup()},
+	})
+}
+
+func (h *externalMetricsHandler) group(w http.ResponseWriter, r *http.Request) {
+	if !checkMetricsGet(w, r) {
+		return
+	}
+	group := externalMetricsAPIGroup()
+	group.TypeMeta = metav1.TypeMeta{Kind: "APIGroup", APIVersion: "v1"}
+	writeJSON(w, http.StatusOK, &group)
+}
+
+// resourceList 每个指标作为一个 namespaced 资源
+func (h *externalMetricsHandler) resourceList(w http.ResponseWriter, r *http.Request) {
+	if !checkMetricsGet(w, r) {
+		return
+	}
+	list := &metav1.APIResourceList{
+		TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+		GroupVersion: externalMetricsGroup + "/" + externalMetricsVersion,
+		APIResources: []metav1.APIResource{},
+	}
+	for _, name := range h.provider.ListMetrics() {
+		list.APIResources = append(list.APIResources, metav1.APIResource{
+			Name:       name,
+			Namespaced: true,
+			Kind:       "ExternalMetricValueList",
+			Verbs:      metav1.Verbs{"get"},
+		})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// metric 处理 /apis/external.metrics.k8s.io/v1beta1/namespaces/{ns}/{metric}
+func (h *externalMetricsHandler) metric(w http.ResponseWriter, r *http.Request) {
+	if !checkMetricsGet(w, r) {
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, externalMetricsPath+"/"), "/")
+	if len(parts) != 3 || parts[0] != "namespaces" || parts[1] == "" || parts[2] == "" {
+		writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound,
+			errors.Errorf("the server could not find the requested resource %s", r.URL.Path))
+		return
+	}
+	name := parts[2]
+	selector, err := labels.Parse(r.URL.Query().Get("labelSelector"))
+	if err != nil {
+		writeStatus(w, http.StatusBadRequest, metav1.StatusReasonBadRequest,
+			errors.Wrap(err, "invalid labelSelector"))
+		return
+	}
+
+	values, err := h.provider.GetMetric(r.Context(), name, selector)
+	if errors.Is(err, extmetrics.ErrMetricNotFound) {
+		writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, err)
+		return
+	}
+	if err != nil {
+		logger.Errorf("Get external metric[%s] err: %+v", name, err)
+		writeStatus(w, http.StatusServiceUnavailable, metav1.StatusReasonServiceUnavailable, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &v1beta1.ExternalMetricValueList{
+		TypeMeta: metav1.TypeMeta{Kind: "ExternalMetricValueList",
+			APIVersion: externalMetricsGroup + "/" + externalMetricsVersion},
+		Items: values,
+	})
+}
+
+func externalMetricsAPIGroup() metav1.APIGroup {
+	version := metav1.GroupVersionForDiscovery{
+		GroupVersion: externalMetricsGroup + "/" + externalMetricsVersion,
+		Version:      externalMetricsVersion,
+	}
+	return metav1.APIGroup{
+		Name:             externalMetricsGroup,
+		Versions:         []metav1.GroupVersionForDiscovery{version},
+		PreferredVersion: version,
+	}
+}
+
+// checkMetricsGet 聚合 API 仅支持 GET，错误按 k8s Status 格式返回
+func checkMetricsGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet {
+		return true
+	}
+	w.Header().Set("Allow", "GET")
+	writeStatus(w, http.StatusMethodNotAllowed, metav1.StatusReasonMethodNotAllowed,
+		errors.Errorf("method %s not allowed", r.Method))
+	return false
+}
+
+// writeStatus 按 k8s API 的格式返回错误
+func writeStatus(w http.ResponseWriter, code int, reason metav1.StatusReason, err error) {
+	writeJSON(w, code, &metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   metav1.StatusFailure,
+		Message:  err.Error(),
+		Reason:   reason,
+		Code:     int32(code),
+	})
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"k8s.io/metrics/pkg/apis/external_metrics/v1beta1"
+	"k8s.io/metrics/pkg/client/external_metrics"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/extmetrics"
+)
+
+type fakeExternalMetricsProvider struct {
+	values map[string][]v1beta1.ExternalMetricValue
+	err    error
+}
+
+func (f *fakeExternalMetricsProvider) ListMetrics() []string {
+	return []string{"task_queue_length", "task_request_rate"}
+}
+
+func (f *fakeExternalMetricsProvider) GetMetric(_ context.Context, name string, selector labels.Selector) (
+	[]v1beta1.ExternalMetricValue, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	values, ok := f.values[name]
+	if !ok {
+		return nil, extmetrics.ErrMetricNotFound
+	}
+	matched := []v1beta1.ExternalMetricValue{}
+	for _, value := range values {
+		if selector.Matches(labels.Set(value.MetricLabels)) {
+			matched = append(matched, value)
+		}
+	}
+	return matched, nil
+}
+
+// TestExternalMetricsHandler 使用 client-go 的 discovery client 和 external metrics client 访问自签名证书的 https server
+func TestExternalMetricsHandler(t *testing.T) {
+	provider := &fakeExternalMetricsProvider{values: map[string][]v1beta1.ExternalMetricValue{
+		"task_queue_length": {
+			{MetricName: "task_queue_length", MetricLabels: map[string]string{"task_type": "transcode"},
+				Value: resource.MustParse("12")},
+			{MetricName: "task_queue_length", MetricLabels: map[string]string{"task_type": "snapshot"},
+				Value: resource.MustParse("3")},
+		},
+	}}
+	if _, err := NewExternalMetricsServer(&config.ServerConf{}); err == nil {
+		t.Errorf("NewExternalMetricsServer() without client ca err = nil, want error")
+	}
+	s, err := NewExternalMetricsServer(&config.ServerConf{ExternalMetricsInsecure: true})
+	if err != nil {
+		t.Fatalf("NewExternalMetricsServer() err: %+v", err)
+	}
+	s.HandleExternalMetrics(provider)
+	ts := httptest.NewUnstartedServer(s.Handler())
+	ts.TLS = s.tlsConfig
+	ts.StartTLS()
+	defer ts.Close()
+	restConfig := &rest.Config{Host: ts.URL, TLSClientConfig: rest.TLSClientConfig{Insecure: true}}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		t.Fatalf("NewDiscoveryClientForConfig() err: %v", err)
+	}
+	resources, err := discoveryClient.ServerResourcesForGroupVersion("external.metrics.k8s.io/v1beta1")
+	if err != nil {
+		t.Fatalf("ServerResourcesForGroupVersion() err: %v", err)
+	}
+	if len(resources.APIResources) != 2 || resources.APIResources[1].Name != "task_request_rate" ||
+		!resources.APIResources[1].Namespaced {
+		t.Errorf("ServerResourcesForGroupVersion() got = %+v", resources.APIResources)
+	}
+	groups, err := discoveryClient.ServerGroups()
+	if err != nil || len(groups.Groups) != 1 || groups.Groups[0].PreferredVersion.Version != "v1beta1" {
+		t.Errorf("ServerGroups() got = %+v, err: %v", groups, err)
+	}
+
+	metricsClient, err := external_metrics.NewForConfig(restConfig)
+	if err != nil {
+		t.Fatalf("external_metrics.NewForConfig() err: %v", err)
+	}
+	selector := labels.SelectorFromSet(labels.Set{"task_type": "transcode"})
+	list, err := metricsClient.NamespacedMetrics("default").List("task_queue_length", selector)
+	if err != nil {
+		t.Fatalf("List() err: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Value.Value() != 12 {
+		t.Errorf("List() got = %+v", list.Items)
+	}
+	if _, err = metricsClient.NamespacedMetrics("default").List("unknown", labels.Everything()); err == nil {
+		t.Errorf("List() unknown metric should fail")
+	}
+
+	provider.err = errors.New("prometheus unavailable")
+	if _, err = metricsClient.NamespacedMetrics("default").List("task_queue_length", labels.Everything()); err == nil {
+		t.Errorf("List() with provider err should fail")
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"invalid path", http.MethodGet, externalMetricsPath + "/task_queue_length", http.StatusNotFound},
+		{"invalid selector", http.MethodGet, externalMetricsPath + "/namespaces/default/task_queue_length?labelSelector=a%20b",
+			http.StatusBadRequest},
+		{"method not allowed", http.MethodPost, externalMetricsPath, http.StatusMethodNotAllowed},
+	}
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, ts.URL+tt.path, nil)
+			resp, err := httpClient.Do(req)
+			if err != nil {
+				t.Fatalf("request err: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("%s %s got = %d, want %d", tt.method, tt.path, resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
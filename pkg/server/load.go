@@ -0,0 +1,144 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"nanto.io/application-auto-scaling-service/pkg/loadstore"
+	"nanto.io/application-auto-scaling-service/pkg/metrics"
+)
+
+const (
+	loadPath = "/api/v1/load"
+
+	defaultLoadQuerySince = time.Hour
+	// 上报数据时间允许超前接收时间的最大时钟偏差
+	maxLoadClockSkew = time.Minute
+)
+
+// loadPushRequest conductor/worker 上报的负载数据
+type loadPushRequest struct {
+	Samples []loadPushSample `json:"samples"`
+}
+
+type loadPushSample struct {
+	TaskType string `json:"taskType"`
+	// 数据时间，为空时取接收时间
+	Time        *time.Time `json:"time,omitempty"`
+	Requests    float64    `json:"requests"`
+	QueueLength float64    `json:"queueLength"`
+}
+
+// taskTypeLoad 某个任务类型的负载数据
+type taskTypeLoad struct {
+	TaskType string `json:"taskType"`
+	// 查询时间范围内的平均请求量（每分钟）
+	RequestRate float64            `json:"requestRate"`
+	Latest      *loadstore.Sample  `json:"latest,omitempty"`
+	Samples     []loadstore.Sample `json:"samples,omitempty"`
+}
+
+type loadHandler struct {
+	store *loadstore.Store
+}
+
+// HandleLoad 注册负载数据接口：
+//
+//	POST /api/v1/load                               上报各任务类型的请求数、排队任务数，
+//	                                                body: {"samples": [{"taskType": "transcode", "requests": 120, "queueLength": 5}]}
+//	GET  /api/v1/load                               各任务类型最近 1 小时的平均请求量和最新数据
+//	GET  /api/v1/load?taskType=transcode&since=1h   指定任务类型的时序数据
+//
+// 上报数据会影响预测的 minReplicas 及节点申请，POST 与管理接口相同需要认证，见 requireAuth
+func (s *Server) HandleLoad(store *loadstore.Store) {
+	h := &loadHandler{store: store}
+	s.mux.HandleFunc(loadPath, s.requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			h.push(w, r)
+		case http.MethodGet:
+			h.query(w, r)
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+		}
+	}))
+}
+
+func (h *loadHandler) push(w http.ResponseWriter, r *http.Request) {
+	req := &loadPushRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		writeError(w, http.StatusBadRequest, errors.Wrap(err, "invalid request body"))
+		return
+	}
+	now := time.Now()
+	taskTypes := make([]string, 0, len(req.Samples))
+	for _, sample := range req.Samples {
+		if sample.TaskType == "" || sample.Requests < 0 || sample.QueueLength < 0 {
+			writeError(w, http.StatusBadRequest, errors.Errorf("invalid sample: %+v", sample))
+			return
+		}
+		// 未来时间的数据会影响最新值和预测，允许少量时钟偏差
+		if sample.Time != nil && sample.Time.After(now.Add(maxLoadClockSkew)) {
+			writeError(w, http.StatusBadRequest, errors.Errorf("sample time[%s] of task type[%s] is in the future",
+				sample.Time.Format(time.RFC3339), sample.TaskType))
+			return
+		}
+		taskTypes = append(taskTypes, sample.TaskType)
+	}
+	if err := h.store.CheckTaskTypes(taskTypes); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	for _, sample := range req.Samples {
+		t := now
+		if sample.Time != nil {
+			t = *sample.Time
+		}
+		if err := h.store.Add(sample.TaskType, loadstore.Sample{Time: t, Requests: sample.Requests,
+			QueueLength: sample.QueueLength}); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		metrics.LoadSamplesTotal.WithLabelValues(sample.TaskType).Inc()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *loadHandler) query(w http.ResponseWriter, r *http.Request) {
+	since := defaultLoadQuerySince
+	if val := r.URL.Query().Get("since"); val != "" {
+		d, err := time.ParseDuration(val)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, errors.Errorf("invalid since[%s]", val))
+			return
+		}
+		since = d
+	}
+
+	now := time.Now()
+	if taskType := r.URL.Query().Get("taskType"); taskType != "" {
+		load := h.taskTypeLoad(taskType, since, now)
+		load.Samples = h.store.Range(taskType, now.Add(-since), now.Add(time.Nanosecond))
+		writeJSON(w, http.StatusOK, load)
+		return
+	}
+	loads := []*taskTypeLoad{}
+	for _, taskType := range h.store.TaskTypes() {
+		loads = append(loads, h.taskTypeLoad(taskType, since, now))
+	}
+	writeJSON(w, http.StatusOK, loads)
+}
+
+func (h *loadHandler) taskTypeLoad(taskType string, since time.Duration, now time.Time) *taskTypeLoad {
+	load := &taskTypeLoad{TaskType: taskType}
+	load.RequestRate, _ = h.store.RequestRate(taskType, since, now)
+	if latest, ok := h.store.Latest(taskType); ok {
+		load.Latest = &latest
+	}
+	return load
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/loadstore"
+)
+
+func TestLoadHandler(t *testing.T) {
+	store := loadstore.NewStore(time.Hour, 10, 1)
+	s := NewServer(&config.ServerConf{AdminToken: "secret"})
+	s.HandleLoad(store)
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		token      string
+		body       string
+		wantStatus int
+	}{
+		{"missing token", "", `{"samples":[{"taskType":"transcode","requests":1}]}`, http.StatusUnauthorized},
+		{"wrong token", "other", `{"samples":[{"taskType":"transcode","requests":1}]}`, http.StatusUnauthorized},
+		{"push", "secret", `{"samples":[{"taskType":"transcode","requests":120,"queueLength":5}]}`, http.StatusNoContent},
+		{"too many task types", "secret", `{"samples":[{"taskType":"render","requests":1}]}`,
+			http.StatusBadRequest},
+		{"invalid body", "secret", `{"samples":`, http.StatusBadRequest},
+		{"empty task type", "secret", `{"samples":[{"requests":1}]}`, http.StatusBadRequest},
+		{"negative requests", "secret", `{"samples":[{"taskType":"transcode","requests":-1}]}`, http.StatusBadRequest},
+		{"future time", "secret", `{"samples":[{"taskType":"transcode","requests":1,"time":"` +
+			time.Now().Add(time.Hour).Format(time.RFC3339) + `"}]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, ts.URL+loadPath, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request err: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("POST %s got = %d, want %d", loadPath, resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+
+	resp, err := http.Get(ts.URL + loadPath + "?taskType=transcode&since=10m")
+	if err != nil {
+		t.Fatalf("request err: %v", err)
+	}
+	defer resp.Body.Close()
+	got := &taskTypeLoad{}
+	if err = json.NewDecoder(resp.Body).Decode(got); err != nil {
+		t.Fatalf("decode response err: %v", err)
+	}
+	if len(got.Samples) != 1 || got.Latest == nil || got.Latest.QueueLength != 5 || got.RequestRate != 12 {
+		t.Errorf("GET %s got = %+v", loadPath, got)
+	}
+
+	resp, err = http.Get(ts.URL + loadPath + "?since=abc")
+	if err != nil {
+		t.Fatalf("request err: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("GET %s with invalid since got = %d, want 400", loadPath, resp.StatusCode)
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"nanto.io/application-auto-scaling-service/pkg/controller"
+)
+
+const predictionPath = "/api/v1/prediction"
+
+// PredictionProvider 提供请求量预测结果，由 controller.StrategyController 实现
+type PredictionProvider interface {
+	Prediction() (*controller.Prediction, error)
+}
+
+// HandlePrediction 注册请求量预测接口：
+//
+//	GET /api/v1/prediction  各任务类型的请求量预测，及预测范围内各策略时间段的 minReplicas 建议
+func (s *Server) HandlePrediction(provider PredictionProvider) {
+	s.mux.HandleFunc(predictionPath, func(w http.ResponseWriter, r *http.Request) {
+		if !checkMethod(w, r, http.MethodGet) {
+			return
+		}
+		prediction, err := provider.Prediction()
+		if errors.Is(err, controller.ErrPredictionDisabled) {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, prediction)
+	})
+}
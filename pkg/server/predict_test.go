@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/controller"
+)
+
+type fakePredictionProvider struct {
+	err error
+}
+
+func (p *fakePredictionProvider) Prediction() (*controller.Prediction, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &controller.Prediction{Mode: controller.PredictModeRecommend, Target: "chpa"}, nil
+}
+
+func TestPredictionHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"ok", nil, http.StatusOK},
+		{"disabled", controller.ErrPredictionDisabled, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer(&config.ServerConf{})
+			s.HandlePrediction(&fakePredictionProvider{err: tt.err})
+			rec := httptest.NewRecorder()
+			s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, predictionPath, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("GET %s got = %d, want %d", predictionPath, rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"nanto.io/application-auto-scaling-service/pkg/controller"
+)
+
+const proposalPath = "/api/v1/strategies/proposal"
+
+// ProposalProvider 根据历史负载生成策略建议，由 controller.StrategyController 实现
+type ProposalProvider interface {
+	ProposeStrategies(opts controller.GenerateOptions) (*controller.StrategyProposal, error)
+}
+
+// HandleProposal 注册策略生成接口：
+//
+//	GET /api/v1/strategies/proposal?step=30m&maxWindows=6  根据上报的负载数据生成 local-strategies.yaml 格式的策略，
+//	                                                      各时间段边界的选取原因写在文件头注释中；format=json 时返回 json
+func (s *Server) HandleProposal(provider ProposalProvider) {
+	s.mux.HandleFunc(proposalPath, func(w http.ResponseWriter, r *http.Request) {
+		if !checkMethod(w, r, http.MethodGet) {
+			return
+		}
+		opts := controller.GenerateOptions{}
+		query := r.URL.Query()
+		if val := query.Get("step"); val != "" {
+			step, err := time.ParseDuration(val)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, errors.Errorf("invalid step[%s]", val))
+				return
+			}
+			opts.Step = step
+		}
+		if val := query.Get("maxWindows"); val != "" {
+			maxWindows, err := strconv.Atoi(val)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, errors.Errorf("invalid maxWindows[%s]", val))
+				return
+			}
+			opts.MaxWindows = maxWindows
+		}
+
+		proposal, err := provider.ProposeStrategies(opts)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		if query.Get("format") == "json" {
+			writeJSON(w, http.StatusOK, proposal)
+			return
+		}
+		out, err := proposal.YAML()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-yaml")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(out)
+	})
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/controller"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	"nanto.io/application-auto-scaling-service/pkg/utils"
+)
+
+type fakeProposalProvider struct {
+	opts controller.GenerateOptions
+}
+
+func (p *fakeProposalProvider) ProposeStrategies(opts controller.GenerateOptions) (*controller.StrategyProposal,
+	error) {
+	p.opts = opts
+	return &controller.StrategyProposal{
+		Info: &controller.StrategiesInfo{TargetHPA: "chpa", Strategies: []controller.Strategy{{
+			ValidTime: "0:00-24:00",
+			Spec: v1alpha1.CustomedHorizontalPodAutoscalerSpec{MinReplicas: utils.Int32Ptr(1),
+				MaxReplicas: utils.Int32Ptr(2)},
+		}}},
+		Explanations: []controller.WindowExplanation{{ValidTime: "0:00-24:00", Reason: "start of day"}},
+	}, nil
+}
+
+func TestProposalHandler(t *testing.T) {
+	provider := &fakeProposalProvider{}
+	s := NewServer(&config.ServerConf{})
+	s.HandleProposal(provider)
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, proposalPath+"?step=1h&maxWindows=4", nil))
+	body, _ := ioutil.ReadAll(rec.Body)
+	if rec.Code != http.StatusOK || !strings.Contains(string(body), "# 0:00-24:00") ||
+		!strings.Contains(string(body), "targetHPA: chpa") {
+		t.Errorf("GET %s got = %d %s", proposalPath, rec.Code, body)
+	}
+	if provider.opts.MaxWindows != 4 || provider.opts.Step.Hours() != 1 {
+		t.Errorf("GET %s got options %+v", proposalPath, provider.opts)
+	}
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, proposalPath+"?step=abc", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GET %s with invalid step got = %d, want 400", proposalPath, rec.Code)
+	}
+}
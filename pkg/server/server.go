@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/metrics"
+	"nanto.io/application-auto-scaling-service/pkg/utils/logutil"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+var logger = logutil.GetLogger()
+
+// Server application-auto-scaling-service 的 http server，提供管理接口、分流策略接口和 metrics
+type Server struct {
+	addr string
+	mux  *http.ServeMux
+	// 不为空时使用 https
+	tlsConfig *tls.Config
+	// 管理接口写操作的认证 token，为空时只接受本机请求
+	adminToken string
+}
+
+// NewServer 创建 http server，默认提供 /healthz 和 /metrics，其余接口通过 HandleXXX 注册
+func NewServer(conf *config.ServerConf) *Server {
+	s := &Server{
+		addr:       conf.ListenAddr,
+		mux:        http.NewServeMux(),
+		adminToken: adminToken(conf.AdminToken),
+	}
+	s.mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	s.mux.Handle("/metrics", metrics.Handler())
+	return s
+}
+
+// Handler 返回 server 的 http handler
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Start 启动 http server，ctx 结束时退出
+func (s *Server) Start(ctx context.Context, cancel context.CancelFunc) {
+	httpServer := &http.Server{Addr: s.addr, Handler: s.mux, TLSConfig: s.tlsConfig}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer shutdownCancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			logger.Errorf("Shutdown http server err: %v", err)
+		}
+	}()
+
+	logger.Infof("Http server listen on %s, tls: %t", s.addr, s.tlsConfig != nil)
+	var err error
+	if s.tlsConfig != nil {
+		err = httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = httpServer.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Errorf("Http server listen on %s err: %v", s.addr, err)
+		cancel()
+		return
+	}
+	logger.Info("=== Http server exit ===")
+}
+
+// errorResponse 接口出错时的响应
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Errorf("Write json response err: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, &errorResponse{Error: err.Error()})
+}
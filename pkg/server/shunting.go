@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net/http"
+
+	"nanto.io/application-auto-scaling-service/pkg/controller"
+)
+
+const shuntingPath = "/api/v1/shunting"
+
+// ShuntingProvider 提供分流策略，由 controller.StrategyController 实现
+type ShuntingProvider interface {
+	ShuntingStrategy() (*controller.ShuntingStrategy, error)
+}
+
+// HandleShunting 注册分流策略接口：
+//
+//	GET /api/v1/shunting  各任务类型分到本地/外部资源的比例，响应头 ETag 为分流策略版本，
+//	                      请求头 If-None-Match 与当前版本一致时返回 304
+func (s *Server) HandleShunting(provider ShuntingProvider) {
+	s.mux.HandleFunc(shuntingPath, func(w http.ResponseWriter, r *http.Request) {
+		if !checkMethod(w, r, http.MethodGet) {
+			return
+		}
+		strategy, err := provider.ShuntingStrategy()
+		if err != nil {
+			logger.Errorf("Get shunting strategy err: %+v", err)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		etag := `"` + strategy.Version + `"`
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		writeJSON(w, http.StatusOK, strategy)
+	})
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+	"nanto.io/application-auto-scaling-service/pkg/controller"
+)
+
+type fakeShuntingProvider struct{}
+
+func (p *fakeShuntingProvider) ShuntingStrategy() (*controller.ShuntingStrategy, error) {
+	return &controller.ShuntingStrategy{
+		Version:       "v1",
+		Window:        "0:00-09:30",
+		LocalReplicas: 2,
+		TaskTypes: []controller.TaskTypeShare{
+			{TaskType: "transcode", LocalShare: 0.5, ExternalShare: 0.5, Demand: 40, LocalCapacity: 20},
+		},
+	}, nil
+}
+
+func TestShuntingHandler(t *testing.T) {
+	s := NewServer(&config.ServerConf{})
+	s.HandleShunting(&fakeShuntingProvider{})
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + shuntingPath)
+	if err != nil {
+		t.Fatalf("request err: %v", err)
+	}
+	defer resp.Body.Close()
+	got := &controller.ShuntingStrategy{}
+	if err = json.NewDecoder(resp.Body).Decode(got); err != nil {
+		t.Fatalf("decode response err: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != `"v1"` || got.TaskTypes[0].LocalShare != 0.5 {
+		t.Errorf("GET %s got = %d %+v, header: %v", shuntingPath, resp.StatusCode, got, resp.Header)
+	}
+
+	// 版本未变化时返回 304
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+shuntingPath, nil)
+	req.Header.Set("If-None-Match", `"v1"`)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request err: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("GET %s with If-None-Match got = %d, want 304", shuntingPath, resp.StatusCode)
+	}
+}
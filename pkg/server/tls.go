@@ -0,0 +1,98 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+)
+
+const (
+	selfSignedCommonName = "application-auto-scaling-service"
+	selfSignedValidity   = 365 * 24 * time.Hour
+)
+
+// NewExternalMetricsServer 创建 external.metrics.k8s.io 的 https server，以 APIService 注册后由 kube-aggregator 访问。
+// 未配置证书时使用自签名证书（APIService 需设置 insecureSkipTLSVerify）；必须配置 client CA 校验 aggregator 的客户端证书，
+// 除非显式开启 external_metrics_insecure
+func NewExternalMetricsServer(conf *config.ServerConf) (*Server, error) {
+	tlsConfig, err := newTLSConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	s := NewServer(&config.ServerConf{ListenAddr: conf.ExternalMetricsListenAddr})
+	s.tlsConfig = tlsConfig
+	return s, nil
+}
+
+func newTLSConfig(conf *config.ServerConf) (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+	if conf.TLSCertFile != "" || conf.TLSKeyFile != "" {
+		if cert, err = tls.LoadX509KeyPair(conf.TLSCertFile, conf.TLSKeyFile); err != nil {
+			return nil, errors.Wrapf(err, "load tls cert[%s] key[%s] err", conf.TLSCertFile, conf.TLSKeyFile)
+		}
+	} else {
+		logger.Warn("Tls cert is not configured, use self-signed cert")
+		if cert, err = selfSignedCert(); err != nil {
+			return nil, err
+		}
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	if conf.ClientCAFile == "" {
+		if !conf.ExternalMetricsInsecure {
+			return nil, errors.New("client ca file is required to verify kube-aggregator, " +
+				"set external_metrics_insecure to serve without client cert verification")
+		}
+		logger.Warn("Client ca is not configured, external metrics are served without client cert verification")
+	} else {
+		caPEM, err := ioutil.ReadFile(conf.ClientCAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read client ca file[%s] err", conf.ClientCAFile)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, errors.Errorf("no valid cert in client ca file[%s]", conf.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// selfSignedCert 生成内存中的自签名证书
+func selfSignedCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, errors.Wrap(err, "generate key err")
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return tls.Certificate{}, errors.Wrap(err, "generate serial number err")
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: selfSignedCommonName},
+		DNSNames:     []string{selfSignedCommonName, selfSignedCommonName + ".default.svc"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(selfSignedValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, errors.Wrap(err, "create self-signed cert err")
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
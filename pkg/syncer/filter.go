@@ -0,0 +1,158 @@
+package syncer
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+)
+
+// 节点字段选择器支持的字段，与 apiserver 一致
+const (
+	fieldMetadataName      = "metadata.name"
+	fieldSpecUnschedulable = "spec.unschedulable"
+)
+
+// taintMatcher 匹配 key[=value][:effect] 格式的污点，value/effect 为空时匹配任意值
+type taintMatcher struct {
+	key    string
+	value  *string
+	effect corev1.TaintEffect
+}
+
+func (m *taintMatcher) matches(taint *corev1.Taint) bool {
+	return taint.Key == m.key && (m.value == nil || taint.Value == *m.value) &&
+		(m.effect == "" || taint.Effect == m.effect)
+}
+
+// nodeFilter 同步给 Vega 的节点过滤条件，为 nil 时不过滤
+type nodeFilter struct {
+	labelSelector        labels.Selector
+	fieldSelector        fields.Selector
+	excludeTaints        []taintMatcher
+	requireConditions    map[corev1.NodeConditionType]corev1.ConditionStatus
+	excludeUnschedulable bool
+}
+
+// newNodeFilter 解析节点过滤配置，条件均为空时返回 nil
+func newNodeFilter(conf *config.NodeFilterConf) (*nodeFilter, error) {
+	if conf == nil || (conf.LabelSelector == "" && conf.FieldSelector == "" && conf.ExcludeTaints == "" &&
+		conf.RequireConditions == "" && !conf.ExcludeUnschedulable) {
+		return nil, nil
+	}
+	f := &nodeFilter{excludeUnschedulable: conf.ExcludeUnschedulable}
+	var err error
+	if f.labelSelector, err = labels.Parse(conf.LabelSelector); err != nil {
+		return nil, errors.Wrapf(err, "invalid node label selector[%s]", conf.LabelSelector)
+	}
+	if f.fieldSelector, err = fields.ParseSelector(conf.FieldSelector); err != nil {
+		return nil, errors.Wrapf(err, "invalid node field selector[%s]", conf.FieldSelector)
+	}
+	for _, req := range f.fieldSelector.Requirements() {
+		if req.Field != fieldMetadataName && req.Field != fieldSpecUnschedulable {
+			return nil, errors.Errorf("unsupported node field[%s], must be %s or %s", req.Field, fieldMetadataName,
+				fieldSpecUnschedulable)
+		}
+	}
+	for _, spec := range splitList(conf.ExcludeTaints) {
+		m := taintMatcher{key: spec}
+		if i := strings.LastIndex(m.key, ":"); i >= 0 {
+			m.key, m.effect = m.key[:i], corev1.TaintEffect(m.key[i+1:])
+			switch m.effect {
+			case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+			default:
+				return nil, errors.Errorf("invalid taint effect in [%s]", spec)
+			}
+		}
+		if i := strings.Index(m.key, "="); i >= 0 {
+			value := m.key[i+1:]
+			m.key, m.value = m.key[:i], &value
+		}
+		if m.key == "" {
+			return nil, errors.Errorf("invalid taint[%s], taint key is empty", spec)
+		}
+		f.excludeTaints = append(f.excludeTaints, m)
+	}
+	for _, spec := range splitList(conf.RequireConditions) {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("invalid node condition[%s], must be type=status", spec)
+		}
+		status := corev1.ConditionStatus(parts[1])
+		switch status {
+		case corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionUnknown:
+		default:
+			return nil, errors.Errorf("invalid node condition status in [%s]", spec)
+		}
+		if f.requireConditions == nil {
+			f.requireConditions = map[corev1.NodeConditionType]corev1.ConditionStatus{}
+		}
+		f.requireConditions[corev1.NodeConditionType(parts[0])] = status
+	}
+	return f, nil
+}
+
+// tweakListOptions 由 apiserver 按标签和字段选择器过滤
+func (f *nodeFilter) tweakListOptions(options *metav1.ListOptions) {
+	if f == nil {
+		return
+	}
+	options.LabelSelector = f.labelSelector.String()
+	options.FieldSelector = f.fieldSelector.String()
+}
+
+// matches 节点是否满足所有过滤条件；选择器也在本地匹配，informer 缓存中的节点变化后可能不再满足
+func (f *nodeFilter) matches(node *corev1.Node) bool {
+	if f == nil {
+		return true
+	}
+	if !f.labelSelector.Matches(labels.Set(node.Labels)) {
+		return false
+	}
+	if !f.fieldSelector.Matches(fields.Set{fieldMetadataName: node.Name,
+		fieldSpecUnschedulable: strconv.FormatBool(node.Spec.Unschedulable)}) {
+		return false
+	}
+	if f.excludeUnschedulable && node.Spec.Unschedulable {
+		return false
+	}
+	for i := range node.Spec.Taints {
+		for j := range f.excludeTaints {
+			if f.excludeTaints[j].matches(&node.Spec.Taints[i]) {
+				return false
+			}
+		}
+	}
+	for condType, status := range f.requireConditions {
+		if nodeConditionStatus(node, condType) != status {
+			return false
+		}
+	}
+	return true
+}
+
+func nodeConditionStatus(node *corev1.Node, condType corev1.NodeConditionType) corev1.ConditionStatus {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == condType {
+			return cond.Status
+		}
+	}
+	return ""
+}
+
+// splitList 解析逗号分隔的配置项，忽略空项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,158 @@
+package syncer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"nanto.io/application-auto-scaling-service/pkg/config"
+)
+
+func newFilterTestNode(name, pool string, ready bool) *corev1.Node {
+	node := newTestNode(name, "id-"+name)
+	node.Labels = map[string]string{"nodepool": pool}
+	status := corev1.ConditionTrue
+	if !ready {
+		status = corev1.ConditionFalse
+	}
+	node.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}}
+	return node
+}
+
+func TestInstanceSyncer_listNodes_filter(t *testing.T) {
+	master := newFilterTestNode("master-1", "system", true)
+	master.Labels["node-role.kubernetes.io/master"] = ""
+	master.Spec.Taints = []corev1.Taint{{Key: "node-role.kubernetes.io/master", Effect: corev1.TaintEffectNoSchedule}}
+	draining := newFilterTestNode("pool-a-2", "pool-a", true)
+	draining.Spec.Unschedulable = true
+	toBeDeleted := newFilterTestNode("pool-a-3", "pool-a", true)
+	toBeDeleted.Spec.Taints = []corev1.Taint{{Key: "ToBeDeletedByClusterAutoscaler", Value: "1600000000",
+		Effect: corev1.TaintEffectNoSchedule}}
+	gpu := newFilterTestNode("pool-b-2", "pool-b", true)
+	gpu.Spec.Taints = []corev1.Taint{{Key: "accelerator", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}}
+	setupTestNodes(master, draining, toBeDeleted, gpu,
+		newFilterTestNode("pool-a-1", "pool-a", true),
+		newFilterTestNode("pool-a-4", "pool-a", false),
+		newFilterTestNode("pool-b-1", "pool-b", true),
+		newFilterTestNode("pool-c-1", "pool-c", true))
+
+	tests := []struct {
+		name string
+		conf *config.NodeFilterConf
+		want []string
+	}{
+		{"no filter", &config.NodeFilterConf{}, []string{"master-1", "pool-a-1", "pool-a-2", "pool-a-3", "pool-a-4",
+			"pool-b-1", "pool-b-2", "pool-c-1"}},
+		{"label selector", &config.NodeFilterConf{LabelSelector: "nodepool in (pool-a,pool-b)"},
+			[]string{"pool-a-1", "pool-a-2", "pool-a-3", "pool-a-4", "pool-b-1", "pool-b-2"}},
+		{"exclude master by label", &config.NodeFilterConf{LabelSelector: "!node-role.kubernetes.io/master"},
+			[]string{"pool-a-1", "pool-a-2", "pool-a-3", "pool-a-4", "pool-b-1", "pool-b-2", "pool-c-1"}},
+		{"field selector", &config.NodeFilterConf{FieldSelector: "spec.unschedulable=false,metadata.name!=pool-c-1"},
+			[]string{"master-1", "pool-a-1", "pool-a-3", "pool-a-4", "pool-b-1", "pool-b-2"}},
+		{"exclude taints", &config.NodeFilterConf{
+			ExcludeTaints: "node-role.kubernetes.io/master:NoSchedule, ToBeDeletedByClusterAutoscaler,accelerator=cpu"},
+			[]string{"pool-a-1", "pool-a-2", "pool-a-4", "pool-b-1", "pool-b-2", "pool-c-1"}},
+		{"exclude taint with value", &config.NodeFilterConf{ExcludeTaints: "accelerator=gpu:NoSchedule"},
+			[]string{"master-1", "pool-a-1", "pool-a-2", "pool-a-3", "pool-a-4", "pool-b-1", "pool-c-1"}},
+		{"require conditions", &config.NodeFilterConf{RequireConditions: "Ready=True"},
+			[]string{"master-1", "pool-a-1", "pool-a-2", "pool-a-3", "pool-b-1", "pool-b-2", "pool-c-1"}},
+		{"combined", &config.NodeFilterConf{LabelSelector: "nodepool=pool-a", ExcludeUnschedulable: true,
+			ExcludeTaints: "ToBeDeletedByClusterAutoscaler", RequireConditions: "Ready=True"}, []string{"pool-a-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := newNodeFilter(tt.conf)
+			if err != nil {
+				t.Fatalf("newNodeFilter() err: %+v", err)
+			}
+			s := newTestSyncer(t, &fakeObjectStore{})
+			s.filter = filter
+			nodes, err := s.listNodes()
+			if err != nil {
+				t.Fatalf("listNodes() err: %+v", err)
+			}
+			got := []string{}
+			for _, node := range nodes {
+				got = append(got, node.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("listNodes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstanceSyncer_SyncInstanceToOBS_filterChange(t *testing.T) {
+	cs := setupTestNodes(newFilterTestNode("pool-a-1", "pool-a", true), newFilterTestNode("pool-a-2", "pool-a", true))
+	filter, err := newNodeFilter(&config.NodeFilterConf{ExcludeTaints: "ToBeDeletedByClusterAutoscaler"})
+	if err != nil {
+		t.Fatalf("newNodeFilter() err: %+v", err)
+	}
+	store := &fakeObjectStore{}
+	s := newTestSyncer(t, store)
+	s.filter = filter
+	s.intervalMinute = time.Hour
+	s.debounce = 50 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.SyncInstanceToOBS(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	waitFor := func(cond func() bool) bool {
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		return cond()
+	}
+	if !waitFor(func() bool { return store.uploadCount() == 1 }) {
+		t.Fatalf("uploads = %d, want 1", store.uploadCount())
+	}
+
+	// 节点被打上排除的污点后重新同步，不再包含该节点
+	node, err := cs.CoreV1().Nodes().Get(ctx, "pool-a-2", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get node err: %v", err)
+	}
+	node.Spec.Taints = []corev1.Taint{{Key: "ToBeDeletedByClusterAutoscaler", Effect: corev1.TaintEffectNoSchedule}}
+	if _, err = cs.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update node err: %v", err)
+	}
+	if !waitFor(func() bool { return store.uploadCount() == 2 }) {
+		t.Fatalf("uploads after taint = %d, want 2", store.uploadCount())
+	}
+	nodes, err := s.listNodes()
+	if err != nil || len(nodes) != 1 || nodes[0].Name != "pool-a-1" {
+		t.Errorf("listNodes() after taint = %v, err: %v", nodes, err)
+	}
+}
+
+func Test_newNodeFilter_invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		conf *config.NodeFilterConf
+	}{
+		{"label selector", &config.NodeFilterConf{LabelSelector: "nodepool in (pool-a"}},
+		{"unsupported field", &config.NodeFilterConf{FieldSelector: "status.phase=Running"}},
+		{"taint effect", &config.NodeFilterConf{ExcludeTaints: "dedicated:NoRun"}},
+		{"taint key", &config.NodeFilterConf{ExcludeTaints: "=gpu"}},
+		{"condition format", &config.NodeFilterConf{RequireConditions: "Ready"}},
+		{"condition status", &config.NodeFilterConf{RequireConditions: "Ready=Yes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newNodeFilter(tt.conf); err == nil {
+				t.Errorf("newNodeFilter() err = nil, want error")
+			}
+		})
+	}
+}
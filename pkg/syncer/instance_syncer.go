@@ -2,36 +2,77 @@ package syncer
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 
 	"nanto.io/application-auto-scaling-service/pkg/config"
 	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/metrics"
 	"nanto.io/application-auto-scaling-service/pkg/utils/logutil"
 	"nanto.io/application-auto-scaling-service/pkg/utils/obsutil"
 )
 
 var logger = logutil.GetLogger()
 
+// metadataContentHash 上传的对象中记录内容 sha256 的自定义元数据
+const metadataContentHash = "content-sha256"
+
+// objectStore 存放实例信息的对象存储，由 obsutil.ObsClient 实现
+type objectStore interface {
+	UploadObjWithMetadata(bucket, srcPath, targetPath string, metadata map[string]string) (string, error)
+	GetObjMeta(bucket, key string) (*obsutil.ObjMeta, error)
+}
+
 // InstanceSyncer 周期同步 X实例 信息给 Vega
 type InstanceSyncer struct {
-	obsCli    *obsutil.ObsClient
+	obsCli    objectStore
 	clusterId string
 	// obs bucket name
 	bucket string
 	// 上传文件本地路径
 	srcPath string
 	// 上传文件目标路径（obs）
-	targetPath     string
+	targetPath string
+	// 定期兜底同步的间隔
 	intervalMinute time.Duration
+	// 节点变化后等待合并的时间，期间的多次变化只同步一次
+	debounce time.Duration
+	// 实例信息格式，InventoryFormatV1 或 InventoryFormatV2
+	format string
+	// 节点过滤条件，为 nil 时同步所有节点
+	filter *nodeFilter
+	// 启动 node informer 后从缓存中读取节点
+	nodeLister corelisters.NodeLister
+	// 最近一次上传（或启动时远端已有）内容的 sha256，内容未变化时不重复上传
+	lastHash string
+	// 是否已与远端对象的元数据比较过
+	remoteChecked bool
 }
 
-func NewInstanceSyncer(obsCli *obsutil.ObsClient, obsConfig *config.ObsConf, clusterId string) *InstanceSyncer {
+func NewInstanceSyncer(obsCli *obsutil.ObsClient, obsConfig *config.ObsConf, filterConfig *config.NodeFilterConf,
+	clusterId string) (*InstanceSyncer, error) {
+	filter, err := newNodeFilter(filterConfig)
+	if err != nil {
+		return nil, err
+	}
+	format := obsConfig.InventoryFormat
+	if format != InventoryFormatV1 && format != InventoryFormatV2 {
+		logger.Warnf("Invalid inventory format[%s], use %s", format, InventoryFormatV1)
+		format = InventoryFormatV1
+	}
 	return &InstanceSyncer{
 		obsCli:         obsCli,
 		clusterId:      clusterId,
@@ -39,19 +80,46 @@ func NewInstanceSyncer(obsCli *obsutil.ObsClient, obsConfig *config.ObsConf, clu
 		srcPath:        fmt.Sprintf(obsConfig.SourceFileNodeIdsTemplate, clusterId),
 		targetPath:     fmt.Sprintf(obsConfig.ObjectKeyNodeIdsTemplate, clusterId),
 		intervalMinute: time.Duration(obsConfig.SyncNodeIdsToOBSIntervalMinute) * time.Minute,
-	}
+		debounce:       time.Duration(obsConfig.SyncDebounceSecond) * time.Second,
+		format:         format,
+		filter:         filter,
+	}, nil
 }
 
-// SyncInstanceToOBS 同步实例信息(nodeId)到 obs，供 Vega 获取
+// SyncInstanceToOBS 同步实例信息(nodeId)到 obs，供 Vega 获取。节点变化时（合并 debounce 时间内的多次变化）同步，
+// 并按 intervalMinute 定期兜底同步，兜底同步时以远端对象为准判断是否需要上传
 func (s *InstanceSyncer) SyncInstanceToOBS(ctx context.Context) {
+	changeCh := s.startNodeInformer(ctx)
 	if err := s.syncInstanceToOBS(); err != nil {
 		logger.Errorf("SyncInstanceToOBS err: %+v", err)
 	}
 	ticker := time.NewTicker(s.intervalMinute)
 	defer ticker.Stop()
+	var (
+		debounceTimer *time.Timer
+		debounceCh    <-chan time.Time
+	)
+	defer func() {
+		if debounceTimer != nil {
+			debounceTimer.Stop()
+		}
+	}()
 	for {
 		select {
+		case <-changeCh:
+			// 以第一次变化开始计时，避免持续变化时一直不同步
+			if debounceCh == nil {
+				debounceTimer = time.NewTimer(s.debounce)
+				debounceCh = debounceTimer.C
+			}
+		case <-debounceCh:
+			debounceTimer, debounceCh = nil, nil
+			if err := s.syncInstanceToOBS(); err != nil {
+				logger.Errorf("SyncInstanceToOBS on node change err: %+v", err)
+			}
 		case <-ticker.C:
+			// 远端对象可能被删除或覆盖，定期同步时重新比较远端元数据
+			s.remoteChecked, s.lastHash = false, ""
 			if err := s.syncInstanceToOBS(); err != nil {
 				logger.Errorf("SyncInstanceToOBS err: %+v", err)
 				continue
@@ -63,69 +131,148 @@ func (s *InstanceSyncer) SyncInstanceToOBS(ctx context.Context) {
 	}
 }
 
+// startNodeInformer 启动 node informer，返回节点变化的通知 channel；缓存同步失败时返回 nil，仅定期同步
+func (s *InstanceSyncer) startNodeInformer(ctx context.Context) <-chan struct{} {
+	changeCh := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changeCh <- struct{}{}:
+		default:
+		}
+	}
+	factory := informers.NewSharedInformerFactoryWithOptions(k8sclient.GetKubeClientSet(), 0,
+		informers.WithTweakListOptions(s.filter.tweakListOptions))
+	informer := factory.Core().V1().Nodes()
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { notify() },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, ok1 := oldObj.(*corev1.Node)
+			newNode, ok2 := newObj.(*corev1.Node)
+			if ok1 && ok2 && !nodeChanged(oldNode, newNode) &&
+				s.filter.matches(oldNode) == s.filter.matches(newNode) {
+				return
+			}
+			notify()
+		},
+		DeleteFunc: func(obj interface{}) { notify() },
+	})
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.Informer().HasSynced) {
+		logger.Warn("Wait for node informer cache sync failed, sync node ids by interval only")
+		return nil
+	}
+	s.nodeLister = informer.Lister()
+	// 缓存同步期间的 Add 事件已由启动时的同步覆盖
+	select {
+	case <-changeCh:
+	default:
+	}
+	return changeCh
+}
+
+// nodeChanged 节点的变化是否影响同步给 Vega 的内容，忽略心跳等状态更新；托管 pod 数的变化由定期同步兜底
+func nodeChanged(oldNode, newNode *corev1.Node) bool {
+	return newNodeInventory(oldNode) != newNodeInventory(newNode)
+}
+
 func (s *InstanceSyncer) syncInstanceToOBS() error {
-	var (
-		nodeIds []string
-		err     error
-	)
-	nodeIds, err = getNodeIds()
+	nodes, err := s.listNodes()
 	if err != nil {
 		return err
 	}
-	logger.Infof("Get nodeIds: %v", nodeIds)
-
-	// todo 做缓存，先判断是否修改，再上传
+	dataBytes, err := s.encodeInventory(context.Background(), nodes)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(dataBytes)
+	contentHash := hex.EncodeToString(hash[:])
+	if !s.remoteChecked {
+		s.checkRemote(dataBytes, contentHash)
+	}
+	if contentHash == s.lastHash {
+		logger.Infof("Inventory not changed, skip uploading to %s", s.targetPath)
+		metrics.InstanceSyncTotal.WithLabelValues("skipped").Inc()
+		return nil
+	}
 
-	if err = writeNodeIdsFile(s.clusterId, nodeIds, s.srcPath); err != nil {
+	if err = writeNodeIdsFile(dataBytes, s.srcPath); err != nil {
+		metrics.InstanceSyncTotal.WithLabelValues("error").Inc()
 		return err
 	}
-	if err = s.obsCli.UploadObj(s.bucket, s.srcPath, s.targetPath); err != nil {
+	if _, err = s.obsCli.UploadObjWithMetadata(s.bucket, s.srcPath, s.targetPath,
+		map[string]string{metadataContentHash: contentHash}); err != nil {
+		metrics.InstanceSyncTotal.WithLabelValues("error").Inc()
 		return err
 	}
+	s.lastHash = contentHash
+	metrics.InstanceSyncTotal.WithLabelValues("uploaded").Inc()
 	return nil
 }
 
-func getNodeIds() ([]string, error) {
-	nodes, err := k8sclient.GetKubeClientSet().CoreV1().Nodes().List(context.Background(), v1.ListOptions{})
+// checkRemote 启动后首次同步及定期兜底同步时与远端对象比较：自定义元数据中的 sha256 或 ETag（内容的 md5）一致时视为已上传
+func (s *InstanceSyncer) checkRemote(dataBytes []byte, contentHash string) {
+	meta, err := s.obsCli.GetObjMeta(s.bucket, s.targetPath)
 	if err != nil {
-		return nil, errors.Wrap(err, "clientset get nodes err")
+		if errors.Is(err, obsutil.ErrObjNotFound) {
+			s.remoteChecked = true
+		} else {
+			logger.Warnf("Get metadata of %s err, upload directly: %+v", s.targetPath, err)
+		}
+		return
 	}
-
-	nodeIds := []string{}
-	for _, node := range nodes.Items {
-		nodeIds = append(nodeIds, node.Spec.ProviderID)
+	s.remoteChecked = true
+	md5Sum := md5.Sum(dataBytes)
+	for key, value := range meta.Metadata {
+		if strings.EqualFold(key, metadataContentHash) && value == contentHash {
+			s.lastHash = contentHash
+			return
+		}
+	}
+	if strings.Trim(meta.ETag, `"`) == hex.EncodeToString(md5Sum[:]) {
+		s.lastHash = contentHash
 	}
-
-	return nodeIds, nil
-}
-
-type instanceData struct {
-	ClusterId string
-	NodeIds   []string
 }
 
-func newInstanceData(clusterId string, nodeIds []string) *instanceData {
-	return &instanceData{ClusterId: clusterId, NodeIds: nodeIds}
+// listNodes 满足过滤条件的节点，启动了 node informer 时从缓存中读取
+func (s *InstanceSyncer) listNodes() ([]*corev1.Node, error) {
+	var all []*corev1.Node
+	if s.nodeLister != nil {
+		nodes, err := s.nodeLister.List(labels.Everything())
+		if err != nil {
+			return nil, errors.Wrap(err, "list nodes from informer err")
+		}
+		all = nodes
+	} else {
+		options := v1.ListOptions{}
+		s.filter.tweakListOptions(&options)
+		list, err := k8sclient.GetKubeClientSet().CoreV1().Nodes().List(context.Background(), options)
+		if err != nil {
+			return nil, errors.Wrap(err, "clientset get nodes err")
+		}
+		for i := range list.Items {
+			all = append(all, &list.Items[i])
+		}
+	}
+	nodes := make([]*corev1.Node, 0, len(all))
+	for _, node := range all {
+		if s.filter.matches(node) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil
 }
 
-func writeNodeIdsFile(clusterId string, nodeIds []string, filePath string) error {
-	var (
-		dataFile  *os.File
-		dataBytes []byte
-		err       error
-	)
-	data := newInstanceData(clusterId, nodeIds)
-	if dataBytes, err = json.Marshal(data); err != nil {
-		return errors.Wrapf(err, "Marshal data[%+v] err", data)
-	}
-	if dataFile, err = os.Create(filePath); err != nil {
+func writeNodeIdsFile(dataBytes []byte, filePath string) error {
+	dataFile, err := os.Create(filePath)
+	if err != nil {
 		return errors.Wrapf(err, "create file[%s] failed", filePath)
 	}
+	defer dataFile.Close()
 	// chmod 避免umask覆盖
 	if err = os.Chmod(filePath, 0640); err != nil {
 		return errors.Wrapf(err, "chmod file[%s] failed", filePath)
 	}
-	if _, err = dataFile.WriteString(string(dataBytes)); err != nil {
+	if _, err = dataFile.Write(dataBytes); err != nil {
 		return errors.Wrapf(err, "create file[%s] failed", filePath)
 	}
 	return nil
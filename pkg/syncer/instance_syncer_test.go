@@ -0,0 +1,240 @@
+package syncer
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	crdfake "nanto.io/application-auto-scaling-service/pkg/k8sclient/clientset/versioned/fake"
+	"nanto.io/application-auto-scaling-service/pkg/utils/obsutil"
+)
+
+// fakeObjectStore 记录上传次数，远端对象元数据由 meta 指定，为空时对象不存在
+type fakeObjectStore struct {
+	mu      sync.Mutex
+	meta    *obsutil.ObjMeta
+	metaErr error
+	uploads int
+}
+
+func (f *fakeObjectStore) UploadObjWithMetadata(_, _, _ string, metadata map[string]string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.uploads++
+	f.meta = &obsutil.ObjMeta{Metadata: metadata}
+	return "", nil
+}
+
+func (f *fakeObjectStore) GetObjMeta(_, key string) (*obsutil.ObjMeta, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.metaErr != nil {
+		return nil, f.metaErr
+	}
+	if f.meta == nil {
+		return nil, errors.Wrapf(obsutil.ErrObjNotFound, "object[%s]", key)
+	}
+	return f.meta, nil
+}
+
+func (f *fakeObjectStore) uploadCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.uploads
+}
+
+// deleteObject 模拟远端对象被删除
+func (f *fakeObjectStore) deleteObject() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.meta = nil
+}
+
+func newTestNode(name, providerID string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: corev1.NodeSpec{ProviderID: providerID}}
+}
+
+func setupTestNodes(nodes ...runtime.Object) *kubefake.Clientset {
+	cs := kubefake.NewSimpleClientset(nodes...)
+	k8sclient.SetK8sClientSet(cs, crdfake.NewSimpleClientset(), record.NewFakeRecorder(10))
+	return cs
+}
+
+func newTestSyncer(t *testing.T, store *fakeObjectStore) *InstanceSyncer {
+	return &InstanceSyncer{obsCli: store, clusterId: "cluster-1", bucket: "bucket",
+		srcPath: filepath.Join(t.TempDir(), "nodeIds.txt"), targetPath: "aass/cluster-1_nodeIds.txt",
+		format: InventoryFormatV2}
+}
+
+func TestInstanceSyncer_syncInstanceToOBS(t *testing.T) {
+	cs := setupTestNodes(newTestNode("node-1", "id-1"), newTestNode("node-2", "id-2"))
+	store := &fakeObjectStore{}
+	s := newTestSyncer(t, store)
+
+	for i := 0; i < 2; i++ {
+		if err := s.syncInstanceToOBS(); err != nil {
+			t.Fatalf("syncInstanceToOBS() err: %+v", err)
+		}
+	}
+	if store.uploads != 1 {
+		t.Errorf("syncInstanceToOBS() without changes uploads = %d, want 1", store.uploads)
+	}
+
+	// 节点变化后重新上传
+	if err := cs.Tracker().Add(newTestNode("node-3", "id-3")); err != nil {
+		t.Fatalf("add node err: %v", err)
+	}
+	if err := s.syncInstanceToOBS(); err != nil {
+		t.Fatalf("syncInstanceToOBS() err: %+v", err)
+	}
+	if store.uploads != 2 {
+		t.Errorf("syncInstanceToOBS() after node added uploads = %d, want 2", store.uploads)
+	}
+}
+
+func TestInstanceSyncer_checkRemote(t *testing.T) {
+	setupTestNodes(newTestNode("node-1", "id-1"))
+	content := []byte(`{"ClusterId":"cluster-1","NodeIds":["id-1"]}`)
+	md5Sum := md5.Sum(content)
+	tests := []struct {
+		name        string
+		store       *fakeObjectStore
+		wantUploads int
+	}{
+		{"remote not found", &fakeObjectStore{}, 1},
+		{"remote etag matches", &fakeObjectStore{meta: &obsutil.ObjMeta{
+			ETag: `"` + hex.EncodeToString(md5Sum[:]) + `"`}}, 0},
+		{"remote changed", &fakeObjectStore{meta: &obsutil.ObjMeta{ETag: `"other"`,
+			Metadata: map[string]string{metadataContentHash: "other"}}}, 1},
+		{"get metadata err", &fakeObjectStore{metaErr: errors.New("timeout")}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSyncer(t, tt.store)
+			s.format = InventoryFormatV1
+			if err := s.syncInstanceToOBS(); err != nil {
+				t.Fatalf("syncInstanceToOBS() err: %+v", err)
+			}
+			if tt.store.uploads != tt.wantUploads {
+				t.Errorf("syncInstanceToOBS() uploads = %d, want %d", tt.store.uploads, tt.wantUploads)
+			}
+		})
+	}
+
+	// 远端元数据中记录的 sha256 一致时不上传
+	store := &fakeObjectStore{}
+	if err := newTestSyncer(t, store).syncInstanceToOBS(); err != nil {
+		t.Fatalf("syncInstanceToOBS() err: %+v", err)
+	}
+	if err := newTestSyncer(t, store).syncInstanceToOBS(); err != nil || store.uploads != 1 {
+		t.Errorf("syncInstanceToOBS() after restart uploads = %d, err: %v", store.uploads, err)
+	}
+}
+
+func TestInstanceSyncer_SyncInstanceToOBS_debounce(t *testing.T) {
+	cs := setupTestNodes(newTestNode("node-1", "id-1"))
+	store := &fakeObjectStore{}
+	s := newTestSyncer(t, store)
+	s.intervalMinute = time.Hour
+	s.debounce = 200 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.SyncInstanceToOBS(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitUploads := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for store.uploadCount() < want && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if got := store.uploadCount(); got != want {
+			t.Fatalf("uploads = %d, want %d", got, want)
+		}
+	}
+	waitUploads(1)
+
+	// 短时间内多个节点加入只上传一次
+	for _, node := range []*corev1.Node{newTestNode("node-2", "id-2"), newTestNode("node-3", "id-3"),
+		newTestNode("node-4", "id-4")} {
+		if _, err := cs.CoreV1().Nodes().Create(ctx, node, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("create node err: %v", err)
+		}
+	}
+	waitUploads(2)
+	time.Sleep(3 * s.debounce)
+	if got := store.uploadCount(); got != 2 {
+		t.Errorf("uploads after burst = %d, want 2", got)
+	}
+
+	// 不影响同步内容的节点更新不触发同步
+	node, err := cs.CoreV1().Nodes().Get(ctx, "node-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get node err: %v", err)
+	}
+	node.Labels = map[string]string{"foo": "bar"}
+	if _, err = cs.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("update node err: %v", err)
+	}
+	if err = cs.CoreV1().Nodes().Delete(ctx, "node-4", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("delete node err: %v", err)
+	}
+	waitUploads(3)
+}
+
+func TestInstanceSyncer_SyncInstanceToOBS_interval(t *testing.T) {
+	setupTestNodes(newTestNode("node-1", "id-1"))
+	store := &fakeObjectStore{}
+	s := newTestSyncer(t, store)
+	s.intervalMinute = 100 * time.Millisecond
+	s.debounce = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.SyncInstanceToOBS(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitUploads := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for store.uploadCount() < want && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if got := store.uploadCount(); got != want {
+			t.Fatalf("uploads = %d, want %d", got, want)
+		}
+	}
+	waitUploads(1)
+	// 远端对象未变化时定期同步不重复上传
+	time.Sleep(3 * s.intervalMinute)
+	if got := store.uploadCount(); got != 1 {
+		t.Errorf("uploads with remote unchanged = %d, want 1", got)
+	}
+
+	// 远端对象被删除后定期同步重新上传
+	store.deleteObject()
+	waitUploads(2)
+}
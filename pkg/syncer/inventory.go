@@ -0,0 +1,182 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+)
+
+// 同步给 Vega 的实例信息格式
+const (
+	// InventoryFormatV1 旧格式，仅包含 ClusterId 与有 providerID 的 NodeIds
+	InventoryFormatV1 = "v1"
+	// InventoryFormatV2 包含各节点详情的版本化格式
+	InventoryFormatV2 = "v2"
+)
+
+// instanceData v1 格式的实例信息
+type instanceData struct {
+	ClusterId string
+	NodeIds   []string
+}
+
+func newInstanceData(clusterId string, nodeIds []string) *instanceData {
+	return &instanceData{ClusterId: clusterId, NodeIds: nodeIds}
+}
+
+// inventory v2 格式的实例信息
+type inventory struct {
+	Version   string          `json:"version"`
+	ClusterId string          `json:"clusterId"`
+	Nodes     []nodeInventory `json:"nodes"`
+}
+
+// nodeInventory 节点详情
+type nodeInventory struct {
+	Name       string `json:"name"`
+	ProviderID string `json:"providerId"`
+	// 节点没有 providerID 时为 true，Vega 无法将其对应到实例
+	ProviderIDMissing bool `json:"providerIdMissing,omitempty"`
+	Ready             bool `json:"ready"`
+	// 节点未被 cordon
+	Schedulable bool   `json:"schedulable"`
+	Zone        string `json:"zone,omitempty"`
+	Region      string `json:"region,omitempty"`
+	Flavor      string `json:"flavor,omitempty"`
+	// 可分配 CPU，单位 m
+	AllocatableMilliCPU int64 `json:"allocatableMilliCpu"`
+	// 可分配内存，单位字节
+	AllocatableMemoryBytes int64 `json:"allocatableMemoryBytes"`
+	// 节点上 customHPA 目标负载的 pod 数
+	ManagedPods int `json:"managedPods"`
+}
+
+// encodeInventory 按配置的格式生成实例信息
+func (s *InstanceSyncer) encodeInventory(ctx context.Context, nodes []*corev1.Node) ([]byte, error) {
+	if s.format == InventoryFormatV1 {
+		nodeIds := []string{}
+		for _, node := range nodes {
+			if node.Spec.ProviderID == "" {
+				logger.Warnf("Node[%s] has no providerID, skip", node.Name)
+				continue
+			}
+			nodeIds = append(nodeIds, node.Spec.ProviderID)
+		}
+		// 排序保证节点未变化时内容一致
+		sort.Strings(nodeIds)
+		logger.Infof("Get nodeIds: %v", nodeIds)
+		data := newInstanceData(s.clusterId, nodeIds)
+		dataBytes, err := json.Marshal(data)
+		return dataBytes, errors.Wrapf(err, "Marshal data[%+v] err", data)
+	}
+
+	managedPods, err := countManagedPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data := &inventory{Version: InventoryFormatV2, ClusterId: s.clusterId, Nodes: make([]nodeInventory, 0, len(nodes))}
+	for _, node := range nodes {
+		item := newNodeInventory(node)
+		item.ManagedPods = managedPods[node.Name]
+		if item.ProviderIDMissing {
+			logger.Warnf("Node[%s] has no providerID", node.Name)
+		}
+		data.Nodes = append(data.Nodes, item)
+	}
+	sort.Slice(data.Nodes, func(i, j int) bool { return data.Nodes[i].Name < data.Nodes[j].Name })
+	logger.Infof("Get %d nodes for inventory", len(data.Nodes))
+	dataBytes, err := json.Marshal(data)
+	return dataBytes, errors.Wrap(err, "Marshal inventory err")
+}
+
+func newNodeInventory(node *corev1.Node) nodeInventory {
+	return nodeInventory{
+		Name:                   node.Name,
+		ProviderID:             node.Spec.ProviderID,
+		ProviderIDMissing:      node.Spec.ProviderID == "",
+		Ready:                  nodeConditionStatus(node, corev1.NodeReady) == corev1.ConditionTrue,
+		Schedulable:            !node.Spec.Unschedulable,
+		Zone:                   firstLabel(node, corev1.LabelTopologyZone, corev1.LabelFailureDomainBetaZone),
+		Region:                 firstLabel(node, corev1.LabelTopologyRegion, corev1.LabelFailureDomainBetaRegion),
+		Flavor:                 firstLabel(node, corev1.LabelInstanceTypeStable, corev1.LabelInstanceType),
+		AllocatableMilliCPU:    node.Status.Allocatable.Cpu().MilliValue(),
+		AllocatableMemoryBytes: node.Status.Allocatable.Memory().Value(),
+	}
+}
+
+func firstLabel(node *corev1.Node, keys ...string) string {
+	for _, key := range keys {
+		if value := node.Labels[key]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// countManagedPods 统计各节点上 customHPA 目标负载未结束的 pod 数
+func countManagedPods(ctx context.Context) (map[string]int, error) {
+	chpas, err := k8sclient.GetCrdClientSet().AutoscalingV1alpha1().
+		CustomedHorizontalPodAutoscalers(metav1.NamespaceDefault).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "list customHPA err")
+	}
+	counted := map[string]bool{}
+	counts := map[string]int{}
+	for i := range chpas.Items {
+		selector, err := workloadSelector(ctx, chpas.Items[i].Spec.ScaleTargetRef)
+		if err != nil {
+			logger.Warnf("Get workload of customHPA[%s] err, skip counting its pods: %+v", chpas.Items[i].Name, err)
+			continue
+		}
+		pods, err := k8sclient.GetKubeClientSet().CoreV1().Pods(metav1.NamespaceDefault).
+			List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, errors.Wrapf(err, "list pods of customHPA[%s] err", chpas.Items[i].Name)
+		}
+		for j := range pods.Items {
+			pod := &pods.Items[j]
+			// 多个 customHPA 指向同一负载时 pod 只统计一次
+			if pod.Spec.NodeName == "" || counted[pod.Name] ||
+				pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+				continue
+			}
+			counted[pod.Name] = true
+			counts[pod.Spec.NodeName]++
+		}
+	}
+	return counts, nil
+}
+
+// workloadSelector ScaleTargetRef 指向负载的 pod 标签选择器，目前支持 Deployment 和 StatefulSet
+func workloadSelector(ctx context.Context, ref v1alpha1.ScaleTargetRef) (string, error) {
+	appsCli := k8sclient.GetKubeClientSet().AppsV1()
+	var selector *metav1.LabelSelector
+	switch ref.Kind {
+	case "Deployment":
+		d, err := appsCli.Deployments(metav1.NamespaceDefault).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", errors.Wrapf(err, "get deployment[%s] err", ref.Name)
+		}
+		selector = d.Spec.Selector
+	case "StatefulSet":
+		sts, err := appsCli.StatefulSets(metav1.NamespaceDefault).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", errors.Wrapf(err, "get statefulset[%s] err", ref.Name)
+		}
+		selector = sts.Spec.Selector
+	default:
+		return "", errors.Errorf("unsupported scale target kind[%s]", ref.Kind)
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid workload selector")
+	}
+	return s.String(), nil
+}
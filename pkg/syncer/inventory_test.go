@@ -0,0 +1,110 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient/apis/autoscaling/v1alpha1"
+	crdfake "nanto.io/application-auto-scaling-service/pkg/k8sclient/clientset/versioned/fake"
+)
+
+func newTestPod(name, nodeName string, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault, Labels: labels},
+		Spec:       corev1.PodSpec{NodeName: nodeName},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func TestInstanceSyncer_encodeInventory(t *testing.T) {
+	node1 := newTestNode("node-1", "id-1")
+	node1.Labels = map[string]string{corev1.LabelTopologyZone: "az-1", corev1.LabelTopologyRegion: "region-1",
+		corev1.LabelInstanceTypeStable: "c6.large.2"}
+	node1.Status.Allocatable = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1900m"),
+		corev1.ResourceMemory: resource.MustParse("3Gi")}
+	node1.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}}
+	node2 := newTestNode("node-2", "")
+	node2.Spec.Unschedulable = true
+	node2.Labels = map[string]string{corev1.LabelFailureDomainBetaZone: "az-2"}
+
+	appLabels := map[string]string{"app": "worker"}
+	finished := newTestPod("worker-3", "node-1", appLabels)
+	finished.Status.Phase = corev1.PodSucceeded
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: metav1.NamespaceDefault},
+		Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: appLabels}},
+	}
+	cs := kubefake.NewSimpleClientset(node1, node2, deploy,
+		newTestPod("worker-1", "node-1", appLabels), newTestPod("worker-2", "node-1", appLabels),
+		newTestPod("worker-4", "", appLabels), finished, newTestPod("other", "node-1", nil))
+	chpa := &v1alpha1.CustomedHorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-hpa", Namespace: metav1.NamespaceDefault},
+		Spec: v1alpha1.CustomedHorizontalPodAutoscalerSpec{
+			ScaleTargetRef: v1alpha1.ScaleTargetRef{Kind: "Deployment", Name: "worker"}},
+	}
+	k8sclient.SetK8sClientSet(cs, crdfake.NewSimpleClientset(chpa), record.NewFakeRecorder(10))
+	nodes := []*corev1.Node{node2, node1}
+
+	t.Run("v2", func(t *testing.T) {
+		s := newTestSyncer(t, &fakeObjectStore{})
+		data, err := s.encodeInventory(context.Background(), nodes)
+		if err != nil {
+			t.Fatalf("encodeInventory() err: %+v", err)
+		}
+		got := &inventory{}
+		if err = json.Unmarshal(data, got); err != nil {
+			t.Fatalf("unmarshal inventory err: %v", err)
+		}
+		want := &inventory{Version: InventoryFormatV2, ClusterId: "cluster-1", Nodes: []nodeInventory{
+			{Name: "node-1", ProviderID: "id-1", Ready: true, Schedulable: true, Zone: "az-1", Region: "region-1",
+				Flavor: "c6.large.2", AllocatableMilliCPU: 1900, AllocatableMemoryBytes: 3 << 30, ManagedPods: 2},
+			{Name: "node-2", ProviderIDMissing: true, Zone: "az-2"},
+		}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("encodeInventory() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("v1 skips nodes without providerID", func(t *testing.T) {
+		s := newTestSyncer(t, &fakeObjectStore{})
+		s.format = InventoryFormatV1
+		data, err := s.encodeInventory(context.Background(), nodes)
+		if err != nil {
+			t.Fatalf("encodeInventory() err: %+v", err)
+		}
+		if want := `{"ClusterId":"cluster-1","NodeIds":["id-1"]}`; string(data) != want {
+			t.Errorf("encodeInventory() = %s, want %s", data, want)
+		}
+	})
+}
+
+func Test_nodeChanged(t *testing.T) {
+	node := newTestNode("node-1", "id-1")
+	node.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}}
+
+	heartbeat := node.DeepCopy()
+	heartbeat.Status.Conditions[0].LastHeartbeatTime = metav1.Now()
+	if nodeChanged(node, heartbeat) {
+		t.Errorf("nodeChanged() on heartbeat = true, want false")
+	}
+	notReady := node.DeepCopy()
+	notReady.Status.Conditions[0].Status = corev1.ConditionUnknown
+	if !nodeChanged(node, notReady) {
+		t.Errorf("nodeChanged() on not ready = false, want true")
+	}
+	cordoned := node.DeepCopy()
+	cordoned.Spec.Unschedulable = true
+	if !nodeChanged(node, cordoned) {
+		t.Errorf("nodeChanged() on cordon = false, want true")
+	}
+}
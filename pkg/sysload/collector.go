@@ -0,0 +1,79 @@
+package sysload
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+)
+
+// PodUsage pod 的资源使用量（各容器之和）
+type PodUsage struct {
+	Namespace string
+	Name      string
+	// CPU 使用量（毫核）
+	CPUMilli int64
+	// 内存使用量（字节）
+	MemoryBytes int64
+}
+
+// NodeUsage 节点的资源使用量
+type NodeUsage struct {
+	Name        string
+	CPUMilli    int64
+	MemoryBytes int64
+}
+
+// Collector 采集 pod、节点的系统负载
+type Collector interface {
+	// PodUsages 获取命名空间下匹配标签选择器的 pod 的资源使用量
+	PodUsages(ctx context.Context, namespace string, selector labels.Selector) ([]PodUsage, error)
+	// NodeUsages 获取所有节点的资源使用量
+	NodeUsages(ctx context.Context) ([]NodeUsage, error)
+}
+
+// metricsCollector 通过 metrics.k8s.io API（metrics-server）采集系统负载
+type metricsCollector struct{}
+
+// NewMetricsCollector 创建通过 metrics.k8s.io API 采集系统负载的 Collector，使用 k8sclient 中的 metrics clientset
+func NewMetricsCollector() Collector {
+	return &metricsCollector{}
+}
+
+func (c *metricsCollector) PodUsages(ctx context.Context, namespace string, selector labels.Selector) ([]PodUsage,
+	error) {
+	list, err := k8sclient.GetMetricsClientSet().MetricsV1beta1().PodMetricses(namespace).
+		List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, errors.Wrapf(err, "list pod metrics of namespace[%s] selector[%s] err", namespace, selector)
+	}
+	usages := make([]PodUsage, 0, len(list.Items))
+	for _, item := range list.Items {
+		usage := PodUsage{Namespace: item.Namespace, Name: item.Name}
+		for _, container := range item.Containers {
+			usage.CPUMilli += container.Usage.Cpu().MilliValue()
+			usage.MemoryBytes += container.Usage.Memory().Value()
+		}
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
+
+func (c *metricsCollector) NodeUsages(ctx context.Context) ([]NodeUsage, error) {
+	list, err := k8sclient.GetMetricsClientSet().MetricsV1beta1().NodeMetricses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "list node metrics err")
+	}
+	usages := make([]NodeUsage, 0, len(list.Items))
+	for _, item := range list.Items {
+		usages = append(usages, NodeUsage{
+			Name:        item.Name,
+			CPUMilli:    item.Usage.Cpu().MilliValue(),
+			MemoryBytes: item.Usage.Memory().Value(),
+		})
+	}
+	return usages, nil
+}
@@ -0,0 +1,107 @@
+package sysload
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+	crdfake "nanto.io/application-auto-scaling-service/pkg/k8sclient/clientset/versioned/fake"
+)
+
+func newTestPod(name string, cpuRequest string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{"app": "worker"}},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "worker",
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+				corev1.ResourceCPU: resource.MustParse(cpuRequest),
+			}},
+		}}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func newTestNode(name string, cpu string, unschedulable bool) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       corev1.NodeSpec{Unschedulable: unschedulable},
+		Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse(cpu),
+		}},
+	}
+}
+
+func TestMetricsCollector(t *testing.T) {
+	k8sclient.SetK8sClientSet(kubefake.NewSimpleClientset(), crdfake.NewSimpleClientset(), record.NewFakeRecorder(10))
+	// fake clientset 的 tracker 无法将 PodMetrics/NodeMetrics 对应到 pods/nodes 资源，通过 reactor 返回数据
+	metricsClient := metricsfake.NewSimpleClientset()
+	metricsClient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &metricsv1beta1.PodMetricsList{Items: []metricsv1beta1.PodMetrics{{
+			ObjectMeta: metav1.ObjectMeta{Name: "p1", Namespace: "default"},
+			Containers: []metricsv1beta1.ContainerMetrics{
+				{Name: "a", Usage: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m"),
+					corev1.ResourceMemory: resource.MustParse("1Mi")}},
+				{Name: "b", Usage: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")}},
+			},
+		}}}, nil
+	})
+	metricsClient.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &metricsv1beta1.NodeMetricsList{Items: []metricsv1beta1.NodeMetrics{{
+			ObjectMeta: metav1.ObjectMeta{Name: "n1"},
+			Usage:      corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+		}}}, nil
+	})
+	k8sclient.SetMetricsClientSet(metricsClient)
+
+	collector := NewMetricsCollector()
+	pods, err := collector.PodUsages(context.Background(), "default", labels.Everything())
+	if err != nil || len(pods) != 1 || pods[0].CPUMilli != 300 || pods[0].MemoryBytes != 1024*1024 {
+		t.Errorf("PodUsages() got = %+v, err: %v", pods, err)
+	}
+	nodes, err := collector.NodeUsages(context.Background())
+	if err != nil || len(nodes) != 1 || nodes[0].CPUMilli != 2000 {
+		t.Errorf("NodeUsages() got = %+v, err: %v", nodes, err)
+	}
+}
+
+func TestCPURatio(t *testing.T) {
+	pending := newTestPod("p3", "1")
+	pending.Status.Phase = corev1.PodPending
+	k8sclient.SetK8sClientSet(kubefake.NewSimpleClientset(
+		newTestPod("p1", "500m"), newTestPod("p2", "500m"), pending,
+		newTestNode("n1", "4", false), newTestNode("n2", "4", false), newTestNode("n3", "4", true),
+	), crdfake.NewSimpleClientset(), record.NewFakeRecorder(10))
+	collector := &FakeCollector{
+		Pods: []PodUsage{
+			{Namespace: "default", Name: "p1", CPUMilli: 400},
+			{Namespace: "default", Name: "p2", CPUMilli: 200},
+			// 非 Running 的 pod 不统计
+			{Namespace: "default", Name: "p3", CPUMilli: 1000},
+		},
+		Nodes: []NodeUsage{{Name: "n1", CPUMilli: 3000}, {Name: "n2", CPUMilli: 1000}, {Name: "n3", CPUMilli: 4000}},
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{"app": "worker"})
+	if got, err := PodsCPURatio(context.Background(), collector, "default", selector); err != nil || got != 0.6 {
+		t.Errorf("PodsCPURatio() got = %v, err: %v, want 0.6", got, err)
+	}
+	// 不可调度的节点不统计
+	if got, err := NodesCPURatio(context.Background(), collector); err != nil || got != 0.5 {
+		t.Errorf("NodesCPURatio() got = %v, err: %v, want 0.5", got, err)
+	}
+
+	if _, err := PodsCPURatio(context.Background(), &FakeCollector{}, "default", selector); err == nil {
+		t.Errorf("PodsCPURatio() without metrics expect err")
+	}
+}
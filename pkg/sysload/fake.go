@@ -0,0 +1,36 @@
+package sysload
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// FakeCollector 用于单测的 Collector，返回预设的资源使用量
+type FakeCollector struct {
+	Pods  []PodUsage
+	Nodes []NodeUsage
+	Err   error
+}
+
+// PodUsages 返回预设的 pod 资源使用量（不按标签过滤，只按命名空间过滤）
+func (c *FakeCollector) PodUsages(_ context.Context, namespace string, _ labels.Selector) ([]PodUsage, error) {
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	usages := []PodUsage{}
+	for _, usage := range c.Pods {
+		if usage.Namespace == namespace {
+			usages = append(usages, usage)
+		}
+	}
+	return usages, nil
+}
+
+// NodeUsages 返回预设的节点资源使用量
+func (c *FakeCollector) NodeUsages(_ context.Context) ([]NodeUsage, error) {
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	return c.Nodes, nil
+}
@@ -0,0 +1,86 @@
+package sysload
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"nanto.io/application-auto-scaling-service/pkg/k8sclient"
+)
+
+// ErrNoMetrics 没有可用的负载数据（如 pod 未设置 CPU request、metrics-server 尚未采集到数据）
+var ErrNoMetrics = errors.New("no metrics available")
+
+// PodsCPURatio 匹配标签选择器的 pod 的 CPU 使用量之和与 CPU request 之和的比值（与 CPURatioToRequest 指标一致），
+// 只统计同时有 request 和使用量数据的 pod
+func PodsCPURatio(ctx context.Context, collector Collector, namespace string, selector labels.Selector) (float64,
+	error) {
+	pods, err := k8sclient.GetKubeClientSet().CoreV1().Pods(namespace).
+		List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return 0, errors.Wrapf(err, "list pods of namespace[%s] selector[%s] err", namespace, selector)
+	}
+	requests := map[string]int64{}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		var request int64
+		for _, container := range pod.Spec.Containers {
+			request += container.Resources.Requests.Cpu().MilliValue()
+		}
+		if request > 0 {
+			requests[pod.Name] = request
+		}
+	}
+
+	usages, err := collector.PodUsages(ctx, namespace, selector)
+	if err != nil {
+		return 0, err
+	}
+	var usageSum, requestSum int64
+	for _, usage := range usages {
+		if request, ok := requests[usage.Name]; ok {
+			usageSum += usage.CPUMilli
+			requestSum += request
+		}
+	}
+	if requestSum == 0 {
+		return 0, errors.Wrapf(ErrNoMetrics, "namespace[%s] selector[%s]", namespace, selector)
+	}
+	return float64(usageSum) / float64(requestSum), nil
+}
+
+// NodesCPURatio 可调度节点的 CPU 使用量之和与 allocatable 之和的比值
+func NodesCPURatio(ctx context.Context, collector Collector) (float64, error) {
+	nodes, err := k8sclient.GetKubeClientSet().CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, errors.Wrap(err, "list nodes err")
+	}
+	allocatable := map[string]int64{}
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		allocatable[node.Name] = node.Status.Allocatable.Cpu().MilliValue()
+	}
+
+	usages, err := collector.NodeUsages(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var usageSum, allocatableSum int64
+	for _, usage := range usages {
+		if cpu, ok := allocatable[usage.Name]; ok {
+			usageSum += usage.CPUMilli
+			allocatableSum += cpu
+		}
+	}
+	if allocatableSum == 0 {
+		return 0, errors.Wrap(ErrNoMetrics, "nodes")
+	}
+	return float64(usageSum) / float64(allocatableSum), nil
+}
@@ -1,6 +1,7 @@
 package obsutil
 
 import (
+	"net/http"
 	"os"
 
 	"github.com/huaweicloud/huaweicloud-sdk-go-obs/obs"
@@ -37,16 +38,46 @@ func NewObsClient(endpoint string) (*ObsClient, error) {
 	return &ObsClient{ObsCli: obsCli}, nil
 }
 
+// ErrObjNotFound 对象不存在
+var ErrObjNotFound = errors.New("object not found")
+
+// ObjMeta 对象的 ETag 及自定义元数据
+type ObjMeta struct {
+	ETag     string
+	Metadata map[string]string
+}
+
 // UploadObj 上传文件，入参 srcPath 和 targetPath 需要指定到文件名
 func (c *ObsClient) UploadObj(bucket, srcPath, targetPath string) error {
+	_, err := c.UploadObjWithMetadata(bucket, srcPath, targetPath, nil)
+	return err
+}
+
+// UploadObjWithMetadata 上传文件并设置对象的自定义元数据，返回对象的 ETag
+func (c *ObsClient) UploadObjWithMetadata(bucket, srcPath, targetPath string, metadata map[string]string) (string,
+	error) {
 	input := &obs.PutFileInput{}
 	input.Bucket = bucket
 	input.Key = targetPath
 	input.SourceFile = srcPath
+	input.Metadata = metadata
 	output, err := c.ObsCli.PutFile(input)
 	if err != nil {
-		return errors.Wrapf(err, "failed to send file[%s]", srcPath)
+		return "", errors.Wrapf(err, "failed to send file[%s]", srcPath)
 	}
 	logger.Infof("Success to send file[%s], RequestId[%s]", srcPath, output.RequestId)
-	return nil
+	return output.ETag, nil
+}
+
+// GetObjMeta 获取对象的 ETag 及自定义元数据，对象不存在时返回 ErrObjNotFound
+func (c *ObsClient) GetObjMeta(bucket, key string) (*ObjMeta, error) {
+	input := &obs.GetObjectMetadataInput{Bucket: bucket, Key: key}
+	output, err := c.ObsCli.GetObjectMetadata(input)
+	if obsErr, ok := err.(obs.ObsError); ok && obsErr.StatusCode == http.StatusNotFound {
+		return nil, errors.Wrapf(ErrObjNotFound, "object[%s]", key)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get metadata of object[%s]", key)
+	}
+	return &ObjMeta{ETag: output.ETag, Metadata: output.Metadata}, nil
 }
@@ -0,0 +1,14 @@
+package utils
+
+// Int32Value 返回 int32 指针指向的值，指针为空时返回 0
+func Int32Value(p *int32) int32 {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+// Int32Ptr 返回 int32 值的指针
+func Int32Ptr(i int32) *int32 {
+	return &i
+}